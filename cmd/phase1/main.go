@@ -9,10 +9,15 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
+	"redis-timeout-tracking-poc/pkg/grpcserver"
 	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/notifier"
 	"redis-timeout-tracking-poc/pkg/phase1"
 	redisClient "redis-timeout-tracking-poc/pkg/redis"
+	"redis-timeout-tracking-poc/pkg/server"
 )
 
 func main() {
@@ -33,7 +38,12 @@ func main() {
 
 	// Connect to Redis
 	redisConfig := redisClient.DefaultConnectionConfig()
+	redisConfig.Mode = cfg.RedisMode
 	redisConfig.URL = cfg.RedisURL
+	redisConfig.Addrs = cfg.RedisAddrs
+	redisConfig.MasterName = cfg.RedisMasterName
+	redisConfig.Password = cfg.RedisPassword
+	redisConfig.SentinelPassword = cfg.RedisSentinelPassword
 
 	redis, err := redisClient.NewClient(redisConfig, logger)
 	if err != nil {
@@ -41,8 +51,25 @@ func main() {
 	}
 	defer redis.Close()
 
+	// Load and validate escalation policies before doing any work
+	evaluator, err := escalation.Load(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to load escalation policies")
+	}
+
+	// Notifications go to the configured webhook, or are only logged
+	notifier, err := notifier.New(cfg, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to configure notifications")
+	}
+
 	// Create Phase 1 service
-	service := phase1.NewService(redis.GetRedisClient(), cfg, logger, metrics)
+	clk := clock.New()
+	service := phase1.NewService(redis.GetRedisClient(), cfg, logger, metrics, notifier, evaluator, clk)
+
+	// Serve the HTTP and gRPC APIs
+	httpServer := server.NewHTTPServer(cfg, service.GetTimeoutManager(), evaluator, logger, metrics, service, clk)
+	grpcServer := grpcserver.NewGRPCServer(grpcserver.NewServer(redis.GetRedisClient(), service.GetTimeoutManager(), evaluator, logger, clk))
 
 	// Setup context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -52,6 +79,10 @@ func main() {
 	if err := service.Start(ctx); err != nil {
 		logger.WithError(err).Fatal("Failed to start service")
 	}
+	server.Start(httpServer, logger)
+	if err := grpcserver.Serve(grpcServer, cfg.GRPCPort, logger); err != nil {
+		logger.WithError(err).Fatal("Failed to start gRPC server")
+	}
 
 	// Wait for shutdown signal
 	sigCh := make(chan os.Signal, 1)
@@ -64,6 +95,10 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.WithError(err).Error("Failed to shutdown HTTP server gracefully")
+	}
+	grpcserver.Shutdown(shutdownCtx, grpcServer)
 	if err := service.Stop(shutdownCtx); err != nil {
 		logger.WithError(err).Error("Error during service shutdown")
 	}
@@ -0,0 +1,346 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: timeout/v1/timeout_tracking.proto
+
+package timeoutv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	TimeoutTracking_TrackAgentMessage_FullMethodName       = "/timeout.v1.TimeoutTracking/TrackAgentMessage"
+	TimeoutTracking_ClearTimeout_FullMethodName            = "/timeout.v1.TimeoutTracking/ClearTimeout"
+	TimeoutTracking_GetConversation_FullMethodName         = "/timeout.v1.TimeoutTracking/GetConversation"
+	TimeoutTracking_BatchTrackAgentMessages_FullMethodName = "/timeout.v1.TimeoutTracking/BatchTrackAgentMessages"
+	TimeoutTracking_BatchClearTimeouts_FullMethodName      = "/timeout.v1.TimeoutTracking/BatchClearTimeouts"
+	TimeoutTracking_WatchEscalations_FullMethodName        = "/timeout.v1.TimeoutTracking/WatchEscalations"
+)
+
+// TimeoutTrackingClient is the client API for TimeoutTracking service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TimeoutTrackingClient interface {
+	// TrackAgentMessage starts, or restarts, waiting on a conversation for an
+	// agent message
+	TrackAgentMessage(ctx context.Context, in *TrackAgentMessageRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	// ClearTimeout stops waiting on a conversation for a customer response
+	ClearTimeout(ctx context.Context, in *ClearTimeoutRequest, opts ...grpc.CallOption) (*EventResponse, error)
+	// GetConversation explains where a conversation is in its escalation
+	// ladder
+	GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*Conversation, error)
+	// BatchTrackAgentMessages tracks many agent messages in one round trip to
+	// Redis; each succeeds or fails on its own
+	BatchTrackAgentMessages(ctx context.Context, in *BatchTrackAgentMessagesRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	// BatchClearTimeouts clears many conversations in one round trip to
+	// Redis; each succeeds or fails on its own
+	BatchClearTimeouts(ctx context.Context, in *BatchClearTimeoutsRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	// WatchEscalations streams the escalations detected from now on, by any
+	// pod, until the client cancels. Escalations detected while the stream is
+	// disconnected are missed.
+	WatchEscalations(ctx context.Context, in *WatchEscalationsRequest, opts ...grpc.CallOption) (TimeoutTracking_WatchEscalationsClient, error)
+}
+
+type timeoutTrackingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTimeoutTrackingClient(cc grpc.ClientConnInterface) TimeoutTrackingClient {
+	return &timeoutTrackingClient{cc}
+}
+
+func (c *timeoutTrackingClient) TrackAgentMessage(ctx context.Context, in *TrackAgentMessageRequest, opts ...grpc.CallOption) (*EventResponse, error) {
+	out := new(EventResponse)
+	err := c.cc.Invoke(ctx, TimeoutTracking_TrackAgentMessage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeoutTrackingClient) ClearTimeout(ctx context.Context, in *ClearTimeoutRequest, opts ...grpc.CallOption) (*EventResponse, error) {
+	out := new(EventResponse)
+	err := c.cc.Invoke(ctx, TimeoutTracking_ClearTimeout_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeoutTrackingClient) GetConversation(ctx context.Context, in *GetConversationRequest, opts ...grpc.CallOption) (*Conversation, error) {
+	out := new(Conversation)
+	err := c.cc.Invoke(ctx, TimeoutTracking_GetConversation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeoutTrackingClient) BatchTrackAgentMessages(ctx context.Context, in *BatchTrackAgentMessagesRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, TimeoutTracking_BatchTrackAgentMessages_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeoutTrackingClient) BatchClearTimeouts(ctx context.Context, in *BatchClearTimeoutsRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, TimeoutTracking_BatchClearTimeouts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *timeoutTrackingClient) WatchEscalations(ctx context.Context, in *WatchEscalationsRequest, opts ...grpc.CallOption) (TimeoutTracking_WatchEscalationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &TimeoutTracking_ServiceDesc.Streams[0], TimeoutTracking_WatchEscalations_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &timeoutTrackingWatchEscalationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type TimeoutTracking_WatchEscalationsClient interface {
+	Recv() (*Escalation, error)
+	grpc.ClientStream
+}
+
+type timeoutTrackingWatchEscalationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *timeoutTrackingWatchEscalationsClient) Recv() (*Escalation, error) {
+	m := new(Escalation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TimeoutTrackingServer is the server API for TimeoutTracking service.
+// All implementations must embed UnimplementedTimeoutTrackingServer
+// for forward compatibility
+type TimeoutTrackingServer interface {
+	// TrackAgentMessage starts, or restarts, waiting on a conversation for an
+	// agent message
+	TrackAgentMessage(context.Context, *TrackAgentMessageRequest) (*EventResponse, error)
+	// ClearTimeout stops waiting on a conversation for a customer response
+	ClearTimeout(context.Context, *ClearTimeoutRequest) (*EventResponse, error)
+	// GetConversation explains where a conversation is in its escalation
+	// ladder
+	GetConversation(context.Context, *GetConversationRequest) (*Conversation, error)
+	// BatchTrackAgentMessages tracks many agent messages in one round trip to
+	// Redis; each succeeds or fails on its own
+	BatchTrackAgentMessages(context.Context, *BatchTrackAgentMessagesRequest) (*BatchResponse, error)
+	// BatchClearTimeouts clears many conversations in one round trip to
+	// Redis; each succeeds or fails on its own
+	BatchClearTimeouts(context.Context, *BatchClearTimeoutsRequest) (*BatchResponse, error)
+	// WatchEscalations streams the escalations detected from now on, by any
+	// pod, until the client cancels. Escalations detected while the stream is
+	// disconnected are missed.
+	WatchEscalations(*WatchEscalationsRequest, TimeoutTracking_WatchEscalationsServer) error
+	mustEmbedUnimplementedTimeoutTrackingServer()
+}
+
+// UnimplementedTimeoutTrackingServer must be embedded to have forward compatible implementations.
+type UnimplementedTimeoutTrackingServer struct {
+}
+
+func (UnimplementedTimeoutTrackingServer) TrackAgentMessage(context.Context, *TrackAgentMessageRequest) (*EventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TrackAgentMessage not implemented")
+}
+func (UnimplementedTimeoutTrackingServer) ClearTimeout(context.Context, *ClearTimeoutRequest) (*EventResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearTimeout not implemented")
+}
+func (UnimplementedTimeoutTrackingServer) GetConversation(context.Context, *GetConversationRequest) (*Conversation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConversation not implemented")
+}
+func (UnimplementedTimeoutTrackingServer) BatchTrackAgentMessages(context.Context, *BatchTrackAgentMessagesRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchTrackAgentMessages not implemented")
+}
+func (UnimplementedTimeoutTrackingServer) BatchClearTimeouts(context.Context, *BatchClearTimeoutsRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchClearTimeouts not implemented")
+}
+func (UnimplementedTimeoutTrackingServer) WatchEscalations(*WatchEscalationsRequest, TimeoutTracking_WatchEscalationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEscalations not implemented")
+}
+func (UnimplementedTimeoutTrackingServer) mustEmbedUnimplementedTimeoutTrackingServer() {}
+
+// UnsafeTimeoutTrackingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TimeoutTrackingServer will
+// result in compilation errors.
+type UnsafeTimeoutTrackingServer interface {
+	mustEmbedUnimplementedTimeoutTrackingServer()
+}
+
+func RegisterTimeoutTrackingServer(s grpc.ServiceRegistrar, srv TimeoutTrackingServer) {
+	s.RegisterService(&TimeoutTracking_ServiceDesc, srv)
+}
+
+func _TimeoutTracking_TrackAgentMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TrackAgentMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeoutTrackingServer).TrackAgentMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeoutTracking_TrackAgentMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeoutTrackingServer).TrackAgentMessage(ctx, req.(*TrackAgentMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeoutTracking_ClearTimeout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearTimeoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeoutTrackingServer).ClearTimeout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeoutTracking_ClearTimeout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeoutTrackingServer).ClearTimeout(ctx, req.(*ClearTimeoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeoutTracking_GetConversation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConversationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeoutTrackingServer).GetConversation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeoutTracking_GetConversation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeoutTrackingServer).GetConversation(ctx, req.(*GetConversationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeoutTracking_BatchTrackAgentMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchTrackAgentMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeoutTrackingServer).BatchTrackAgentMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeoutTracking_BatchTrackAgentMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeoutTrackingServer).BatchTrackAgentMessages(ctx, req.(*BatchTrackAgentMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeoutTracking_BatchClearTimeouts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchClearTimeoutsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TimeoutTrackingServer).BatchClearTimeouts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TimeoutTracking_BatchClearTimeouts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TimeoutTrackingServer).BatchClearTimeouts(ctx, req.(*BatchClearTimeoutsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TimeoutTracking_WatchEscalations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEscalationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TimeoutTrackingServer).WatchEscalations(m, &timeoutTrackingWatchEscalationsServer{stream})
+}
+
+type TimeoutTracking_WatchEscalationsServer interface {
+	Send(*Escalation) error
+	grpc.ServerStream
+}
+
+type timeoutTrackingWatchEscalationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *timeoutTrackingWatchEscalationsServer) Send(m *Escalation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// TimeoutTracking_ServiceDesc is the grpc.ServiceDesc for TimeoutTracking service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TimeoutTracking_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "timeout.v1.TimeoutTracking",
+	HandlerType: (*TimeoutTrackingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "TrackAgentMessage",
+			Handler:    _TimeoutTracking_TrackAgentMessage_Handler,
+		},
+		{
+			MethodName: "ClearTimeout",
+			Handler:    _TimeoutTracking_ClearTimeout_Handler,
+		},
+		{
+			MethodName: "GetConversation",
+			Handler:    _TimeoutTracking_GetConversation_Handler,
+		},
+		{
+			MethodName: "BatchTrackAgentMessages",
+			Handler:    _TimeoutTracking_BatchTrackAgentMessages_Handler,
+		},
+		{
+			MethodName: "BatchClearTimeouts",
+			Handler:    _TimeoutTracking_BatchClearTimeouts_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEscalations",
+			Handler:       _TimeoutTracking_WatchEscalations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "timeout/v1/timeout_tracking.proto",
+}
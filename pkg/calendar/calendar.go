@@ -0,0 +1,206 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxScanDays bounds how far ahead Add looks for working time, so a calendar
+// whose holidays cover every working day can't loop forever
+const maxScanDays = 3 * 366
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Calendar describes when a support team is working: weekly hours in a
+// timezone, minus holidays. Hours map lowercase weekday names to "HH:MM-HH:MM"
+// windows; holidays are "YYYY-MM-DD" dates in the calendar's timezone.
+//
+// Validate must be called before the calendar is used.
+type Calendar struct {
+	Name     string              `json:"name" yaml:"name"`
+	Timezone string              `json:"timezone" yaml:"timezone"`
+	Hours    map[string][]string `json:"hours" yaml:"hours"`
+	Holidays []string            `json:"holidays,omitempty" yaml:"holidays,omitempty"`
+
+	location *time.Location
+	windows  [7][]window
+	holidays map[string]bool
+}
+
+// window is a working period within a day, in minutes since midnight
+type window struct {
+	start, end int
+}
+
+// Validate parses the timezone, hours and holidays, and checks there is at
+// least one working window a week
+func (c *Calendar) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("calendar without a name")
+	}
+
+	location, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return fmt.Errorf("calendar %q: invalid timezone: %w", c.Name, err)
+	}
+	c.location = location
+
+	c.windows = [7][]window{}
+	working := false
+	for day, ranges := range c.Hours {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("calendar %q: unknown weekday %q", c.Name, day)
+		}
+		for _, r := range ranges {
+			w, err := parseWindow(r)
+			if err != nil {
+				return fmt.Errorf("calendar %q: %s: %w", c.Name, day, err)
+			}
+			c.windows[weekday] = append(c.windows[weekday], w)
+			working = true
+		}
+	}
+	if !working {
+		return fmt.Errorf("calendar %q has no working hours", c.Name)
+	}
+	for weekday, windows := range c.windows {
+		sort.Slice(windows, func(i, j int) bool { return windows[i].start < windows[j].start })
+		for i := 1; i < len(windows); i++ {
+			if windows[i].start < windows[i-1].end {
+				return fmt.Errorf("calendar %q: overlapping hours on %s", c.Name, strings.ToLower(time.Weekday(weekday).String()))
+			}
+		}
+	}
+
+	c.holidays = make(map[string]bool, len(c.Holidays))
+	for _, holiday := range c.Holidays {
+		if _, err := time.Parse("2006-01-02", holiday); err != nil {
+			return fmt.Errorf("calendar %q: invalid holiday %q", c.Name, holiday)
+		}
+		c.holidays[holiday] = true
+	}
+
+	return nil
+}
+
+// Elapsed returns how much working time passes between from and to
+func (c *Calendar) Elapsed(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+
+	var elapsed time.Duration
+	for day := c.startOfDay(from); day.Before(to); day = c.nextDay(day) {
+		for _, period := range c.periods(day) {
+			start, end := period[0], period[1]
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			if end.After(start) {
+				elapsed += end.Sub(start)
+			}
+		}
+	}
+	return elapsed
+}
+
+// Add returns the instant at which d of working time has passed since from.
+// Work that would finish exactly at the end of a window finishes there.
+func (c *Calendar) Add(from time.Time, d time.Duration) time.Time {
+	remaining := d
+	day := c.startOfDay(from)
+	for i := 0; i < maxScanDays; i, day = i+1, c.nextDay(day) {
+		for _, period := range c.periods(day) {
+			start, end := period[0], period[1]
+			if !end.After(from) {
+				continue
+			}
+			if start.Before(from) {
+				start = from
+			}
+			available := end.Sub(start)
+			if remaining <= available {
+				return start.Add(remaining)
+			}
+			remaining -= available
+		}
+	}
+	return day
+}
+
+// periods returns the working periods of a day, empty on holidays
+func (c *Calendar) periods(day time.Time) [][2]time.Time {
+	if c.holidays[day.Format("2006-01-02")] {
+		return nil
+	}
+
+	windows := c.windows[day.Weekday()]
+	periods := make([][2]time.Time, 0, len(windows))
+	for _, w := range windows {
+		periods = append(periods, [2]time.Time{c.at(day, w.start), c.at(day, w.end)})
+	}
+	return periods
+}
+
+func (c *Calendar) startOfDay(t time.Time) time.Time {
+	local := t.In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+}
+
+func (c *Calendar) nextDay(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, c.location)
+}
+
+// at returns the wall-clock time minutes after midnight on day, which is not
+// always day + minutes across a DST change
+func (c *Calendar) at(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, c.location)
+}
+
+func parseWindow(s string) (window, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return window{}, fmt.Errorf("invalid hours %q, want HH:MM-HH:MM", s)
+	}
+
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return window{}, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return window{}, err
+	}
+	if end <= start {
+		return window{}, fmt.Errorf("hours %q end before they start", s)
+	}
+
+	return window{start: start, end: end}, nil
+}
+
+// parseClock parses "HH:MM" into minutes since midnight; "24:00" is allowed
+// as the end of the day
+func parseClock(s string) (int, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &hours, &minutes); err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", s)
+	}
+	return hours*60 + minutes, nil
+}
@@ -0,0 +1,113 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCalendar(t *testing.T) *Calendar {
+	cal := &Calendar{
+		Name:     "us_east",
+		Timezone: "America/New_York",
+		Hours: map[string][]string{
+			"monday":    {"09:00-17:00"},
+			"tuesday":   {"09:00-17:00"},
+			"wednesday": {"09:00-17:00"},
+			"thursday":  {"09:00-17:00"},
+			"friday":    {"09:00-12:00", "13:00-17:00"},
+		},
+		Holidays: []string{"2026-12-25"},
+	}
+	require.NoError(t, cal.Validate())
+	return cal
+}
+
+func at(t *testing.T, value string) time.Time {
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	parsed, err := time.ParseInLocation("2006-01-02 15:04", value, location)
+	require.NoError(t, err)
+	return parsed
+}
+
+func TestCalendar_Elapsed(t *testing.T) {
+	cal := testCalendar(t)
+
+	tests := []struct {
+		name     string
+		from, to string
+		elapsed  time.Duration
+	}{
+		{"within a window", "2026-10-13 10:00", "2026-10-13 10:30", 30 * time.Minute},
+		{"before opening", "2026-10-13 07:00", "2026-10-13 09:15", 15 * time.Minute},
+		{"lunch break", "2026-10-16 11:30", "2026-10-16 13:30", time.Hour},
+		{"over the weekend", "2026-10-16 16:59", "2026-10-19 09:01", 2 * time.Minute},
+		{"over a holiday", "2026-12-24 16:00", "2026-12-28 12:00", 4 * time.Hour},
+		{"backwards", "2026-10-13 10:30", "2026-10-13 10:00", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.elapsed, cal.Elapsed(at(t, tt.from), at(t, tt.to)))
+		})
+	}
+}
+
+func TestCalendar_Add(t *testing.T) {
+	cal := testCalendar(t)
+
+	tests := []struct {
+		name string
+		from string
+		d    time.Duration
+		want string
+	}{
+		{"within a window", "2026-10-13 10:00", 30 * time.Minute, "2026-10-13 10:30"},
+		{"outside hours", "2026-10-13 20:00", 30 * time.Minute, "2026-10-14 09:30"},
+		{"ends at closing", "2026-10-13 16:00", time.Hour, "2026-10-13 17:00"},
+		{"Friday evening to Monday", "2026-10-16 16:59", 2 * time.Minute, "2026-10-19 09:01"},
+		{"skips lunch", "2026-10-16 11:30", time.Hour, "2026-10-16 13:30"},
+		{"skips a holiday", "2026-12-24 16:30", time.Hour, "2026-12-28 09:30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadline := cal.Add(at(t, tt.from), tt.d)
+			assert.True(t, at(t, tt.want).Equal(deadline), "got %s", deadline)
+			assert.Equal(t, tt.d, cal.Elapsed(at(t, tt.from), deadline))
+		})
+	}
+}
+
+func TestCalendar_AddAcrossDST(t *testing.T) {
+	cal := testCalendar(t)
+
+	// Clocks go back on Sunday 2026-11-01; working hours stay 09:00-17:00
+	// local time
+	deadline := cal.Add(at(t, "2026-10-30 16:00"), 2*time.Hour)
+	assert.True(t, at(t, "2026-11-02 10:00").Equal(deadline), "got %s", deadline)
+}
+
+func TestCalendar_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cal  Calendar
+	}{
+		{"no name", Calendar{Timezone: "UTC", Hours: map[string][]string{"monday": {"09:00-17:00"}}}},
+		{"bad timezone", Calendar{Name: "c", Timezone: "Mars/Olympus", Hours: map[string][]string{"monday": {"09:00-17:00"}}}},
+		{"no hours", Calendar{Name: "c", Timezone: "UTC"}},
+		{"unknown weekday", Calendar{Name: "c", Timezone: "UTC", Hours: map[string][]string{"funday": {"09:00-17:00"}}}},
+		{"end before start", Calendar{Name: "c", Timezone: "UTC", Hours: map[string][]string{"monday": {"17:00-09:00"}}}},
+		{"overlapping", Calendar{Name: "c", Timezone: "UTC", Hours: map[string][]string{"monday": {"09:00-13:00", "12:00-17:00"}}}},
+		{"bad holiday", Calendar{Name: "c", Timezone: "UTC", Hours: map[string][]string{"monday": {"09:00-17:00"}}, Holidays: []string{"25/12/2026"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.cal.Validate())
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for timeout tracking. Services use the real
+// clock; tests use a Manual clock and move it forward themselves.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on C like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// New returns the clock backed by the time package
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// Manual is a clock that only moves when Advance is called. Its tickers fire
+// as Advance passes their deadlines; like time.Ticker, ticks are dropped when
+// the receiver hasn't taken the previous one.
+type Manual struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	tickers []*manualTicker
+}
+
+// NewManual returns a manual clock that starts at now
+func NewManual(now time.Time) *Manual {
+	m := &Manual{now: now}
+	m.changed = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *Manual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticker := &manualTicker{
+		clock:  m,
+		c:      make(chan time.Time, 1),
+		period: d,
+		next:   m.now.Add(d),
+	}
+	m.tickers = append(m.tickers, ticker)
+	m.changed.Broadcast()
+	return ticker
+}
+
+// BlockUntil waits until n tickers are running, so that a test doesn't
+// advance the clock before the loops it drives have started
+func (m *Manual) BlockUntil(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.tickers) < n {
+		m.changed.Wait()
+	}
+}
+
+// Advance moves the clock forward by d and fires every ticker that comes due
+func (m *Manual) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = m.now.Add(d)
+	for _, ticker := range m.tickers {
+		for !ticker.next.After(m.now) {
+			select {
+			case ticker.c <- ticker.next:
+			default:
+			}
+			ticker.next = ticker.next.Add(ticker.period)
+		}
+	}
+}
+
+type manualTicker struct {
+	clock  *Manual
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManual_Advance(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	clock := NewManual(start)
+
+	clock.Advance(90 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), clock.Now())
+}
+
+func TestManual_Ticker(t *testing.T) {
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	clock := NewManual(start)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	// Ticks the receiver missed are dropped
+	clock.Advance(3 * time.Second)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+	select {
+	case <-ticker.C():
+		t.Fatal("missed ticks were queued")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(4*time.Second), <-ticker.C())
+
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestManual_BlockUntil(t *testing.T) {
+	clock := NewManual(time.Now())
+
+	started := make(chan struct{})
+	go func() {
+		clock.BlockUntil(2)
+		close(started)
+	}()
+
+	clock.NewTicker(time.Second)
+	clock.NewTicker(time.Minute)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("BlockUntil did not return")
+	}
+}
@@ -3,36 +3,85 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"redis-timeout-tracking-poc/pkg/constants"
 )
 
 type Config struct {
-	RedisURL          string
-	TimeoutIntervalMS int64
-	CheckIntervalMS   int64
-	LeaderElectionTTL int
-	PodID             string
-	Port              string
-	Phase2Mode        bool
-	ConsumerGroupName string
-	LogLevel          string
-	MetricsPort       string
+	RedisURL                string
+	RedisMode               string
+	RedisAddrs              []string
+	RedisMasterName         string
+	RedisPassword           string
+	RedisSentinelPassword   string
+	TimeoutIntervalMS       int64
+	CheckIntervalMS         int64
+	DetectionBatchSize      int64
+	ShardCount              int
+	LeaderElectionTTL       int
+	PodID                   string
+	Port                    string
+	GRPCPort                string
+	Phase2Mode              bool
+	ConsumerGroupName       string
+	IdempotencyTTL          int
+	MaxDeliveryAttempts     int
+	IngestStream            string
+	IngestConsumerGroup     string
+	IngestBatchSize         int64
+	HistoryLimit            int
+	HistoryTTL              int
+	EventDedupTTL           int
+	WebhookURL              string
+	WebhookSecret           string
+	WebhookTimeoutMS        int64
+	EscalationPolicyFile    string
+	TimeoutLevelMultipliers []int64
+	LogLevel                string
+	MetricsPort             string
 }
 
 func Load() *Config {
 	config := &Config{
-		RedisURL:          getEnv("REDIS_URL", "redis://localhost:6379"),
-		TimeoutIntervalMS: getEnvInt64("TIMEOUT_INTERVAL_MS", 30000),
-		CheckIntervalMS:   getEnvInt64("CHECK_INTERVAL_MS", 1000),
-		LeaderElectionTTL: getEnvInt("LEADER_ELECTION_TTL", 10),
-		PodID:             getEnv("POD_ID", generatePodID()),
-		Port:              getEnv("PORT", "8080"),
-		Phase2Mode:        getEnvBool("PHASE2_MODE", false),
-		ConsumerGroupName: getEnv("CONSUMER_GROUP_NAME", "timeout-processors"),
-		LogLevel:          getEnv("LOG_LEVEL", "info"),
-		MetricsPort:       getEnv("METRICS_PORT", "9090"),
+		RedisURL:              getEnv("REDIS_URL", "redis://localhost:6379"),
+		RedisMode:             getEnv("REDIS_MODE", "standalone"),
+		RedisAddrs:            getEnvList("REDIS_ADDRS"),
+		RedisMasterName:       getEnv("REDIS_MASTER_NAME", ""),
+		RedisPassword:         getEnv("REDIS_PASSWORD", ""),
+		RedisSentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+		TimeoutIntervalMS:     getEnvInt64("TIMEOUT_INTERVAL_MS", 30000),
+		CheckIntervalMS:       getEnvInt64("CHECK_INTERVAL_MS", 1000),
+		DetectionBatchSize:    getEnvInt64("DETECTION_BATCH_SIZE", constants.DefaultDetectionBatchSize),
+		ShardCount:            getEnvInt("SHARD_COUNT", constants.DefaultShardCount),
+		LeaderElectionTTL:     getEnvInt("LEADER_ELECTION_TTL", 10),
+		PodID:                 getEnv("POD_ID", generatePodID()),
+		Port:                  getEnv("PORT", "8080"),
+		GRPCPort:              getEnv("GRPC_PORT", "50051"),
+		Phase2Mode:            getEnvBool("PHASE2_MODE", false),
+		ConsumerGroupName:     getEnv("CONSUMER_GROUP_NAME", "timeout-processors"),
+		IdempotencyTTL:        getEnvInt("IDEMPOTENCY_TTL", 86400),
+		MaxDeliveryAttempts:   getEnvInt("MAX_DELIVERY_ATTEMPTS", 5),
+		IngestStream:          getEnv("INGEST_STREAM", ""),
+		IngestConsumerGroup:   getEnv("INGEST_CONSUMER_GROUP", "timeout-ingesters"),
+		IngestBatchSize:       getEnvInt64("INGEST_BATCH_SIZE", constants.DefaultIngestBatchSize),
+		HistoryLimit:          getEnvInt("HISTORY_LIMIT", constants.DefaultHistoryLimit),
+		HistoryTTL:            getEnvInt("HISTORY_TTL", constants.DefaultHistoryTTLSeconds),
+		EventDedupTTL:         getEnvInt("EVENT_DEDUP_TTL", constants.DefaultEventDedupTTLSeconds),
+		WebhookURL:            getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:         getEnv("WEBHOOK_SECRET", ""),
+		WebhookTimeoutMS:      getEnvInt64("WEBHOOK_TIMEOUT_MS", 5000),
+		EscalationPolicyFile:  getEnv("ESCALATION_POLICY_FILE", ""),
+		TimeoutLevelMultipliers: []int64{
+			getEnvInt64(constants.EnvTimeoutLevel1Multiplier, constants.TimeoutLevel1Multiplier),
+			getEnvInt64(constants.EnvTimeoutLevel2Multiplier, constants.TimeoutLevel2Multiplier),
+			getEnvInt64(constants.EnvTimeoutLevel3Multiplier, constants.TimeoutLevel3Multiplier),
+		},
+		LogLevel:    getEnv("LOG_LEVEL", "info"),
+		MetricsPort: getEnv("METRICS_PORT", "9090"),
 	}
 
 	return config
@@ -46,10 +95,89 @@ func (c *Config) CheckInterval() time.Duration {
 	return time.Duration(c.CheckIntervalMS) * time.Millisecond
 }
 
+// DetectionBatch returns how many due conversations a detector fetches at a
+// time, falling back to the default when unset
+func (c *Config) DetectionBatch() int64 {
+	if c.DetectionBatchSize <= 0 {
+		return constants.DefaultDetectionBatchSize
+	}
+	return c.DetectionBatchSize
+}
+
+// Shards returns how many detection shards waiting conversations are split
+// into, falling back to the default when unset
+func (c *Config) Shards() int {
+	if c.ShardCount <= 0 {
+		return constants.DefaultShardCount
+	}
+	return c.ShardCount
+}
+
 func (c *Config) LeaderElectionTTLDuration() time.Duration {
 	return time.Duration(c.LeaderElectionTTL) * time.Second
 }
 
+// LeaderRenewInterval returns how often the election runs: the leader renews
+// its lock, standbys try to take it. It is derived from the lock TTL, before
+// jitter.
+func (c *Config) LeaderRenewInterval() time.Duration {
+	return c.LeaderElectionTTLDuration() / constants.LeaderRenewDivisor
+}
+
+func (c *Config) IdempotencyTTLDuration() time.Duration {
+	return time.Duration(c.IdempotencyTTL) * time.Second
+}
+
+// DeliveryAttempts returns how many times a stream entry is delivered
+// before it is given up on, at least once
+func (c *Config) DeliveryAttempts() int {
+	if c.MaxDeliveryAttempts < 1 {
+		return 1
+	}
+	return c.MaxDeliveryAttempts
+}
+
+// IngestBatch returns how many input stream entries are read and applied at a
+// time, falling back to the default when unset
+func (c *Config) IngestBatch() int64 {
+	if c.IngestBatchSize <= 0 {
+		return constants.DefaultIngestBatchSize
+	}
+	return c.IngestBatchSize
+}
+
+// HistoryEvents returns how many events of a conversation's history are
+// kept, falling back to the default when unset
+func (c *Config) HistoryEvents() int {
+	if c.HistoryLimit <= 0 {
+		return constants.DefaultHistoryLimit
+	}
+	return c.HistoryLimit
+}
+
+// HistoryTTLDuration returns how long a conversation's history is kept once
+// it stops waiting, falling back to the default when unset
+func (c *Config) HistoryTTLDuration() time.Duration {
+	if c.HistoryTTL <= 0 {
+		return constants.DefaultHistoryTTLSeconds * time.Second
+	}
+	return time.Duration(c.HistoryTTL) * time.Second
+}
+
+// EventDedupTTLDuration returns how long the latest event time and message
+// IDs of a conversation are remembered after its last event, falling back to
+// the default when unset
+func (c *Config) EventDedupTTLDuration() time.Duration {
+	if c.EventDedupTTL <= 0 {
+		return constants.DefaultEventDedupTTLSeconds * time.Second
+	}
+	return time.Duration(c.EventDedupTTL) * time.Second
+}
+
+func (c *Config) WebhookTimeout() time.Duration {
+	return time.Duration(c.WebhookTimeoutMS) * time.Millisecond
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -75,6 +203,17 @@ func getEnvInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+// getEnvList splits a comma-separated variable, skipping empty items
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -23,11 +23,65 @@ const (
 	// DefaultLeaderElectionTTLSeconds - Default leader election TTL in seconds
 	DefaultLeaderElectionTTLSeconds = 10
 
-	// DefaultLeaderElectionIntervalSeconds - Default leader election check interval
-	DefaultLeaderElectionIntervalSeconds = 5
+	// LeaderRenewDivisor - The election runs every TTL / LeaderRenewDivisor, so
+	// a leader gets several chances to renew before its lock expires
+	LeaderRenewDivisor = 3
+
+	// LeaderRenewJitter - Fraction of the election interval each pod adds or
+	// removes at random, so standbys don't all retry at once
+	LeaderRenewJitter = 0.1
 
 	// DefaultCleanupIntervalSeconds - Default cleanup interval for expired conversations
 	DefaultCleanupIntervalSeconds = 60
+
+	// DefaultDetectionBatchSize - Default number of due conversations fetched per scan
+	DefaultDetectionBatchSize = 1000
+
+	// DefaultShardCount - Default number of detection shards
+	DefaultShardCount = 1
+
+	// DefaultHistoryLimit - Default number of events kept per conversation history
+	DefaultHistoryLimit = 100
+
+	// DefaultHistoryTTLSeconds - Default time a conversation history is kept after it closes
+	DefaultHistoryTTLSeconds = 7 * 24 * 60 * 60
+
+	// DefaultEventDedupTTLSeconds - Default time the latest event time and message IDs of a conversation are remembered
+	DefaultEventDedupTTLSeconds = 24 * 60 * 60
+
+	// EventDedupMessages - Message IDs remembered per conversation for deduplication
+	EventDedupMessages = 100
+
+	// MaxBatchEvents - Most events accepted in one batch
+	MaxBatchEvents = 1000
+
+	// DefaultIngestBatchSize - Default number of input stream entries read and applied at a time
+	DefaultIngestBatchSize = 100
+
+	// CleanupBatchSize - Expired conversations removed per cleanup script call
+	CleanupBatchSize = 1000
+)
+
+// Request validation limits
+const (
+	// MaxIDLength - Longest conversation, agent, customer, message or actor ID accepted
+	MaxIDLength = 128
+
+	// MaxAttributeLength - Longest policy, tenant, channel or priority accepted
+	MaxAttributeLength = 64
+
+	// MaxRequestBodyBytes - Largest HTTP request body accepted, but for batches
+	MaxRequestBodyBytes = 64 << 10
+
+	// MaxBatchBodyBytes - Largest batch request body accepted
+	MaxBatchBodyBytes = 2 << 20
+
+	// MaxEventClockSkew - How far in the future an event timestamp may be, to allow for clock skew
+	MaxEventClockSkew = 5 * time.Minute
+
+	// ConversationMaxAge - How long a conversation is kept waiting before cleanup drops it;
+	// events older than that are rejected
+	ConversationMaxAge = 24 * time.Hour
 )
 
 // Timeout levels as constants for better code readability
@@ -55,7 +109,6 @@ const (
 	EnvTimeoutLevel2Multiplier = "TIMEOUT_LEVEL_2_MULTIPLIER"
 	EnvTimeoutLevel3Multiplier = "TIMEOUT_LEVEL_3_MULTIPLIER"
 	EnvLeaderElectionTTL       = "LEADER_ELECTION_TTL_SECONDS"
-	EnvLeaderElectionInterval  = "LEADER_ELECTION_INTERVAL_SECONDS"
 	EnvCleanupInterval         = "CLEANUP_INTERVAL_SECONDS"
 )
 
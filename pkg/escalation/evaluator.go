@@ -0,0 +1,173 @@
+package escalation
+
+import (
+	"fmt"
+	"time"
+
+	"redis-timeout-tracking-poc/pkg/calendar"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+// Evaluator decides which escalation level a waiting conversation has reached.
+// Phase 1 and Phase 2 detection both go through it.
+type Evaluator struct {
+	policies        map[string]*Policy
+	rules           []Rule
+	defaultPolicy   *Policy
+	calendars       map[string]*calendar.Calendar
+	tenantCalendars map[string]string
+	defaultCalendar string
+	baseIntervalMS  int64
+}
+
+// NewEvaluator validates set against the base interval and returns an
+// evaluator for it
+func NewEvaluator(set *PolicySet, baseIntervalMS int64) (*Evaluator, error) {
+	if err := set.Validate(baseIntervalMS); err != nil {
+		return nil, err
+	}
+
+	e := &Evaluator{
+		policies:        make(map[string]*Policy, len(set.Policies)),
+		rules:           set.Rules,
+		calendars:       make(map[string]*calendar.Calendar, len(set.Calendars)),
+		tenantCalendars: set.TenantCalendars,
+		defaultCalendar: set.DefaultCalendar,
+		baseIntervalMS:  baseIntervalMS,
+	}
+	for i := range set.Policies {
+		policy := &set.Policies[i]
+		e.policies[policy.Name] = policy
+	}
+	e.defaultPolicy = e.policies[set.DefaultPolicy]
+	for i := range set.Calendars {
+		cal := &set.Calendars[i]
+		e.calendars[cal.Name] = cal
+	}
+
+	return e, nil
+}
+
+// Load builds the evaluator for a configuration: the policy file when one is
+// configured, otherwise the multiplier ladder from the environment
+func Load(cfg *config.Config) (*Evaluator, error) {
+	set := DefaultPolicySet(cfg.TimeoutLevelMultipliers)
+	if cfg.EscalationPolicyFile != "" {
+		loaded, err := LoadPolicySet(cfg.EscalationPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		set = loaded
+	}
+
+	evaluator, err := NewEvaluator(set, cfg.TimeoutIntervalMS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid escalation policies: %w", err)
+	}
+	return evaluator, nil
+}
+
+// Policy returns the named policy, or the default policy when name is empty
+// or unknown
+func (e *Evaluator) Policy(name string) *Policy {
+	if policy, ok := e.policies[name]; ok {
+		return policy
+	}
+	return e.defaultPolicy
+}
+
+// HasPolicy reports whether a policy with the given name is defined
+func (e *Evaluator) HasPolicy(name string) bool {
+	_, ok := e.policies[name]
+	return ok
+}
+
+// Select returns the policy for a conversation: the one it names, else the
+// first rule that matches it, else the default policy
+func (e *Evaluator) Select(attrs models.ConversationAttributes) *Policy {
+	if policy, ok := e.policies[attrs.Policy]; ok {
+		return policy
+	}
+	for _, rule := range e.rules {
+		if rule.Match.matches(attrs) {
+			return e.policies[rule.Policy]
+		}
+	}
+	return e.defaultPolicy
+}
+
+// ThresholdsMS returns the wait (ms) after which each level of the policy
+// fires, in level order
+func (e *Evaluator) ThresholdsMS(policy *Policy) []int64 {
+	thresholds := make([]int64, len(policy.Levels))
+	for i, level := range policy.Levels {
+		thresholds[i] = level.ThresholdMS(e.baseIntervalMS)
+	}
+	return thresholds
+}
+
+// Calendar returns the calendar a policy measures a conversation's wait
+// against, or nil when the policy counts wall-clock time
+func (e *Evaluator) Calendar(policy *Policy, attrs models.ConversationAttributes) *calendar.Calendar {
+	if !policy.BusinessHours {
+		return nil
+	}
+	if name, ok := e.tenantCalendars[attrs.TenantID]; ok {
+		return e.calendars[name]
+	}
+	return e.calendars[e.defaultCalendar]
+}
+
+// WaitMS returns how long (ms) a conversation has waited between startMS and
+// nowMS as the policy counts it: working time for business-hours policies,
+// wall-clock time otherwise
+func (e *Evaluator) WaitMS(policy *Policy, attrs models.ConversationAttributes, startMS, nowMS int64) int64 {
+	cal := e.Calendar(policy, attrs)
+	if cal == nil {
+		return nowMS - startMS
+	}
+	return cal.Elapsed(time.UnixMilli(startMS), time.UnixMilli(nowMS)).Milliseconds()
+}
+
+// Evaluate returns the highest level of the policy whose threshold waitMS has
+// reached, if it is above currentLevel. Levels skipped in between are not
+// reported; like the original ladder, a conversation found late jumps straight
+// to the level it has reached.
+//
+// A level fires at its threshold, where the original ladder waited for the
+// wait to pass it: a level fires exactly at the deadline NextDeadlineMS
+// schedules, so the detector never finds a conversation in the due range that
+// isn't due yet.
+func (e *Evaluator) Evaluate(policy *Policy, waitMS int64, currentLevel int) (int, bool) {
+	for level := len(policy.Levels); level > currentLevel; level-- {
+		if waitMS >= policy.Levels[level-1].ThresholdMS(e.baseIntervalMS) {
+			return level, true
+		}
+	}
+	return 0, false
+}
+
+// NextDeadlineMS returns when (unix ms) the level after level fires for a
+// conversation whose agent message was sent at agentTimeMS, or false when
+// level is the last of the policy
+func (e *Evaluator) NextDeadlineMS(policy *Policy, attrs models.ConversationAttributes, agentTimeMS int64, level int) (int64, bool) {
+	if level < 0 || level >= len(policy.Levels) {
+		return 0, false
+	}
+
+	threshold := policy.Levels[level].ThresholdMS(e.baseIntervalMS)
+	cal := e.Calendar(policy, attrs)
+	if cal == nil {
+		return agentTimeMS + threshold, true
+	}
+	return cal.Add(time.UnixMilli(agentTimeMS), time.Duration(threshold)*time.Millisecond).UnixMilli(), true
+}
+
+// Level returns the definition of a 1-based level of the policy
+func (e *Evaluator) Level(policy *Policy, level int) Level {
+	if level < 1 || level > len(policy.Levels) {
+		return Level{Name: fmt.Sprintf("level%d", level)}
+	}
+	return policy.Levels[level-1]
+}
@@ -0,0 +1,252 @@
+package escalation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"redis-timeout-tracking-poc/pkg/calendar"
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+// DefaultPolicyName is the policy used when a policy file doesn't name one
+const DefaultPolicyName = "default"
+
+// Duration is a time.Duration that reads as a Go duration string ("90s",
+// "15m") in policy files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"90s\": %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("duration must be a string like \"90s\": %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) parse(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Level is one step of an escalation policy. Its threshold is either absolute
+// (After) or relative to the base timeout interval (Multiplier).
+type Level struct {
+	Name       string   `json:"name" yaml:"name"`
+	After      Duration `json:"after,omitempty" yaml:"after,omitempty"`
+	Multiplier float64  `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	Action     string   `json:"action" yaml:"action"`
+}
+
+// ThresholdMS returns how long (ms) a conversation must wait before this level
+// fires
+func (l Level) ThresholdMS(baseIntervalMS int64) int64 {
+	if l.After > 0 {
+		return time.Duration(l.After).Milliseconds()
+	}
+	return int64(l.Multiplier * float64(baseIntervalMS))
+}
+
+// Policy is an ordered escalation ladder. Level numbers are 1-based positions
+// in Levels. With BusinessHours set, thresholds count only working time in the
+// conversation's calendar instead of wall-clock time.
+type Policy struct {
+	Name          string  `json:"name" yaml:"name"`
+	BusinessHours bool    `json:"business_hours,omitempty" yaml:"business_hours,omitempty"`
+	Levels        []Level `json:"levels" yaml:"levels"`
+}
+
+// Match selects conversations by their attributes. Empty fields match
+// anything.
+type Match struct {
+	TenantID string `json:"tenant_id,omitempty" yaml:"tenant_id,omitempty"`
+	Channel  string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Priority string `json:"priority,omitempty" yaml:"priority,omitempty"`
+}
+
+func (m Match) matches(attrs models.ConversationAttributes) bool {
+	return (m.TenantID == "" || m.TenantID == attrs.TenantID) &&
+		(m.Channel == "" || m.Channel == attrs.Channel) &&
+		(m.Priority == "" || m.Priority == attrs.Priority)
+}
+
+// Rule assigns a policy to the conversations it matches
+type Rule struct {
+	Match  Match  `json:"match" yaml:"match"`
+	Policy string `json:"policy" yaml:"policy"`
+}
+
+// PolicySet is the content of an escalation policy file. Rules are tried in
+// order for conversations that don't name a policy; the default policy
+// applies when none matches. Business-hours policies use the calendar mapped
+// to the conversation's tenant, or the default calendar.
+type PolicySet struct {
+	DefaultPolicy   string              `json:"default_policy" yaml:"default_policy"`
+	Policies        []Policy            `json:"policies" yaml:"policies"`
+	Rules           []Rule              `json:"rules,omitempty" yaml:"rules,omitempty"`
+	DefaultCalendar string              `json:"default_calendar,omitempty" yaml:"default_calendar,omitempty"`
+	TenantCalendars map[string]string   `json:"tenant_calendars,omitempty" yaml:"tenant_calendars,omitempty"`
+	Calendars       []calendar.Calendar `json:"calendars,omitempty" yaml:"calendars,omitempty"`
+}
+
+// DefaultPolicySet returns a single policy with one relative level per
+// multiplier, named level1, level2, ...
+func DefaultPolicySet(multipliers []int64) *PolicySet {
+	policy := Policy{Name: DefaultPolicyName}
+	for i, multiplier := range multipliers {
+		policy.Levels = append(policy.Levels, Level{
+			Name:       fmt.Sprintf("level%d", i+1),
+			Multiplier: float64(multiplier),
+			Action:     "notify",
+		})
+	}
+
+	return &PolicySet{
+		DefaultPolicy: DefaultPolicyName,
+		Policies:      []Policy{policy},
+	}
+}
+
+// LoadPolicySet reads a policy file. Files ending in .json are parsed as JSON,
+// anything else as YAML.
+func LoadPolicySet(path string) (*PolicySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read escalation policy file: %w", err)
+	}
+
+	set := &PolicySet{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, set)
+	} else {
+		err = yaml.Unmarshal(data, set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse escalation policy file %s: %w", path, err)
+	}
+
+	if set.DefaultPolicy == "" {
+		set.DefaultPolicy = DefaultPolicyName
+	}
+
+	return set, nil
+}
+
+// Validate checks that every policy is usable with the given base interval:
+// named, unique, at least one level, and strictly increasing thresholds. It
+// also checks the calendars and prepares them for use.
+func (s *PolicySet) Validate(baseIntervalMS int64) error {
+	if len(s.Policies) == 0 {
+		return fmt.Errorf("no escalation policies defined")
+	}
+
+	if err := s.validateCalendars(); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(s.Policies))
+	for _, policy := range s.Policies {
+		if policy.Name == "" {
+			return fmt.Errorf("escalation policy without a name")
+		}
+		if seen[policy.Name] {
+			return fmt.Errorf("duplicate escalation policy %q", policy.Name)
+		}
+		seen[policy.Name] = true
+
+		if err := policy.validate(baseIntervalMS); err != nil {
+			return fmt.Errorf("escalation policy %q: %w", policy.Name, err)
+		}
+		if policy.BusinessHours && s.DefaultCalendar == "" {
+			return fmt.Errorf("escalation policy %q uses business hours but no default_calendar is set", policy.Name)
+		}
+	}
+
+	if !seen[s.DefaultPolicy] {
+		return fmt.Errorf("default escalation policy %q is not defined", s.DefaultPolicy)
+	}
+
+	for i, rule := range s.Rules {
+		if !seen[rule.Policy] {
+			return fmt.Errorf("rule %d refers to undefined escalation policy %q", i+1, rule.Policy)
+		}
+	}
+
+	return nil
+}
+
+func (s *PolicySet) validateCalendars() error {
+	seen := make(map[string]bool, len(s.Calendars))
+	for i := range s.Calendars {
+		cal := &s.Calendars[i]
+		if err := cal.Validate(); err != nil {
+			return err
+		}
+		if seen[cal.Name] {
+			return fmt.Errorf("duplicate calendar %q", cal.Name)
+		}
+		seen[cal.Name] = true
+	}
+
+	if s.DefaultCalendar != "" && !seen[s.DefaultCalendar] {
+		return fmt.Errorf("default calendar %q is not defined", s.DefaultCalendar)
+	}
+	for tenant, name := range s.TenantCalendars {
+		if !seen[name] {
+			return fmt.Errorf("tenant %q refers to undefined calendar %q", tenant, name)
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) validate(baseIntervalMS int64) error {
+	if len(p.Levels) == 0 {
+		return fmt.Errorf("no levels defined")
+	}
+
+	var previous int64
+	for i, level := range p.Levels {
+		if level.Name == "" {
+			return fmt.Errorf("level %d has no name", i+1)
+		}
+		if level.Action == "" {
+			return fmt.Errorf("level %q has no action", level.Name)
+		}
+		if level.After < 0 || level.Multiplier < 0 {
+			return fmt.Errorf("level %q has a negative threshold", level.Name)
+		}
+		if (level.After > 0) == (level.Multiplier > 0) {
+			return fmt.Errorf("level %q must set exactly one of after or multiplier", level.Name)
+		}
+
+		threshold := level.ThresholdMS(baseIntervalMS)
+		if threshold <= previous {
+			return fmt.Errorf("level %q threshold %dms does not come after the previous level", level.Name, threshold)
+		}
+		previous = threshold
+	}
+
+	return nil
+}
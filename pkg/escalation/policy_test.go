@@ -0,0 +1,293 @@
+package escalation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/calendar"
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+const testPolicyYAML = `
+default_policy: standard
+policies:
+  - name: standard
+    levels:
+      - name: reminder
+        multiplier: 1
+        action: notify_agent
+      - name: supervisor
+        multiplier: 2
+        action: notify_supervisor
+      - name: reassign
+        after: 5m
+        action: reassign
+  - name: vip
+    levels:
+      - name: reminder
+        after: 30s
+        action: notify_agent
+rules:
+  - match: {tenant_id: acme, priority: high}
+    policy: vip
+  - match: {channel: chat}
+    policy: vip
+`
+
+const testPolicyJSON = `{
+  "default_policy": "standard",
+  "policies": [
+    {"name": "standard", "levels": [
+      {"name": "reminder", "multiplier": 1, "action": "notify_agent"},
+      {"name": "supervisor", "after": "90s", "action": "notify_supervisor"}
+    ]}
+  ]
+}`
+
+func writePolicyFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadPolicySet_YAML(t *testing.T) {
+	set, err := LoadPolicySet(writePolicyFile(t, "policies.yaml", testPolicyYAML))
+	require.NoError(t, err)
+	require.NoError(t, set.Validate(30000))
+
+	assert.Equal(t, "standard", set.DefaultPolicy)
+	require.Len(t, set.Policies, 2)
+	assert.Equal(t, Duration(5*time.Minute), set.Policies[0].Levels[2].After)
+	assert.Equal(t, "reassign", set.Policies[0].Levels[2].Action)
+}
+
+func TestLoadPolicySet_JSON(t *testing.T) {
+	set, err := LoadPolicySet(writePolicyFile(t, "policies.json", testPolicyJSON))
+	require.NoError(t, err)
+	require.NoError(t, set.Validate(30000))
+
+	assert.Equal(t, int64(90000), set.Policies[0].Levels[1].ThresholdMS(30000))
+}
+
+func TestPolicySet_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		set  PolicySet
+	}{
+		{"no policies", PolicySet{DefaultPolicy: "standard"}},
+		{"unknown default", PolicySet{DefaultPolicy: "missing", Policies: []Policy{
+			{Name: "standard", Levels: []Level{{Name: "l1", Multiplier: 1, Action: "notify"}}},
+		}}},
+		{"no levels", PolicySet{DefaultPolicy: "standard", Policies: []Policy{{Name: "standard"}}}},
+		{"both thresholds", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: []Level{{Name: "l1", Multiplier: 1, After: Duration(time.Second), Action: "notify"}}},
+		}}},
+		{"no action", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: []Level{{Name: "l1", Multiplier: 1}}},
+		}}},
+		{"thresholds out of order", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: []Level{
+				{Name: "l1", After: Duration(time.Minute), Action: "notify"},
+				{Name: "l2", Multiplier: 1, Action: "notify"},
+			}},
+		}}},
+		{"duplicate names", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: []Level{{Name: "l1", Multiplier: 1, Action: "notify"}}},
+			{Name: "standard", Levels: []Level{{Name: "l1", Multiplier: 1, Action: "notify"}}},
+		}}},
+		{"rule with unknown policy", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: []Level{{Name: "l1", Multiplier: 1, Action: "notify"}}},
+		}, Rules: []Rule{{Match: Match{Channel: "chat"}, Policy: "missing"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.set.Validate(30000))
+		})
+	}
+}
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	set, err := LoadPolicySet(writePolicyFile(t, "policies.yaml", testPolicyYAML))
+	require.NoError(t, err)
+
+	evaluator, err := NewEvaluator(set, 30000)
+	require.NoError(t, err)
+
+	standard := evaluator.Policy("")
+	assert.Equal(t, "standard", standard.Name)
+	assert.Equal(t, []int64{30000, 60000, 300000}, evaluator.ThresholdsMS(standard))
+
+	level, due := evaluator.Evaluate(standard, 29000, 0)
+	assert.False(t, due)
+
+	// Fires at the threshold, the deadline NextDeadlineMS schedules
+	_, due = evaluator.Evaluate(standard, 29999, 0)
+	assert.False(t, due)
+	level, due = evaluator.Evaluate(standard, 30000, 0)
+	assert.True(t, due)
+	assert.Equal(t, 1, level)
+	deadline, ok := evaluator.NextDeadlineMS(standard, models.ConversationAttributes{}, 1000, 0)
+	require.True(t, ok)
+	assert.Equal(t, int64(31000), deadline)
+
+	level, due = evaluator.Evaluate(standard, 31000, 0)
+	assert.True(t, due)
+	assert.Equal(t, 1, level)
+
+	// Found late: jumps to the level reached
+	level, due = evaluator.Evaluate(standard, 301000, 0)
+	assert.True(t, due)
+	assert.Equal(t, 3, level)
+
+	// Already notified at that level
+	_, due = evaluator.Evaluate(standard, 61000, 2)
+	assert.False(t, due)
+
+	vip := evaluator.Policy("vip")
+	level, due = evaluator.Evaluate(vip, 31000, 0)
+	assert.True(t, due)
+	assert.Equal(t, 1, level)
+	assert.Equal(t, "notify_agent", evaluator.Level(vip, level).Action)
+}
+
+func TestEvaluator_Select(t *testing.T) {
+	set, err := LoadPolicySet(writePolicyFile(t, "policies.yaml", testPolicyYAML))
+	require.NoError(t, err)
+
+	evaluator, err := NewEvaluator(set, 30000)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		attrs  models.ConversationAttributes
+		policy string
+	}{
+		{"no attributes", models.ConversationAttributes{}, "standard"},
+		{"all rule fields match", models.ConversationAttributes{TenantID: "acme", Priority: "high"}, "vip"},
+		{"partial rule match", models.ConversationAttributes{TenantID: "acme", Priority: "low"}, "standard"},
+		{"later rule matches", models.ConversationAttributes{TenantID: "other", Channel: "chat"}, "vip"},
+		{"explicit policy wins", models.ConversationAttributes{Policy: "standard", Channel: "chat"}, "standard"},
+		{"unknown explicit policy falls through", models.ConversationAttributes{Policy: "missing", Channel: "chat"}, "vip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.policy, evaluator.Select(tt.attrs).Name)
+		})
+	}
+}
+
+const testBusinessHoursYAML = `
+default_policy: office
+default_calendar: london
+tenant_calendars:
+  acme: new_york
+calendars:
+  - name: london
+    timezone: Europe/London
+    hours:
+      monday: ["09:00-17:00"]
+      tuesday: ["09:00-17:00"]
+      wednesday: ["09:00-17:00"]
+      thursday: ["09:00-17:00"]
+      friday: ["09:00-17:00"]
+  - name: new_york
+    timezone: America/New_York
+    hours:
+      monday: ["09:00-17:00"]
+      tuesday: ["09:00-17:00"]
+      wednesday: ["09:00-17:00"]
+      thursday: ["09:00-17:00"]
+      friday: ["09:00-17:00"]
+    holidays: ["2026-10-19"]
+policies:
+  - name: office
+    business_hours: true
+    levels:
+      - name: reminder
+        after: 30m
+        action: notify_agent
+  - name: always
+    levels:
+      - name: reminder
+        after: 30m
+        action: notify_agent
+`
+
+func TestEvaluator_BusinessHours(t *testing.T) {
+	set, err := LoadPolicySet(writePolicyFile(t, "policies.yaml", testBusinessHoursYAML))
+	require.NoError(t, err)
+
+	evaluator, err := NewEvaluator(set, 30000)
+	require.NoError(t, err)
+
+	office := evaluator.Policy("office")
+	always := evaluator.Policy("always")
+
+	// Friday 16:50 in London: ten working minutes left in the week
+	sent := time.Date(2026, 10, 16, 16, 50, 0, 0, time.UTC).Add(-time.Hour).UnixMilli()
+	monday := time.Date(2026, 10, 19, 9, 20, 0, 0, time.UTC).Add(-time.Hour).UnixMilli()
+
+	deadline, ok := evaluator.NextDeadlineMS(office, models.ConversationAttributes{}, sent, 0)
+	require.True(t, ok)
+	assert.Equal(t, monday, deadline)
+
+	level, due := evaluator.Evaluate(office, evaluator.WaitMS(office, models.ConversationAttributes{}, sent, monday-1), 0)
+	assert.False(t, due)
+	level, due = evaluator.Evaluate(office, evaluator.WaitMS(office, models.ConversationAttributes{}, sent, monday), 0)
+	assert.True(t, due)
+	assert.Equal(t, 1, level)
+
+	// acme works New York hours and has Monday off: Friday 16:50 there
+	// escalates on Tuesday
+	acme := models.ConversationAttributes{TenantID: "acme"}
+	acmeSent := time.Date(2026, 10, 16, 16, 50, 0, 0, time.UTC).Add(4 * time.Hour).UnixMilli()
+	deadline, ok = evaluator.NextDeadlineMS(office, acme, acmeSent, 0)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 10, 20, 9, 20, 0, 0, time.UTC).Add(4*time.Hour).UnixMilli(), deadline)
+
+	// Wall-clock policies ignore calendars
+	deadline, ok = evaluator.NextDeadlineMS(always, acme, sent, 0)
+	require.True(t, ok)
+	assert.Equal(t, sent+30*time.Minute.Milliseconds(), deadline)
+
+	// Nothing after the last level
+	_, ok = evaluator.NextDeadlineMS(office, acme, sent, 1)
+	assert.False(t, ok)
+}
+
+func TestPolicySet_ValidateCalendars(t *testing.T) {
+	levels := []Level{{Name: "l1", Multiplier: 1, Action: "notify"}}
+	weekdays := map[string][]string{"monday": {"09:00-17:00"}}
+
+	tests := []struct {
+		name string
+		set  PolicySet
+	}{
+		{"business hours without default calendar", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", BusinessHours: true, Levels: levels},
+		}}},
+		{"unknown default calendar", PolicySet{DefaultPolicy: "standard", DefaultCalendar: "missing", Policies: []Policy{
+			{Name: "standard", Levels: levels},
+		}}},
+		{"tenant with unknown calendar", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: levels},
+		}, Calendars: []calendar.Calendar{{Name: "office", Timezone: "UTC", Hours: weekdays}},
+			TenantCalendars: map[string]string{"acme": "missing"}}},
+		{"invalid calendar", PolicySet{DefaultPolicy: "standard", Policies: []Policy{
+			{Name: "standard", Levels: levels},
+		}, Calendars: []calendar.Calendar{{Name: "office", Timezone: "UTC"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.set.Validate(30000))
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	timeoutv1 "redis-timeout-tracking-poc/pkg/api/timeout/v1"
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/escalation"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/phase1"
+	"redis-timeout-tracking-poc/pkg/validation"
+)
+
+// Server implements the TimeoutTracking gRPC service on top of the timeout
+// manager both phases share. Events are validated as the HTTP API validates
+// them, and invalid ones fail with InvalidArgument.
+type Server struct {
+	timeoutv1.UnimplementedTimeoutTrackingServer
+
+	rdb            redis.UniversalClient
+	timeoutManager *phase1.TimeoutManager
+	evaluator      *escalation.Evaluator
+	logger         *logrus.Logger
+	clock          clock.Clock
+}
+
+func NewServer(rdb redis.UniversalClient, timeoutManager *phase1.TimeoutManager, evaluator *escalation.Evaluator, logger *logrus.Logger, clock clock.Clock) *Server {
+	return &Server{
+		rdb:            rdb,
+		timeoutManager: timeoutManager,
+		evaluator:      evaluator,
+		logger:         logger,
+		clock:          clock,
+	}
+}
+
+// NewGRPCServer creates a gRPC server serving the TimeoutTracking service
+func NewGRPCServer(server *Server) *grpc.Server {
+	grpcServer := grpc.NewServer()
+	timeoutv1.RegisterTimeoutTrackingServer(grpcServer, server)
+	return grpcServer
+}
+
+// Serve serves grpcServer on port in the background. The port is bound
+// before it returns, so a port already in use is reported to the caller.
+func Serve(grpcServer *grpc.Server, port string, logger *logrus.Logger) error {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		logger.WithField("port", port).Info("Starting gRPC server")
+		if err := grpcServer.Serve(listener); err != nil {
+			logger.WithError(err).Fatal("gRPC server failed")
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops grpcServer gracefully, and forcefully once ctx is done:
+// escalation watchers stay connected until they cancel
+func Shutdown(ctx context.Context, grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+	}
+}
+
+func (s *Server) TrackAgentMessage(ctx context.Context, req *timeoutv1.TrackAgentMessageRequest) (*timeoutv1.EventResponse, error) {
+	event := models.ConversationEvent{
+		Type:           models.EventAgentMessage,
+		ConversationID: req.GetConversationId(),
+		AgentID:        req.GetAgentId(),
+		MessageID:      req.GetMessageId(),
+		Timestamp:      s.timestamp(req.GetTimestamp()),
+		Policy:         req.GetPolicy(),
+		TenantID:       req.GetTenantId(),
+		Channel:        req.GetChannel(),
+		Priority:       req.GetPriority(),
+	}
+	if err := validation.Event(s.clock.Now(), event); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if event.Policy != "" && !s.evaluator.HasPolicy(event.Policy) {
+		return nil, status.Error(codes.InvalidArgument, "unknown escalation policy")
+	}
+	agentMsg := event.AgentMessage()
+
+	// Stale and duplicate events are acknowledged as no-ops
+	err := s.timeoutManager.TrackAgentMessage(ctx, agentMsg)
+	if err != nil && !phase1.IsIgnoredEvent(err) {
+		s.logger.WithError(err).WithField("conversation_id", agentMsg.ConversationID).Error("Failed to track agent message")
+		return nil, status.Error(codes.Internal, "failed to track agent message")
+	}
+
+	return &timeoutv1.EventResponse{
+		ConversationId: agentMsg.ConversationID,
+		Result:         eventResult(err),
+		Policy:         s.evaluator.Select(agentMsg.Attributes()).Name,
+	}, nil
+}
+
+func (s *Server) ClearTimeout(ctx context.Context, req *timeoutv1.ClearTimeoutRequest) (*timeoutv1.EventResponse, error) {
+	event := models.ConversationEvent{
+		Type:           models.EventCustomerResponse,
+		ConversationID: req.GetConversationId(),
+		CustomerID:     req.GetCustomerId(),
+		MessageID:      req.GetMessageId(),
+		Timestamp:      s.timestamp(req.GetTimestamp()),
+	}
+	if err := validation.Event(s.clock.Now(), event); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	customerResp := event.CustomerResponse()
+
+	// Stale and duplicate events are acknowledged as no-ops
+	err := s.timeoutManager.ClearTimeout(ctx, customerResp)
+	if err != nil && !phase1.IsIgnoredEvent(err) {
+		s.logger.WithError(err).WithField("conversation_id", customerResp.ConversationID).Error("Failed to clear timeout")
+		return nil, status.Error(codes.Internal, "failed to clear timeout")
+	}
+
+	return &timeoutv1.EventResponse{
+		ConversationId: customerResp.ConversationID,
+		Result:         eventResult(err),
+	}, nil
+}
+
+func (s *Server) GetConversation(ctx context.Context, req *timeoutv1.GetConversationRequest) (*timeoutv1.Conversation, error) {
+	if req.GetConversationId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing conversation ID")
+	}
+
+	conversation, err := s.timeoutManager.GetConversation(ctx, req.GetConversationId())
+	if err != nil {
+		s.logger.WithError(err).WithField("conversation_id", req.GetConversationId()).Error("Failed to get conversation")
+		return nil, status.Error(codes.Internal, "failed to get conversation")
+	}
+
+	return &timeoutv1.Conversation{
+		ConversationId:   conversation.ConversationID,
+		Tracked:          conversation.Tracked,
+		Status:           conversation.Status,
+		AgentMessageTime: timestampProto(conversation.AgentMessageTime),
+		CurrentLevel:     int32(conversation.Level),
+		LevelName:        conversation.LevelName,
+		NextLevel:        conversation.NextLevel,
+		NextDeadline:     timestampProto(conversation.NextDeadline),
+		Overdue:          conversation.Overdue,
+		PausedAt:         timestampProto(conversation.PausedAt),
+		SnoozedUntil:     timestampProto(conversation.SnoozedUntil),
+		ForcedLevel:      int32(conversation.ForcedLevel),
+		Policy:           conversation.Policy,
+		Shard:            int32(conversation.Shard),
+		Attributes: &timeoutv1.ConversationAttributes{
+			Policy:   conversation.Attributes.Policy,
+			TenantId: conversation.Attributes.TenantID,
+			Channel:  conversation.Attributes.Channel,
+			Priority: conversation.Attributes.Priority,
+		},
+	}, nil
+}
+
+func (s *Server) BatchTrackAgentMessages(ctx context.Context, req *timeoutv1.BatchTrackAgentMessagesRequest) (*timeoutv1.BatchResponse, error) {
+	events := make([]models.ConversationEvent, len(req.GetMessages()))
+	for i, message := range req.GetMessages() {
+		events[i] = models.ConversationEvent{
+			Type:           models.EventAgentMessage,
+			ConversationID: message.GetConversationId(),
+			AgentID:        message.GetAgentId(),
+			MessageID:      message.GetMessageId(),
+			Timestamp:      s.timestamp(message.GetTimestamp()),
+			Policy:         message.GetPolicy(),
+			TenantID:       message.GetTenantId(),
+			Channel:        message.GetChannel(),
+			Priority:       message.GetPriority(),
+		}
+	}
+	return s.applyEvents(ctx, events)
+}
+
+func (s *Server) BatchClearTimeouts(ctx context.Context, req *timeoutv1.BatchClearTimeoutsRequest) (*timeoutv1.BatchResponse, error) {
+	events := make([]models.ConversationEvent, len(req.GetResponses()))
+	for i, response := range req.GetResponses() {
+		events[i] = models.ConversationEvent{
+			Type:           models.EventCustomerResponse,
+			ConversationID: response.GetConversationId(),
+			CustomerID:     response.GetCustomerId(),
+			MessageID:      response.GetMessageId(),
+			Timestamp:      s.timestamp(response.GetTimestamp()),
+		}
+	}
+	return s.applyEvents(ctx, events)
+}
+
+// applyEvents applies a batch as POST /conversations/events:batch does
+func (s *Server) applyEvents(ctx context.Context, events []models.ConversationEvent) (*timeoutv1.BatchResponse, error) {
+	if len(events) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no events")
+	}
+	if len(events) > constants.MaxBatchEvents {
+		return nil, status.Errorf(codes.InvalidArgument, "too many events: at most %d per batch", constants.MaxBatchEvents)
+	}
+
+	// Only the valid events are applied; indexes[j] is the event valid[j]
+	// comes from
+	errs := make([]error, len(events))
+	valid := make([]models.ConversationEvent, 0, len(events))
+	indexes := make([]int, 0, len(events))
+	for i, event := range events {
+		if err := validation.Event(s.clock.Now(), event); err != nil {
+			errs[i] = fmt.Errorf("%w: %v", phase1.ErrInvalidEvent, err)
+			continue
+		}
+		valid = append(valid, event)
+		indexes = append(indexes, i)
+	}
+	if len(valid) > 0 {
+		for j, err := range s.timeoutManager.ApplyEvents(ctx, valid) {
+			errs[indexes[j]] = err
+		}
+	}
+	results := phase1.BatchResults(events, errs)
+
+	response := &timeoutv1.BatchResponse{Results: make([]*timeoutv1.BatchResult, len(results))}
+	for i, result := range results {
+		if !result.Success {
+			response.Failed++
+		}
+		response.Results[i] = &timeoutv1.BatchResult{
+			Index:          int32(result.Index),
+			ConversationId: result.ConversationID,
+			Result:         eventResults[result.Result],
+			Error:          result.Error,
+		}
+	}
+	return response, nil
+}
+
+// WatchEscalations streams the escalations announced on
+// phase1.EscalationsChannel until the client cancels
+func (s *Server) WatchEscalations(req *timeoutv1.WatchEscalationsRequest, stream timeoutv1.TimeoutTracking_WatchEscalationsServer) error {
+	ctx := stream.Context()
+
+	conversations := make(map[string]bool, len(req.GetConversationIds()))
+	for _, id := range req.GetConversationIds() {
+		conversations[id] = true
+	}
+
+	escalations, err := phase1.WatchEscalations(ctx, s.rdb, s.logger)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to watch escalations")
+		return status.Error(codes.Unavailable, "failed to watch escalations")
+	}
+
+	for event := range escalations {
+		if len(conversations) > 0 && !conversations[event.ConversationID] {
+			continue
+		}
+		if event.Level < int(req.GetMinLevel()) {
+			continue
+		}
+		if err := stream.Send(escalationProto(event)); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	// The subscription was closed under us
+	return status.Error(codes.Unavailable, "escalation feed closed")
+}
+
+// timestamp returns the time of an event, which defaults to now
+func (s *Server) timestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return s.clock.Now()
+	}
+	return ts.AsTime()
+}
+
+var eventResults = map[string]timeoutv1.EventResult{
+	"applied":   timeoutv1.EventResult_EVENT_RESULT_APPLIED,
+	"stale":     timeoutv1.EventResult_EVENT_RESULT_STALE,
+	"duplicate": timeoutv1.EventResult_EVENT_RESULT_DUPLICATE,
+	"rejected":  timeoutv1.EventResult_EVENT_RESULT_REJECTED,
+	"failed":    timeoutv1.EventResult_EVENT_RESULT_FAILED,
+}
+
+func eventResult(err error) timeoutv1.EventResult {
+	return eventResults[phase1.EventResult(err)]
+}
+
+func timestampProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func escalationProto(event models.TimeoutEvent) *timeoutv1.Escalation {
+	return &timeoutv1.Escalation{
+		EventId:          event.EventID,
+		ConversationId:   event.ConversationID,
+		Level:            int32(event.Level),
+		LevelName:        event.LevelName,
+		Action:           event.Action,
+		Policy:           event.Policy,
+		AgentMessageTime: timestamppb.New(event.AgentMessageTime),
+		DetectedAt:       timestamppb.New(event.DetectedAt),
+		Shard:            int32(event.Shard),
+	}
+}
@@ -0,0 +1,257 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	timeoutv1 "redis-timeout-tracking-poc/pkg/api/timeout/v1"
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
+	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/phase1"
+)
+
+// setupTestClient serves the TimeoutTracking service over an in-memory
+// connection, backed by Redis test database 3
+func setupTestClient(t *testing.T) (timeoutv1.TimeoutTrackingClient, redis.UniversalClient, *clock.Manual) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+		DB:   3, // Use test database
+	})
+	ctx := context.Background()
+	require.NoError(t, rdb.Ping(ctx).Err(), "Redis should be available for testing")
+	rdb.FlushDB(ctx)
+	t.Cleanup(func() { rdb.Close() })
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 30000,
+		PodID:             "test-pod",
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	evaluator, err := escalation.NewEvaluator(escalation.DefaultPolicySet([]int64{1, 2, 3}), cfg.TimeoutIntervalMS)
+	require.NoError(t, err)
+
+	tm := phase1.NewTimeoutManager(phase1.NewRedisStore(rdb, cfg), cfg, logger, metrics, evaluator, clk)
+	grpcServer := NewGRPCServer(NewServer(rdb, tm, evaluator, logger, clk))
+
+	listener := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return timeoutv1.NewTimeoutTrackingClient(conn), rdb, clk
+}
+
+func TestServer_TrackAndClear(t *testing.T) {
+	client, _, clk := setupTestClient(t)
+	ctx := context.Background()
+
+	tracked, err := client.TrackAgentMessage(ctx, &timeoutv1.TrackAgentMessageRequest{
+		ConversationId: "conv_123",
+		AgentId:        "agent_456",
+		MessageId:      "msg_1",
+		Timestamp:      timestamppb.New(clk.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_APPLIED, tracked.GetResult())
+	assert.Equal(t, escalation.DefaultPolicyName, tracked.GetPolicy())
+
+	conversation, err := client.GetConversation(ctx, &timeoutv1.GetConversationRequest{ConversationId: "conv_123"})
+	require.NoError(t, err)
+	assert.True(t, conversation.GetTracked())
+	assert.Equal(t, models.StatusWaiting, conversation.GetStatus())
+	assert.True(t, clk.Now().Equal(conversation.GetAgentMessageTime().AsTime()))
+	assert.NotNil(t, conversation.GetNextDeadline())
+
+	// Redelivered
+	duplicate, err := client.TrackAgentMessage(ctx, &timeoutv1.TrackAgentMessageRequest{
+		ConversationId: "conv_123",
+		AgentId:        "agent_456",
+		MessageId:      "msg_1",
+		Timestamp:      timestamppb.New(clk.Now()),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_DUPLICATE, duplicate.GetResult())
+
+	cleared, err := client.ClearTimeout(ctx, &timeoutv1.ClearTimeoutRequest{
+		ConversationId: "conv_123",
+		CustomerId:     "customer_789",
+		MessageId:      "msg_2",
+		Timestamp:      timestamppb.New(clk.Now().Add(time.Second)),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_APPLIED, cleared.GetResult())
+
+	conversation, err = client.GetConversation(ctx, &timeoutv1.GetConversationRequest{ConversationId: "conv_123"})
+	require.NoError(t, err)
+	assert.False(t, conversation.GetTracked())
+	assert.Nil(t, conversation.GetAgentMessageTime())
+
+	_, err = client.TrackAgentMessage(ctx, &timeoutv1.TrackAgentMessageRequest{AgentId: "agent_456"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.TrackAgentMessage(ctx, &timeoutv1.TrackAgentMessageRequest{ConversationId: "conv_123", AgentId: "agent_456", MessageId: "msg_3", Policy: "nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Batches(t *testing.T) {
+	client, _, clk := setupTestClient(t)
+	ctx := context.Background()
+
+	tracked, err := client.BatchTrackAgentMessages(ctx, &timeoutv1.BatchTrackAgentMessagesRequest{
+		Messages: []*timeoutv1.TrackAgentMessageRequest{
+			{ConversationId: "conv_1", AgentId: "agent_456", MessageId: "msg_1", Timestamp: timestamppb.New(clk.Now())},
+			{ConversationId: "conv_2", AgentId: "agent_456", MessageId: "msg_2", Timestamp: timestamppb.New(clk.Now())},
+			{ConversationId: "conv_3", AgentId: "agent_456", MessageId: "msg_5", Policy: "nope"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, tracked.GetResults(), 3)
+	assert.Equal(t, int32(1), tracked.GetFailed())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_APPLIED, tracked.GetResults()[0].GetResult())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_APPLIED, tracked.GetResults()[1].GetResult())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_REJECTED, tracked.GetResults()[2].GetResult())
+	assert.Equal(t, int32(2), tracked.GetResults()[2].GetIndex())
+	assert.NotEmpty(t, tracked.GetResults()[2].GetError())
+
+	cleared, err := client.BatchClearTimeouts(ctx, &timeoutv1.BatchClearTimeoutsRequest{
+		Responses: []*timeoutv1.ClearTimeoutRequest{
+			{ConversationId: "conv_1", CustomerId: "customer_789", MessageId: "msg_3", Timestamp: timestamppb.New(clk.Now().Add(time.Second))},
+			// Older than the agent message
+			{ConversationId: "conv_2", CustomerId: "customer_789", MessageId: "msg_4", Timestamp: timestamppb.New(clk.Now().Add(-time.Second))},
+		},
+	})
+	require.NoError(t, err)
+	assert.Zero(t, cleared.GetFailed())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_APPLIED, cleared.GetResults()[0].GetResult())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_STALE, cleared.GetResults()[1].GetResult())
+
+	conversation, err := client.GetConversation(ctx, &timeoutv1.GetConversationRequest{ConversationId: "conv_2"})
+	require.NoError(t, err)
+	assert.True(t, conversation.GetTracked())
+
+	_, err = client.BatchClearTimeouts(ctx, &timeoutv1.BatchClearTimeoutsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_RejectsInvalidEvents(t *testing.T) {
+	client, _, clk := setupTestClient(t)
+	ctx := context.Background()
+
+	// Validated as the HTTP API validates them
+	_, err := client.TrackAgentMessage(ctx, &timeoutv1.TrackAgentMessageRequest{
+		ConversationId: "conv {123}",
+		AgentId:        "agent_456",
+		MessageId:      "msg_1",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "conversation_id")
+
+	_, err = client.ClearTimeout(ctx, &timeoutv1.ClearTimeoutRequest{
+		ConversationId: "conv_123",
+		CustomerId:     "customer_789",
+		MessageId:      "msg_2",
+		Timestamp:      timestamppb.New(clk.Now().Add(-48 * time.Hour)),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), "timestamp")
+
+	tracked, err := client.BatchTrackAgentMessages(ctx, &timeoutv1.BatchTrackAgentMessagesRequest{
+		Messages: []*timeoutv1.TrackAgentMessageRequest{
+			{ConversationId: "conv_1", AgentId: "agent_456", MessageId: "msg_3"},
+			{ConversationId: "conv 2", AgentId: "agent_456", MessageId: "msg_4"},
+			{ConversationId: "conv_3", AgentId: "agent_456", MessageId: "msg_5", Timestamp: timestamppb.New(clk.Now().Add(-48 * time.Hour))},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), tracked.GetFailed())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_APPLIED, tracked.GetResults()[0].GetResult())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_REJECTED, tracked.GetResults()[1].GetResult())
+	assert.Equal(t, timeoutv1.EventResult_EVENT_RESULT_REJECTED, tracked.GetResults()[2].GetResult())
+
+	for _, id := range []string{"conv 2", "conv_3"} {
+		conversation, err := client.GetConversation(ctx, &timeoutv1.GetConversationRequest{ConversationId: id})
+		require.NoError(t, err)
+		assert.False(t, conversation.GetTracked(), id)
+	}
+}
+
+func TestServer_WatchEscalations(t *testing.T) {
+	client, rdb, clk := setupTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchEscalations(ctx, &timeoutv1.WatchEscalationsRequest{
+		ConversationIds: []string{"conv_1"},
+		MinLevel:        2,
+	})
+	require.NoError(t, err)
+
+	escalated := func(conversationID string, level int) models.TimeoutEvent {
+		agentMessageTime := clk.Now().Add(-time.Minute)
+		return models.TimeoutEvent{
+			EventID:          models.TimeoutEventID(conversationID, agentMessageTime, level),
+			ConversationID:   conversationID,
+			Level:            level,
+			Policy:           escalation.DefaultPolicyName,
+			AgentMessageTime: agentMessageTime,
+			DetectedAt:       clk.Now(),
+		}
+	}
+
+	logger := logrus.New()
+	// The stream is only subscribed once the server has handled the call;
+	// announce until the watcher gets something
+	received := make(chan *timeoutv1.Escalation, 1)
+	go func() {
+		got, err := stream.Recv()
+		if err == nil {
+			received <- got
+		}
+	}()
+
+	var got *timeoutv1.Escalation
+	for got == nil {
+		// Filtered out: another conversation, and a level too low
+		phase1.PublishEscalation(ctx, rdb, logger, escalated("conv_2", 3))
+		phase1.PublishEscalation(ctx, rdb, logger, escalated("conv_1", 1))
+		phase1.PublishEscalation(ctx, rdb, logger, escalated("conv_1", 2))
+
+		select {
+		case got = <-received:
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no escalation received")
+		}
+	}
+
+	assert.Equal(t, "conv_1", got.GetConversationId())
+	assert.Equal(t, int32(2), got.GetLevel())
+	assert.Equal(t, escalated("conv_1", 2).EventID, got.GetEventId())
+}
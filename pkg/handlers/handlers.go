@@ -2,36 +2,56 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/models"
 	"redis-timeout-tracking-poc/pkg/phase1"
+	"redis-timeout-tracking-poc/pkg/problem"
+	"redis-timeout-tracking-poc/pkg/validation"
 )
 
+// Cluster reports this pod's part in timeout detection, for the health and
+// status endpoints. Both phases' services implement it.
+type Cluster interface {
+	IsLeader() bool
+	OwnedShards() []int
+}
+
+// Handler serves the HTTP API both phases share, on top of their timeout
+// manager
 type Handler struct {
 	timeoutManager *phase1.TimeoutManager
+	evaluator      *escalation.Evaluator
+	config         *config.Config
 	logger         *logrus.Logger
-	isLeaderFunc   func() bool
+	cluster        Cluster
+	clock          clock.Clock
 }
 
-func NewHandler(timeoutManager *phase1.TimeoutManager, logger *logrus.Logger, isLeaderFunc func() bool) *Handler {
+func NewHandler(timeoutManager *phase1.TimeoutManager, evaluator *escalation.Evaluator, config *config.Config, logger *logrus.Logger, cluster Cluster, clock clock.Clock) *Handler {
 	return &Handler{
 		timeoutManager: timeoutManager,
+		evaluator:      evaluator,
+		config:         config,
 		logger:         logger,
-		isLeaderFunc:   isLeaderFunc,
+		cluster:        cluster,
+		clock:          clock,
 	}
 }
 
 func (h *Handler) AgentMessage(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	conversationID := vars["id"]
-
-	if conversationID == "" {
-		http.Error(w, "Missing conversation ID", http.StatusBadRequest)
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
 		return
 	}
 
@@ -39,34 +59,58 @@ func (h *Handler) AgentMessage(w http.ResponseWriter, r *http.Request) {
 		AgentID   string    `json:"agent_id"`
 		MessageID string    `json:"message_id"`
 		Timestamp time.Time `json:"timestamp,omitempty"`
+		Policy    string    `json:"policy,omitempty"`
+		TenantID  string    `json:"tenant_id,omitempty"`
+		Channel   string    `json:"channel,omitempty"`
+		Priority  string    `json:"priority,omitempty"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeBody(w, r, &request, constants.MaxRequestBodyBytes, false) {
 		return
 	}
 
-	if request.Timestamp.IsZero() {
-		request.Timestamp = time.Now()
-	}
-
-	agentMsg := models.AgentMessage{
+	event := models.ConversationEvent{
+		Type:           models.EventAgentMessage,
 		ConversationID: conversationID,
 		AgentID:        request.AgentID,
 		MessageID:      request.MessageID,
 		Timestamp:      request.Timestamp,
+		Policy:         request.Policy,
+		TenantID:       request.TenantID,
+		Channel:        request.Channel,
+		Priority:       request.Priority,
+	}
+	if err := validation.Event(h.clock.Now(), event); err != nil {
+		respondInvalid(w, r, err)
+		return
+	}
+
+	if request.Policy != "" && !h.evaluator.HasPolicy(request.Policy) {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeUnknownPolicy, fmt.Sprintf("Escalation policy %q is not defined", request.Policy))
+		return
 	}
 
-	if err := h.timeoutManager.TrackAgentMessage(r.Context(), agentMsg); err != nil {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = h.clock.Now()
+	}
+	agentMsg := event.AgentMessage()
+
+	// Stale and duplicate events are acknowledged as no-ops
+	err := h.timeoutManager.TrackAgentMessage(r.Context(), agentMsg)
+	if err != nil && !phase1.IsIgnoredEvent(err) {
 		h.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to track agent message")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		respondInternal(w, r)
 		return
 	}
+	result := phase1.EventResult(err)
 
 	response := map[string]interface{}{
 		"success":         true,
 		"conversation_id": conversationID,
-		"tracked_at":      request.Timestamp,
+		"tracked_at":      agentMsg.Timestamp,
+		"result":          result,
+		"applied":         result == "applied",
+		"policy":          h.evaluator.Select(agentMsg.Attributes()).Name,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -79,11 +123,8 @@ func (h *Handler) AgentMessage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) CustomerResponse(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	conversationID := vars["id"]
-
-	if conversationID == "" {
-		http.Error(w, "Missing conversation ID", http.StatusBadRequest)
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
 		return
 	}
 
@@ -93,32 +134,42 @@ func (h *Handler) CustomerResponse(w http.ResponseWriter, r *http.Request) {
 		Timestamp  time.Time `json:"timestamp,omitempty"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeBody(w, r, &request, constants.MaxRequestBodyBytes, false) {
 		return
 	}
 
-	if request.Timestamp.IsZero() {
-		request.Timestamp = time.Now()
-	}
-
-	customerResp := models.CustomerResponse{
+	event := models.ConversationEvent{
+		Type:           models.EventCustomerResponse,
 		ConversationID: conversationID,
 		CustomerID:     request.CustomerID,
 		MessageID:      request.MessageID,
 		Timestamp:      request.Timestamp,
 	}
+	if err := validation.Event(h.clock.Now(), event); err != nil {
+		respondInvalid(w, r, err)
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = h.clock.Now()
+	}
+	customerResp := event.CustomerResponse()
 
-	if err := h.timeoutManager.ClearTimeout(r.Context(), customerResp); err != nil {
+	// Stale and duplicate events are acknowledged as no-ops
+	err := h.timeoutManager.ClearTimeout(r.Context(), customerResp)
+	if err != nil && !phase1.IsIgnoredEvent(err) {
 		h.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to clear timeout")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		respondInternal(w, r)
 		return
 	}
+	result := phase1.EventResult(err)
 
 	response := map[string]interface{}{
 		"success":         true,
 		"conversation_id": conversationID,
-		"cleared_at":      request.Timestamp,
+		"cleared_at":      customerResp.Timestamp,
+		"result":          result,
+		"applied":         result == "applied",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -130,18 +181,82 @@ func (h *Handler) CustomerResponse(w http.ResponseWriter, r *http.Request) {
 	}).Debug("Cleared conversation timeout")
 }
 
+// EventsBatch applies a batch of agent messages and customer responses
+// at once. Events succeed or fail on their own; the response reports each,
+// with invalid events rejected.
+func (h *Handler) EventsBatch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Events []models.ConversationEvent `json:"events"`
+	}
+
+	if !decodeBody(w, r, &request, constants.MaxBatchBodyBytes, false) {
+		return
+	}
+
+	if len(request.Events) == 0 {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeBatchEmpty, "The batch has no events")
+		return
+	}
+	if len(request.Events) > constants.MaxBatchEvents {
+		problem.Respond(w, r, http.StatusRequestEntityTooLarge, problem.CodeBatchTooLarge, fmt.Sprintf("A batch holds at most %d events", constants.MaxBatchEvents))
+		return
+	}
+
+	// Only the valid events are applied; indexes[j] is the event valid[j]
+	// comes from
+	errs := make([]error, len(request.Events))
+	valid := make([]models.ConversationEvent, 0, len(request.Events))
+	indexes := make([]int, 0, len(request.Events))
+	for i, event := range request.Events {
+		if err := validation.Event(h.clock.Now(), event); err != nil {
+			errs[i] = fmt.Errorf("%w: %v", phase1.ErrInvalidEvent, err)
+			continue
+		}
+		valid = append(valid, event)
+		indexes = append(indexes, i)
+	}
+	if len(valid) > 0 {
+		for j, err := range h.timeoutManager.ApplyEvents(r.Context(), valid) {
+			errs[indexes[j]] = err
+		}
+	}
+	results := phase1.BatchResults(request.Events, errs)
+
+	var failed int
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+
+	response := map[string]interface{}{
+		"success": failed == 0,
+		"count":   len(results),
+		"failed":  failed,
+		"results": results,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+
+	h.logger.WithFields(logrus.Fields{
+		"events": len(results),
+		"failed": failed,
+	}).Debug("Applied event batch")
+}
+
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	count, err := h.timeoutManager.GetWaitingConversationsCount(r.Context())
 	if err != nil {
-		http.Error(w, "Health check failed", http.StatusServiceUnavailable)
+		problem.Respond(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "Health check failed")
 		return
 	}
 
 	response := map[string]interface{}{
 		"status":                "healthy",
-		"is_leader":             h.isLeaderFunc(),
+		"is_leader":             h.cluster.IsLeader(),
 		"waiting_conversations": count,
-		"timestamp":             time.Now(),
+		"timestamp":             h.clock.Now(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -151,14 +266,222 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
 	count, err := h.timeoutManager.GetWaitingConversationsCount(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to get status", http.StatusInternalServerError)
+		h.logger.WithError(err).Error("Failed to get status")
+		respondInternal(w, r)
 		return
 	}
 
 	response := map[string]interface{}{
-		"is_leader":             h.isLeaderFunc(),
+		"pod_id":                h.config.PodID,
+		"is_leader":             h.cluster.IsLeader(),
+		"owned_shards":          h.cluster.OwnedShards(),
 		"waiting_conversations": count,
-		"timestamp":             time.Now(),
+		"timestamp":             h.clock.Now(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := h.timeoutManager.GetConversation(r.Context(), conversationID)
+	if err != nil {
+		h.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to get conversation")
+		respondInternal(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func (h *Handler) ListConversations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var filter phase1.ConversationFilter
+	if levelStr := query.Get("level"); levelStr != "" {
+		level, err := strconv.Atoi(levelStr)
+		if err != nil || level < 0 {
+			invalidParameter(w, r, "Invalid level")
+			return
+		}
+		filter.Level = &level
+	}
+	if olderThan := query.Get("older_than"); olderThan != "" {
+		d, err := time.ParseDuration(olderThan)
+		if err != nil || d < 0 {
+			invalidParameter(w, r, "Invalid older_than duration")
+			return
+		}
+		filter.OlderThan = d
+	}
+
+	limit := 100
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 || parsed > 1000 {
+			invalidParameter(w, r, "Invalid limit")
+			return
+		}
+		limit = parsed
+	}
+
+	conversations, next, err := h.timeoutManager.ListConversations(r.Context(), filter, query.Get("cursor"), limit)
+	if errors.Is(err, phase1.ErrInvalidCursor) {
+		invalidParameter(w, r, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list conversations")
+		respondInternal(w, r)
+		return
+	}
+
+	response := map[string]interface{}{
+		"conversations": conversations,
+		"count":         len(conversations),
+		"next_cursor":   next,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *Handler) PauseConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		ActorID string `json:"actor_id,omitempty"`
+	}
+
+	// The body is optional
+	if !decodeBody(w, r, &request, constants.MaxRequestBodyBytes, true) || !h.validActor(w, r, request.ActorID) {
+		return
+	}
+
+	err := h.timeoutManager.PauseConversation(r.Context(), conversationID, request.ActorID)
+	h.respondHold(w, r, conversationID, err)
+}
+
+func (h *Handler) ResumeConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		ActorID string `json:"actor_id,omitempty"`
+	}
+
+	// The body is optional
+	if !decodeBody(w, r, &request, constants.MaxRequestBodyBytes, true) || !h.validActor(w, r, request.ActorID) {
+		return
+	}
+
+	err := h.timeoutManager.ResumeConversation(r.Context(), conversationID, request.ActorID)
+	h.respondHold(w, r, conversationID, err)
+}
+
+func (h *Handler) SnoozeConversation(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Until   time.Time `json:"until"`
+		ActorID string    `json:"actor_id,omitempty"`
+	}
+
+	if !decodeBody(w, r, &request, constants.MaxRequestBodyBytes, false) || !h.validActor(w, r, request.ActorID) {
+		return
+	}
+
+	if !request.Until.After(h.clock.Now()) {
+		respondInvalid(w, r, &validation.Error{Fields: []problem.FieldError{{
+			Field:   "until",
+			Code:    problem.FieldInvalidFormat,
+			Message: "must be in the future",
+		}}})
+		return
+	}
+
+	err := h.timeoutManager.SnoozeConversation(r.Context(), conversationID, request.Until, request.ActorID)
+	h.respondHold(w, r, conversationID, err)
+}
+
+func (h *Handler) ForceEscalation(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Level   int    `json:"level"`
+		ActorID string `json:"actor_id,omitempty"`
+	}
+
+	if !decodeBody(w, r, &request, constants.MaxRequestBodyBytes, false) || !h.validActor(w, r, request.ActorID) {
+		return
+	}
+
+	err := h.timeoutManager.ForceEscalation(r.Context(), conversationID, request.Level, request.ActorID)
+	h.respondHold(w, r, conversationID, err)
+}
+
+// respondHold answers a pause, resume, snooze or escalation request with the
+// conversation's resulting status
+func (h *Handler) respondHold(w http.ResponseWriter, r *http.Request, conversationID string, err error) {
+	switch {
+	case errors.Is(err, phase1.ErrConversationNotTracked):
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeConversationNotTracked, "Conversation not tracked")
+		return
+	case errors.Is(err, phase1.ErrInvalidLevel):
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidLevel, "Invalid escalation level")
+		return
+	case errors.Is(err, phase1.ErrConversationPaused):
+		problem.Respond(w, r, http.StatusConflict, problem.CodeConversationPaused, err.Error())
+		return
+	case errors.Is(err, phase1.ErrConversationNotPaused):
+		problem.Respond(w, r, http.StatusConflict, problem.CodeConversationNotPaused, err.Error())
+		return
+	case errors.Is(err, phase1.ErrConcurrentUpdate):
+		problem.Respond(w, r, http.StatusConflict, problem.CodeConcurrentUpdate, err.Error())
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to update conversation")
+		respondInternal(w, r)
+		return
+	}
+
+	h.GetConversation(w, r)
+}
+
+func (h *Handler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	conversationID, ok := h.conversationID(w, r)
+	if !ok {
+		return
+	}
+
+	events, err := h.timeoutManager.GetHistory(r.Context(), conversationID)
+	if err != nil {
+		h.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to get conversation history")
+		respondInternal(w, r)
+		return
+	}
+
+	response := map[string]interface{}{
+		"conversation_id": conversationID,
+		"events":          events,
+		"count":           len(events),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
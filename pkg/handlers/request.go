@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"redis-timeout-tracking-poc/pkg/problem"
+	"redis-timeout-tracking-poc/pkg/validation"
+)
+
+// decodeBody decodes the JSON body of r into dst. Bodies over limit bytes,
+// with fields dst doesn't have or with anything after the document are
+// refused; an empty body is accepted when optional. It answers the request
+// with a problem and returns false when the body can't be used.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}, limit int64, optional bool) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the JSON document")
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil, optional && err == io.EOF:
+		return true
+	case errors.As(err, &tooLarge):
+		problem.Respond(w, r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge, fmt.Sprintf("Request body must be at most %d bytes", limit))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeUnknownField, "Unknown field "+field)
+	case err == io.EOF:
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidBody, "Request body is required")
+	default:
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidBody, "Invalid request body: "+err.Error())
+	}
+	return false
+}
+
+// respondInvalid answers a request whose fields failed validation
+func respondInvalid(w http.ResponseWriter, r *http.Request, err error) {
+	p := problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Request fields are missing or invalid")
+
+	var invalid *validation.Error
+	if errors.As(err, &invalid) {
+		p.Errors = invalid.Fields
+	} else {
+		p.Detail = err.Error()
+	}
+	problem.Write(w, r, p)
+}
+
+// respondInternal answers a request that failed for a reason the caller
+// can't fix; the reason is only logged
+func respondInternal(w http.ResponseWriter, r *http.Request) {
+	problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
+}
+
+// conversationID returns the conversation ID of the request's path. It
+// answers the request with a problem and returns false when it isn't valid.
+func (h *Handler) conversationID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	conversationID := mux.Vars(r)["id"]
+
+	v := validation.New(h.clock.Now())
+	v.RequiredID("id", conversationID)
+	if err := v.Err(); err != nil {
+		p := problem.New(http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid conversation ID")
+		p.Errors = err.(*validation.Error).Fields
+		problem.Write(w, r, p)
+		return "", false
+	}
+	return conversationID, true
+}
+
+// invalidParameter answers a request with an invalid query parameter
+func invalidParameter(w http.ResponseWriter, r *http.Request, detail string) {
+	problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, detail)
+}
+
+// validActor checks the actor ID of a hold or escalation request. It answers
+// the request with a problem and returns false when it isn't valid.
+func (h *Handler) validActor(w http.ResponseWriter, r *http.Request, actorID string) bool {
+	v := validation.New(h.clock.Now())
+	v.OptionalID("actor_id", actorID)
+	if err := v.Err(); err != nil {
+		respondInvalid(w, r, err)
+		return false
+	}
+	return true
+}
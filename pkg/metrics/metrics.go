@@ -6,7 +6,7 @@ import (
 )
 
 type Metrics struct {
-	WaitingConversationsCount prometheus.Gauge
+	WaitingConversationsCount *prometheus.GaugeVec
 	TimeoutNotificationsSent  *prometheus.CounterVec
 	TimeoutLeaderChanges      prometheus.Counter
 	TimeoutCheckDuration      prometheus.Histogram
@@ -14,45 +14,72 @@ type Metrics struct {
 	LeaderElectionDuration    prometheus.Histogram
 	StreamProcessingDuration  prometheus.Histogram
 	StreamMessagesProcessed   *prometheus.CounterVec
+	IngestEventsProcessed     *prometheus.CounterVec
+	HTTPRequests              *prometheus.CounterVec
+	HTTPRequestDuration       *prometheus.HistogramVec
 }
 
+// NewMetrics registers the metrics with the global registry, which /metrics
+// serves. Registering them twice panics, so a process calls it once; tests
+// use NewMetricsWithRegistry.
 func NewMetrics() *Metrics {
+	return NewMetricsWithRegistry(prometheus.DefaultRegisterer)
+}
+
+// NewMetricsWithRegistry registers the metrics with reg instead of the global
+// registry, so tests can create as many instances as they need
+func NewMetricsWithRegistry(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+
 	return &Metrics{
-		WaitingConversationsCount: promauto.NewGauge(prometheus.GaugeOpts{
+		WaitingConversationsCount: factory.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "waiting_conversations_count",
 			Help: "Current number of conversations waiting for customer response",
-		}),
-		TimeoutNotificationsSent: promauto.NewCounterVec(prometheus.CounterOpts{
+		}, []string{"shard"}),
+		TimeoutNotificationsSent: factory.NewCounterVec(prometheus.CounterOpts{
 			Name: "timeout_notifications_sent_total",
 			Help: "Total number of timeout notifications sent",
 		}, []string{"level"}),
-		TimeoutLeaderChanges: promauto.NewCounter(prometheus.CounterOpts{
+		TimeoutLeaderChanges: factory.NewCounter(prometheus.CounterOpts{
 			Name: "timeout_leader_changes_total",
 			Help: "Total number of leader changes",
 		}),
-		TimeoutCheckDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+		TimeoutCheckDuration: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "timeout_check_duration_seconds",
 			Help:    "Time taken to check all timeouts",
 			Buckets: prometheus.DefBuckets,
 		}),
-		RedisOperationDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+		RedisOperationDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "redis_operation_duration_seconds",
 			Help:    "Time taken for Redis operations",
 			Buckets: prometheus.DefBuckets,
 		}, []string{"operation"}),
-		LeaderElectionDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+		LeaderElectionDuration: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "leader_election_duration_seconds",
 			Help:    "Time taken for leader election operations",
 			Buckets: prometheus.DefBuckets,
 		}),
-		StreamProcessingDuration: promauto.NewHistogram(prometheus.HistogramOpts{
+		StreamProcessingDuration: factory.NewHistogram(prometheus.HistogramOpts{
 			Name:    "stream_processing_duration_seconds",
 			Help:    "Time taken to process stream messages",
 			Buckets: prometheus.DefBuckets,
 		}),
-		StreamMessagesProcessed: promauto.NewCounterVec(prometheus.CounterOpts{
+		StreamMessagesProcessed: factory.NewCounterVec(prometheus.CounterOpts{
 			Name: "stream_messages_processed_total",
 			Help: "Total number of stream messages processed",
 		}, []string{"status"}),
+		IngestEventsProcessed: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "ingest_events_processed_total",
+			Help: "Total number of agent message and customer response events read from the input stream",
+		}, []string{"status"}),
+		HTTPRequests: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP API requests",
+		}, []string{"method", "route", "status"}),
+		HTTPRequestDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time taken to answer HTTP API requests",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
 	}
 }
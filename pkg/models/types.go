@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // ConversationTimeout represents a conversation waiting for customer response
 type ConversationTimeout struct {
@@ -11,11 +14,26 @@ type ConversationTimeout struct {
 
 // TimeoutEvent represents a timeout event for Phase 2 stream processing
 type TimeoutEvent struct {
+	EventID          string    `json:"event_id"`
 	ConversationID   string    `json:"conversation_id"`
 	Level            int       `json:"level"`
+	LevelName        string    `json:"level_name,omitempty"`
+	Action           string    `json:"action,omitempty"`
+	Policy           string    `json:"policy,omitempty"`
 	AgentMessageTime time.Time `json:"agent_message_time"`
 	DetectedAt       time.Time `json:"detected_at"`
 	Attempt          int       `json:"attempt"`
+	// Shard is the detection shard of the conversation and FencingToken the
+	// epoch of the shard leader that detected the timeout
+	Shard        int   `json:"shard"`
+	FencingToken int64 `json:"fencing_token,omitempty"`
+}
+
+// TimeoutEventID returns the deterministic identity of the timeout event for a
+// conversation's agent message at a given level. Re-publishing the same
+// escalation always yields the same ID, so consumers can dedupe on it.
+func TimeoutEventID(conversationID string, agentMessageTime time.Time, level int) string {
+	return fmt.Sprintf("%s:%d:%d", conversationID, agentMessageTime.UnixMilli(), level)
 }
 
 // NotificationLevel represents the escalation levels
@@ -34,6 +52,34 @@ type AgentMessage struct {
 	AgentID        string    `json:"agent_id"`
 	MessageID      string    `json:"message_id"`
 	Timestamp      time.Time `json:"timestamp"`
+	Policy         string    `json:"policy,omitempty"`
+	TenantID       string    `json:"tenant_id,omitempty"`
+	Channel        string    `json:"channel,omitempty"`
+	Priority       string    `json:"priority,omitempty"`
+}
+
+// Attributes returns the policy selection attributes carried by the message
+func (m AgentMessage) Attributes() ConversationAttributes {
+	return ConversationAttributes{
+		Policy:   m.Policy,
+		TenantID: m.TenantID,
+		Channel:  m.Channel,
+		Priority: m.Priority,
+	}
+}
+
+// ConversationAttributes are stored with a tracked conversation and select
+// the escalation policy it is evaluated against
+type ConversationAttributes struct {
+	Policy   string `json:"policy,omitempty"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+	Priority string `json:"priority,omitempty"`
+}
+
+// IsZero reports whether no attributes are set
+func (a ConversationAttributes) IsZero() bool {
+	return a == ConversationAttributes{}
 }
 
 // CustomerResponse represents a customer response event
@@ -43,3 +89,122 @@ type CustomerResponse struct {
 	MessageID      string    `json:"message_id"`
 	Timestamp      time.Time `json:"timestamp"`
 }
+
+// Conversation event types accepted in batches
+const (
+	EventAgentMessage     = "agent_message"
+	EventCustomerResponse = "customer_response"
+)
+
+// ConversationEvent is an agent message or a customer response submitted in
+// a batch, told apart by Type
+type ConversationEvent struct {
+	Type           string    `json:"type"`
+	ConversationID string    `json:"conversation_id"`
+	AgentID        string    `json:"agent_id,omitempty"`
+	CustomerID     string    `json:"customer_id,omitempty"`
+	MessageID      string    `json:"message_id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Policy         string    `json:"policy,omitempty"`
+	TenantID       string    `json:"tenant_id,omitempty"`
+	Channel        string    `json:"channel,omitempty"`
+	Priority       string    `json:"priority,omitempty"`
+}
+
+// AgentMessage returns the event as an agent message
+func (e ConversationEvent) AgentMessage() AgentMessage {
+	return AgentMessage{
+		ConversationID: e.ConversationID,
+		AgentID:        e.AgentID,
+		MessageID:      e.MessageID,
+		Timestamp:      e.Timestamp,
+		Policy:         e.Policy,
+		TenantID:       e.TenantID,
+		Channel:        e.Channel,
+		Priority:       e.Priority,
+	}
+}
+
+// CustomerResponse returns the event as a customer response
+func (e ConversationEvent) CustomerResponse() CustomerResponse {
+	return CustomerResponse{
+		ConversationID: e.ConversationID,
+		CustomerID:     e.CustomerID,
+		MessageID:      e.MessageID,
+		Timestamp:      e.Timestamp,
+	}
+}
+
+// BatchEventResult is the outcome of one event of a batch. Result is
+// "applied", "stale" or "duplicate" for an event that succeeded, "rejected"
+// for an invalid one and "failed" for one that couldn't be applied.
+type BatchEventResult struct {
+	Index          int    `json:"index"`
+	Type           string `json:"type"`
+	ConversationID string `json:"conversation_id"`
+	Success        bool   `json:"success"`
+	Result         string `json:"result"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Conversation statuses reported by ConversationStatus
+const (
+	StatusNotTracked = "not_tracked"
+	StatusWaiting    = "waiting"
+	// StatusExhausted means every level of the policy has fired; the
+	// conversation stays tracked until the customer responds
+	StatusExhausted = "exhausted"
+	// StatusPaused and StatusSnoozed mean the conversation's clock is
+	// stopped, until resumed or until the snooze ends
+	StatusPaused  = "paused"
+	StatusSnoozed = "snoozed"
+)
+
+// ConversationStatus describes where a conversation is in its escalation
+// ladder, for support staff inspecting why it did or didn't escalate
+type ConversationStatus struct {
+	ConversationID   string     `json:"conversation_id"`
+	Tracked          bool       `json:"tracked"`
+	Status           string     `json:"status"`
+	AgentMessageTime *time.Time `json:"agent_message_time,omitempty"`
+	Level            int        `json:"current_level"`
+	LevelName        string     `json:"level_name,omitempty"`
+	NextLevel        string     `json:"next_level,omitempty"`
+	NextDeadline     *time.Time `json:"next_deadline,omitempty"`
+	// Overdue is set when the next deadline has passed but detection hasn't
+	// escalated the conversation yet
+	Overdue  bool       `json:"overdue,omitempty"`
+	PausedAt *time.Time `json:"paused_at,omitempty"`
+	// SnoozedUntil is when a snoozed conversation's clock restarts
+	SnoozedUntil *time.Time `json:"snoozed_until,omitempty"`
+	// ForcedLevel is a manual escalation the detector hasn't made yet
+	ForcedLevel int                    `json:"forced_level,omitempty"`
+	Policy      string                 `json:"policy,omitempty"`
+	Shard       int                    `json:"shard"`
+	Attributes  ConversationAttributes `json:"attributes"`
+}
+
+// History event types recorded in a conversation's timeline
+const (
+	HistoryTracked               = "tracked"
+	HistoryCleared               = "cleared"
+	HistoryEscalated             = "escalated"
+	HistoryNotificationDelivered = "notification_delivered"
+	HistoryNotificationFailed    = "notification_failed"
+	HistoryPaused                = "paused"
+	HistorySnoozed               = "snoozed"
+	HistoryResumed               = "resumed"
+	HistoryEscalationForced      = "escalation_forced"
+)
+
+// HistoryEvent is an entry of a conversation's timeline
+type HistoryEvent struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	// ActorID is who caused the event: the agent, the customer, or the pod
+	// that detected the timeout or delivered the notification
+	ActorID string `json:"actor_id,omitempty"`
+	Level   int    `json:"level,omitempty"`
+	// Detail is free text, e.g. why a notification failed
+	Detail string `json:"detail,omitempty"`
+}
@@ -0,0 +1,86 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+// Notifier delivers timeout notifications to whoever acts on them
+type Notifier interface {
+	Notify(ctx context.Context, event models.TimeoutEvent) error
+}
+
+// PermanentError marks a notification failure that will not succeed on retry,
+// e.g. the receiver rejected the payload
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return "permanent notification failure: " + e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err as a PermanentError
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// IsRetryable reports whether a failed notification is worth retrying. Errors
+// are retryable unless they were marked permanent.
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	return err != nil && !errors.As(err, &permanent)
+}
+
+// ErrMissingWebhookSecret is returned by New for a webhook URL without a
+// secret: payloads signed with an empty key could be forged by anyone
+var ErrMissingWebhookSecret = errors.New("WEBHOOK_SECRET is required when WEBHOOK_URL is set")
+
+// New returns the notifier selected by the configuration: a webhook notifier
+// when a webhook URL is set, otherwise a notifier that only logs
+func New(cfg *config.Config, logger *logrus.Logger) (Notifier, error) {
+	if cfg.WebhookURL != "" {
+		if cfg.WebhookSecret == "" {
+			return nil, ErrMissingWebhookSecret
+		}
+		return NewWebhookNotifier(WebhookConfig{
+			URL:     cfg.WebhookURL,
+			Secret:  cfg.WebhookSecret,
+			Timeout: cfg.WebhookTimeout(),
+		}, logger), nil
+	}
+	return NewLogNotifier(logger), nil
+}
+
+// LogNotifier only logs notifications. It stands in when no notification
+// service is configured.
+type LogNotifier struct {
+	logger *logrus.Logger
+}
+
+func NewLogNotifier(logger *logrus.Logger) *LogNotifier {
+	return &LogNotifier{
+		logger: logger,
+	}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, event models.TimeoutEvent) error {
+	n.logger.WithFields(logrus.Fields{
+		"event_id":        event.EventID,
+		"conversation_id": event.ConversationID,
+		"level":           event.Level,
+		"detected_at":     event.DetectedAt,
+		"attempt":         event.Attempt,
+	}).Info("Timeout notification")
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+const (
+	SignatureHeader = "X-Timeout-Signature"
+	TimestampHeader = "X-Timeout-Timestamp"
+	EventIDHeader   = "X-Timeout-Event-ID"
+)
+
+type WebhookConfig struct {
+	URL     string
+	Secret  string
+	Timeout time.Duration
+}
+
+// WebhookNotifier POSTs timeout events as JSON to an HTTP endpoint. Each
+// request is signed with HMAC-SHA256 over "<timestamp>.<body>" so the receiver
+// can verify the sender and reject replays.
+type WebhookNotifier struct {
+	config WebhookConfig
+	client *http.Client
+	logger *logrus.Logger
+}
+
+func NewWebhookNotifier(config WebhookConfig, logger *logrus.Logger) *WebhookNotifier {
+	return &WebhookNotifier{
+		config: config,
+		client: &http.Client{},
+		logger: logger,
+	}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, event models.TimeoutEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to marshal timeout event: %w", err))
+	}
+
+	if n.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.config.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("failed to create webhook request: %w", err))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(EventIDHeader, event.EventID)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(n.config.Secret, timestamp, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// Connection failures and timeouts may succeed next time
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		n.logger.WithFields(logrus.Fields{
+			"event_id":        event.EventID,
+			"conversation_id": event.ConversationID,
+			"level":           event.Level,
+			"status":          resp.StatusCode,
+		}).Debug("Delivered timeout notification webhook")
+		return nil
+	}
+
+	err = fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	if isRetryableStatus(resp.StatusCode) {
+		return err
+	}
+	return Permanent(err)
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of "<timestamp>.<body>"
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func isRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+}
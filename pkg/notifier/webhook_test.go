@@ -0,0 +1,123 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+func testEvent() models.TimeoutEvent {
+	agentTime := time.Now().Add(-time.Minute)
+	return models.TimeoutEvent{
+		EventID:          models.TimeoutEventID("conv_123", agentTime, 1),
+		ConversationID:   "conv_123",
+		Level:            1,
+		AgentMessageTime: agentTime,
+		DetectedAt:       time.Now(),
+		Attempt:          1,
+	}
+}
+
+func TestWebhookNotifier_SignsPayload(t *testing.T) {
+	secret := "test-secret"
+	event := testEvent()
+
+	var received models.TimeoutEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		timestamp := r.Header.Get(TimestampHeader)
+		assert.Equal(t, "sha256="+Sign(secret, timestamp, body), r.Header.Get(SignatureHeader))
+		assert.Equal(t, event.EventID, r.Header.Get(EventIDHeader))
+		require.NoError(t, json.Unmarshal(body, &received))
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	n := NewWebhookNotifier(WebhookConfig{URL: server.URL, Secret: secret, Timeout: time.Second}, logger)
+
+	err := n.Notify(context.Background(), event)
+	assert.NoError(t, err)
+	assert.Equal(t, event.EventID, received.EventID)
+	assert.Equal(t, event.Level, received.Level)
+}
+
+func TestWebhookNotifier_ClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		retryable bool
+	}{
+		{"server error", http.StatusBadGateway, true},
+		{"rate limited", http.StatusTooManyRequests, true},
+		{"bad request", http.StatusBadRequest, false},
+		{"gone", http.StatusGone, false},
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			n := NewWebhookNotifier(WebhookConfig{URL: server.URL, Secret: "s", Timeout: time.Second}, logger)
+
+			err := n.Notify(context.Background(), testEvent())
+			require.Error(t, err)
+			assert.Equal(t, tt.retryable, IsRetryable(err))
+		})
+	}
+}
+
+func TestWebhookNotifier_TimeoutIsRetryable(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	n := NewWebhookNotifier(WebhookConfig{URL: server.URL, Secret: "s", Timeout: 50 * time.Millisecond}, logger)
+
+	err := n.Notify(context.Background(), testEvent())
+	require.Error(t, err)
+	assert.True(t, IsRetryable(err))
+}
+
+func TestNew_RequiresWebhookSecret(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	// Payloads signed with an empty key could be forged
+	_, err := New(&config.Config{WebhookURL: "http://example.com/hook"}, logger)
+	assert.ErrorIs(t, err, ErrMissingWebhookSecret)
+
+	n, err := New(&config.Config{WebhookURL: "http://example.com/hook", WebhookSecret: "test-secret"}, logger)
+	require.NoError(t, err)
+	assert.IsType(t, &WebhookNotifier{}, n)
+
+	n, err = New(&config.Config{}, logger)
+	require.NoError(t, err)
+	assert.IsType(t, &LogNotifier{}, n)
+}
@@ -0,0 +1,147 @@
+package phase1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+var (
+	// ErrInvalidEvent is wrapped by the errors ApplyEvents returns for
+	// events it rejects without applying
+	ErrInvalidEvent = errors.New("invalid event")
+	// ErrInvalidEventType is returned for an event that is neither an agent
+	// message nor a customer response
+	ErrInvalidEventType = fmt.Errorf("%w: unknown event type", ErrInvalidEvent)
+	// ErrMissingConversationID is returned for an event without a
+	// conversation ID
+	ErrMissingConversationID = fmt.Errorf("%w: missing conversation ID", ErrInvalidEvent)
+	// ErrUnknownPolicy is returned for an agent message naming an escalation
+	// policy that isn't defined
+	ErrUnknownPolicy = fmt.Errorf("%w: unknown escalation policy", ErrInvalidEvent)
+)
+
+// ApplyEvents tracks and clears conversations for a batch of agent messages
+// and customer responses, as TrackAgentMessage and ClearTimeout would one at
+// a time, but in a single round trip to the store. It returns each event's
+// error: invalid events are rejected with an error wrapping ErrInvalidEvent,
+// and stale and duplicate events are ignored as by TrackAgentMessage. Events
+// without a timestamp are taken to happen now.
+func (tm *TimeoutManager) ApplyEvents(ctx context.Context, events []models.ConversationEvent) []error {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues("apply_events").Observe(time.Since(start).Seconds())
+	}()
+
+	errs := make([]error, len(events))
+	storeEvents := make([]Event, 0, len(events))
+	history := make([]HistoryEntry, 0, len(events))
+	// indexes[j] is the event storeEvents[j] and history[j] come from
+	indexes := make([]int, 0, len(events))
+	for i, event := range events {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = tm.clock.Now()
+		}
+		if event.ConversationID == "" {
+			errs[i] = ErrMissingConversationID
+			continue
+		}
+
+		timestamp := event.Timestamp.UnixMilli()
+		switch event.Type {
+		case models.EventAgentMessage:
+			if event.Policy != "" && !tm.evaluator.HasPolicy(event.Policy) {
+				errs[i] = ErrUnknownPolicy
+				continue
+			}
+			attrs := event.AgentMessage().Attributes()
+			policy := tm.evaluator.Select(attrs)
+			storeEvents = append(storeEvents, Event{
+				ConversationID: event.ConversationID,
+				MessageID:      event.MessageID,
+				TimestampMS:    timestamp,
+				Deadline:       DeadlineScore(tm.evaluator.NextDeadlineMS(policy, attrs, timestamp, 0)),
+				Attributes:     attrs,
+			})
+			history = append(history, HistoryEntry{ConversationID: event.ConversationID, Event: models.HistoryEvent{
+				Type:      models.HistoryTracked,
+				Timestamp: event.Timestamp,
+				ActorID:   event.AgentID,
+				Detail:    "policy " + policy.Name,
+			}})
+		case models.EventCustomerResponse:
+			storeEvents = append(storeEvents, Event{
+				ConversationID: event.ConversationID,
+				MessageID:      event.MessageID,
+				TimestampMS:    timestamp,
+				Clear:          true,
+			})
+			history = append(history, HistoryEntry{ConversationID: event.ConversationID, Event: models.HistoryEvent{
+				Type:      models.HistoryCleared,
+				Timestamp: event.Timestamp,
+				ActorID:   event.CustomerID,
+			}})
+		default:
+			errs[i] = ErrInvalidEventType
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+
+	applied := make([]HistoryEntry, 0, len(history))
+	var failed int
+	for j, err := range tm.store.Apply(ctx, storeEvents) {
+		errs[indexes[j]] = err
+		switch {
+		case err == nil:
+			applied = append(applied, history[j])
+		case !IsIgnoredEvent(err):
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		tm.logger.WithFields(logrus.Fields{
+			"events": len(events),
+			"failed": failed,
+		}).Error("Failed to apply batched events")
+	}
+
+	// Only applied events make it into the histories
+	if len(applied) > 0 {
+		if err := tm.store.RecordAll(ctx, applied); err != nil {
+			tm.logger.WithError(err).WithField("events", len(applied)).Warn("Failed to record conversation history")
+		}
+	}
+
+	return errs
+}
+
+// BatchResults reports the outcome of each event of a batch given the errors
+// ApplyEvents returned for it. Why an event was rejected is reported, but not
+// why one failed.
+func BatchResults(events []models.ConversationEvent, errs []error) []models.BatchEventResult {
+	results := make([]models.BatchEventResult, len(events))
+	for i, event := range events {
+		result := EventResult(errs[i])
+		results[i] = models.BatchEventResult{
+			Index:          i,
+			Type:           event.Type,
+			ConversationID: event.ConversationID,
+			Success:        errs[i] == nil || IsIgnoredEvent(errs[i]),
+			Result:         result,
+		}
+		switch {
+		case errors.Is(errs[i], ErrInvalidEvent):
+			results[i].Error = errs[i].Error()
+		case !results[i].Success:
+			results[i].Error = "internal error"
+		}
+	}
+	return results
+}
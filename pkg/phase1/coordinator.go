@@ -0,0 +1,149 @@
+package phase1
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+// Coordinator spreads the detection shards over the pods that are running.
+// Every pod heartbeats into MembersKey; each shard belongs to the live pod
+// that rendezvous hashing picks for it, which campaigns for the shard's lease
+// while every other pod resigns it. When pods join or leave, only the shards
+// they win or held change hands, and the leases keep two pods from detecting
+// the same shard while the views of the membership catch up.
+type Coordinator struct {
+	rdb    redis.UniversalClient
+	config *config.Config
+	logger *logrus.Logger
+	clock  clock.Clock
+	leases []*LeaderElection
+	stopCh chan struct{}
+}
+
+// NewCoordinator creates a coordinator for leases, one per shard in shard
+// order
+func NewCoordinator(rdb redis.UniversalClient, config *config.Config, logger *logrus.Logger, clock clock.Clock, leases []*LeaderElection) *Coordinator {
+	return &Coordinator{
+		rdb:    rdb,
+		config: config,
+		logger: logger,
+		clock:  clock,
+		leases: leases,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start runs the coordination loop: a round now and then every renew
+// interval, jittered once per pod
+func (c *Coordinator) Start(ctx context.Context) error {
+	c.logger.WithField("shards", len(c.leases)).Info("Starting shard coordination")
+
+	go c.loop(ctx)
+
+	return nil
+}
+
+// Stop ends the loop, resigns every shard this pod holds and leaves the
+// membership, so the remaining pods take the shards over on their next round
+func (c *Coordinator) Stop() {
+	close(c.stopCh)
+
+	ctx := context.Background()
+	for _, lease := range c.leases {
+		if lease.FencingToken() > 0 {
+			lease.Resign(ctx)
+		}
+	}
+	if err := c.rdb.ZRem(ctx, MembersKey, c.config.PodID).Err(); err != nil {
+		c.logger.WithError(err).Error("Failed to leave shard membership")
+	}
+}
+
+// IsLeader reports whether this pod currently leads any shard
+func (c *Coordinator) IsLeader() bool {
+	for _, lease := range c.leases {
+		if lease.IsLeader() {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnedShards returns the shards this pod currently leads
+func (c *Coordinator) OwnedShards() []int {
+	owned := []int{}
+	for _, lease := range c.leases {
+		if lease.IsLeader() {
+			owned = append(owned, lease.Shard())
+		}
+	}
+	return owned
+}
+
+func (c *Coordinator) loop(ctx context.Context) {
+	ticker := c.clock.NewTicker(jitter(c.config.LeaderRenewInterval(), constants.LeaderRenewJitter))
+	defer ticker.Stop()
+
+	c.round(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.stopCh:
+			return
+		case <-ticker.C():
+			c.round(ctx)
+		}
+	}
+}
+
+// round heartbeats, then campaigns for the shards this pod should own and
+// resigns the ones it holds but shouldn't
+func (c *Coordinator) round(ctx context.Context) {
+	members, err := c.heartbeat(ctx)
+	if err != nil {
+		// Without a membership view, only keep what we hold
+		c.logger.WithError(err).Error("Failed to refresh shard membership")
+		for _, lease := range c.leases {
+			if lease.FencingToken() > 0 {
+				lease.Campaign(ctx)
+			}
+		}
+		return
+	}
+
+	for _, lease := range c.leases {
+		if shard.Owner(lease.Shard(), members) == c.config.PodID {
+			lease.Campaign(ctx)
+		} else if lease.FencingToken() > 0 {
+			c.logger.WithField("shard", lease.Shard()).Info("Handing shard over to another pod")
+			lease.Resign(ctx)
+		}
+	}
+}
+
+// heartbeat records this pod as alive, drops members that stopped
+// heartbeating a lock TTL ago and returns the live ones
+func (c *Coordinator) heartbeat(ctx context.Context) ([]string, error) {
+	now := c.clock.Now()
+	expired := now.Add(-c.config.LeaderElectionTTLDuration()).UnixMilli()
+
+	pipe := c.rdb.TxPipeline()
+	pipe.ZAdd(ctx, MembersKey, &redis.Z{Score: float64(now.UnixMilli()), Member: c.config.PodID})
+	pipe.ZRemRangeByScore(ctx, MembersKey, "-inf", strconv.FormatInt(expired, 10))
+	membersCmd := pipe.ZRange(ctx, MembersKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to heartbeat: %w", err)
+	}
+
+	return membersCmd.Val(), nil
+}
@@ -0,0 +1,79 @@
+package phase1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+func testCoordinator(rdb *redis.Client, podID string, clk clock.Clock) *Coordinator {
+	cfg := &config.Config{
+		PodID:             podID,
+		LeaderElectionTTL: 9,
+		ShardCount:        8,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+
+	leases := make([]*LeaderElection, cfg.Shards())
+	for s := range leases {
+		leases[s] = NewLeaderElection(rdb, nil, cfg, logger, metrics, nil, nil, clk, s)
+	}
+	return NewCoordinator(rdb, cfg, logger, clk, leases)
+}
+
+func TestCoordinator_Rebalances(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	ctx := context.Background()
+	clk := clock.NewManual(time.Now())
+	podA := testCoordinator(rdb, "pod-a", clk)
+	podB := testCoordinator(rdb, "pod-b", clk)
+
+	// Alone, a pod takes every shard
+	podA.round(ctx)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, podA.OwnedShards())
+
+	// A second pod joins: the first hands over the shards the second wins,
+	// which takes them on its next round
+	podB.round(ctx)
+	assert.Empty(t, podB.OwnedShards())
+	podA.round(ctx)
+	podB.round(ctx)
+
+	members := []string{"pod-a", "pod-b"}
+	for s := 0; s < 8; s++ {
+		owner := podA
+		other := podB
+		if shard.Owner(s, members) == "pod-b" {
+			owner, other = podB, podA
+		}
+		assert.True(t, owner.leases[s].IsLeader(), "shard %d", s)
+		assert.False(t, other.leases[s].IsLeader(), "shard %d", s)
+	}
+	assert.NotEmpty(t, podA.OwnedShards())
+	assert.NotEmpty(t, podB.OwnedShards())
+
+	// When it leaves, its shards go back
+	podB.Stop()
+	assert.Empty(t, podB.OwnedShards())
+	podA.round(ctx)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, podA.OwnedShards())
+
+	members, err := rdb.ZRange(ctx, MembersKey, 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pod-a"}, members)
+}
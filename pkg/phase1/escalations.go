@@ -0,0 +1,75 @@
+package phase1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+// EscalationsChannel is the pub/sub channel detectors announce every
+// escalation on, for live watchers. Delivery is best effort: watchers only
+// get the escalations announced while they are subscribed.
+const EscalationsChannel = "timeout:escalations"
+
+// PublishEscalation announces an escalation to the watchers of
+// EscalationsChannel. A failure is only logged: notifications and the event
+// stream don't depend on it.
+func PublishEscalation(ctx context.Context, rdb redis.UniversalClient, logger *logrus.Logger, event models.TimeoutEvent) {
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = rdb.Publish(ctx, EscalationsChannel, data).Err()
+	}
+	if err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"conversation_id": event.ConversationID,
+			"level":           event.Level,
+		}).Warn("Failed to publish escalation")
+	}
+}
+
+// WatchEscalations subscribes to the escalations announced by any pod from
+// now on. The channel is closed once ctx is done.
+func WatchEscalations(ctx context.Context, rdb redis.UniversalClient, logger *logrus.Logger) (<-chan models.TimeoutEvent, error) {
+	pubsub := rdb.Subscribe(ctx, EscalationsChannel)
+	// Wait for the subscription, so nothing announced after this returns is
+	// missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to escalations: %w", err)
+	}
+
+	escalations := make(chan models.TimeoutEvent)
+	go func() {
+		defer close(escalations)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event models.TimeoutEvent
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					logger.WithError(err).Warn("Ignored malformed escalation")
+					continue
+				}
+				select {
+				case escalations <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return escalations, nil
+}
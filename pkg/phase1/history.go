@@ -0,0 +1,43 @@
+package phase1
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+// RecordHistory appends an event to a conversation's timeline. The timeline
+// is informational, so a failure to record is only logged.
+func RecordHistory(ctx context.Context, store TimeoutStore, logger *logrus.Logger, conversationID string, event models.HistoryEvent) {
+	if err := store.Record(ctx, conversationID, event); err != nil {
+		logger.WithError(err).WithFields(logrus.Fields{
+			"conversation_id": conversationID,
+			"event_type":      event.Type,
+		}).Warn("Failed to record conversation history")
+	}
+}
+
+// GetHistory returns a conversation's timeline, oldest event first. It is
+// kept for a while after the conversation stops waiting.
+func (tm *TimeoutManager) GetHistory(ctx context.Context, conversationID string) ([]models.HistoryEvent, error) {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues("get_history").Observe(time.Since(start).Seconds())
+	}()
+
+	return tm.store.History(ctx, conversationID)
+}
+
+// record appends an event that happened now to a conversation's timeline
+func (tm *TimeoutManager) record(ctx context.Context, conversationID, eventType, actorID string, level int, detail string) {
+	RecordHistory(ctx, tm.store, tm.logger, conversationID, models.HistoryEvent{
+		Type:      eventType,
+		Timestamp: tm.clock.Now(),
+		ActorID:   actorID,
+		Level:     level,
+		Detail:    detail,
+	})
+}
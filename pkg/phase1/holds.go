@@ -0,0 +1,194 @@
+package phase1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/escalation"
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+var (
+	// ErrConversationNotTracked is returned for a conversation that isn't
+	// waiting for a customer response
+	ErrConversationNotTracked = errors.New("conversation not tracked")
+	// ErrConversationPaused is returned when escalating a paused conversation
+	ErrConversationPaused = errors.New("conversation is paused")
+	// ErrConversationNotPaused is returned when resuming a running
+	// conversation
+	ErrConversationNotPaused = errors.New("conversation is not paused")
+	// ErrInvalidLevel is returned when forcing a level the conversation's
+	// policy doesn't have, or one it has already reached
+	ErrInvalidLevel = errors.New("invalid escalation level")
+	// ErrConcurrentUpdate is returned when a conversation kept changing while
+	// an operation was being applied to it
+	ErrConcurrentUpdate = errors.New("conversation changed concurrently")
+)
+
+// holdAttempts is how many times an operation conditional on a
+// conversation's state is tried before giving up with ErrConcurrentUpdate
+const holdAttempts = 3
+
+// EscalationLevel returns the level a conversation in state has reached by
+// now (ms) under policy, if it is above state.Level: the level its wait has
+// reached, or the level it was manually escalated to when that is higher
+func EscalationLevel(evaluator *escalation.Evaluator, policy *escalation.Policy, state ConversationState, now int64) (int, bool) {
+	level, due := evaluator.Evaluate(policy, evaluator.WaitMS(policy, state.Attributes, state.AgentMessageTime, now), state.Level)
+	if state.ForcedLevel > state.Level && (!due || state.ForcedLevel > level) {
+		return state.ForcedLevel, true
+	}
+	return level, due
+}
+
+// ResumeSnoozed restarts the clock of a conversation detection found due
+// while paused, i.e. at the end of its snooze. The snooze doesn't count as
+// waiting time.
+func ResumeSnoozed(ctx context.Context, store TimeoutStore, evaluator *escalation.Evaluator, conversationID string, state ConversationState) (bool, error) {
+	agentMessageMS, deadline := resumeSchedule(evaluator, state, int64(state.Deadline))
+	return store.Resume(ctx, conversationID, state, agentMessageMS, deadline)
+}
+
+// resumeSchedule returns the agent message time and next deadline of a paused
+// conversation resumed at resumeMS: the agent message time moves forward by
+// the pause, keeping the time waited before it
+func resumeSchedule(evaluator *escalation.Evaluator, state ConversationState, resumeMS int64) (int64, float64) {
+	pause := resumeMS - state.PausedAt
+	if pause < 0 {
+		pause = 0
+	}
+	agentMessageMS := state.AgentMessageTime + pause
+
+	// A manual escalation recorded before the pause is still pending
+	if state.ForcedLevel > state.Level {
+		return agentMessageMS, float64(resumeMS)
+	}
+
+	policy := evaluator.Select(state.Attributes)
+	return agentMessageMS, DeadlineScore(evaluator.NextDeadlineMS(policy, state.Attributes, agentMessageMS, state.Level))
+}
+
+// PauseConversation stops a conversation's clock until ResumeConversation:
+// it isn't escalated meanwhile, and the pause doesn't count as waiting time
+func (tm *TimeoutManager) PauseConversation(ctx context.Context, conversationID, actorID string) error {
+	if err := tm.pause(ctx, conversationID, math.Inf(1), "pause_conversation"); err != nil {
+		return err
+	}
+	tm.record(ctx, conversationID, models.HistoryPaused, actorID, 0, "")
+	return nil
+}
+
+// SnoozeConversation pauses a conversation until the given time, when
+// detection resumes it
+func (tm *TimeoutManager) SnoozeConversation(ctx context.Context, conversationID string, until time.Time, actorID string) error {
+	if err := tm.pause(ctx, conversationID, float64(until.UnixMilli()), "snooze_conversation"); err != nil {
+		return err
+	}
+	tm.record(ctx, conversationID, models.HistorySnoozed, actorID, 0, "until "+until.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func (tm *TimeoutManager) pause(ctx context.Context, conversationID string, resumeAt float64, operation string) error {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}()
+
+	paused, err := tm.store.Pause(ctx, conversationID, tm.clock.Now().UnixMilli(), resumeAt)
+	if err != nil {
+		tm.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to pause conversation")
+		return fmt.Errorf("failed to pause conversation: %w", err)
+	}
+	if !paused {
+		return ErrConversationNotTracked
+	}
+
+	tm.logger.WithFields(logrus.Fields{
+		"conversation_id": conversationID,
+		"resume_at":       resumeAt,
+	}).Debug("Paused conversation")
+
+	return nil
+}
+
+// ResumeConversation restarts the clock of a paused or snoozed conversation,
+// keeping the time it waited before the pause
+func (tm *TimeoutManager) ResumeConversation(ctx context.Context, conversationID, actorID string) error {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues("resume_conversation").Observe(time.Since(start).Seconds())
+	}()
+
+	for attempt := 0; attempt < holdAttempts; attempt++ {
+		state, err := tm.store.State(ctx, conversationID)
+		if err != nil {
+			return fmt.Errorf("failed to resume conversation: %w", err)
+		}
+		if state.AgentMessageTime == 0 {
+			return ErrConversationNotTracked
+		}
+		if !state.Paused() {
+			return ErrConversationNotPaused
+		}
+
+		agentMessageMS, deadline := resumeSchedule(tm.evaluator, state, tm.clock.Now().UnixMilli())
+		resumed, err := tm.store.Resume(ctx, conversationID, state, agentMessageMS, deadline)
+		if err != nil {
+			tm.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to resume conversation")
+			return fmt.Errorf("failed to resume conversation: %w", err)
+		}
+		if resumed {
+			tm.record(ctx, conversationID, models.HistoryResumed, actorID, state.Level, "")
+			tm.logger.WithField("conversation_id", conversationID).Debug("Resumed conversation")
+			return nil
+		}
+	}
+	return ErrConcurrentUpdate
+}
+
+// ForceEscalation makes detection escalate a conversation to level on its
+// next check, however long it has waited. The level must be one of its
+// policy's and above the level it has reached.
+func (tm *TimeoutManager) ForceEscalation(ctx context.Context, conversationID string, level int, actorID string) error {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues("force_escalation").Observe(time.Since(start).Seconds())
+	}()
+
+	for attempt := 0; attempt < holdAttempts; attempt++ {
+		state, err := tm.store.State(ctx, conversationID)
+		if err != nil {
+			return fmt.Errorf("failed to force escalation: %w", err)
+		}
+		if state.AgentMessageTime == 0 {
+			return ErrConversationNotTracked
+		}
+		if state.Paused() {
+			return ErrConversationPaused
+		}
+
+		policy := tm.evaluator.Select(state.Attributes)
+		if level <= state.Level || level > len(policy.Levels) {
+			return ErrInvalidLevel
+		}
+
+		forced, err := tm.store.Force(ctx, conversationID, state, level, float64(tm.clock.Now().UnixMilli()))
+		if err != nil {
+			tm.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to force escalation")
+			return fmt.Errorf("failed to force escalation: %w", err)
+		}
+		if forced {
+			tm.record(ctx, conversationID, models.HistoryEscalationForced, actorID, level, "")
+			tm.logger.WithFields(logrus.Fields{
+				"conversation_id": conversationID,
+				"level":           level,
+			}).Info("Forced conversation escalation")
+			return nil
+		}
+	}
+	return ErrConcurrentUpdate
+}
@@ -0,0 +1,91 @@
+package phase1
+
+import "redis-timeout-tracking-poc/pkg/shard"
+
+// Base names of the per-shard keys; see KeysFor
+const (
+	LeaderKey = "timeout:leader"
+	// LeaderEpochKey counts leadership acquisitions; its value is the fencing
+	// token of the current leader
+	LeaderEpochKey = "timeout:leader:epoch"
+	// WaitingConversationsKey scores waiting conversations by their next
+	// escalation deadline
+	WaitingConversationsKey = "waiting_conversations"
+	// WaitingSinceKey scores the same conversations by agent message time
+	WaitingSinceKey       = "waiting_since"
+	NotificationStatesKey = "notification_states"
+	// ConversationAttributesKey holds the policy-selection attributes of each
+	// waiting conversation as JSON
+	ConversationAttributesKey = "conversation_attributes"
+	// PausedConversationsKey holds when (ms) each paused or snoozed
+	// conversation's clock was stopped
+	PausedConversationsKey = "paused_conversations"
+	// ForcedLevelsKey holds the level a conversation was manually escalated
+	// to, until the detector reaches it
+	ForcedLevelsKey = "forced_levels"
+	// ConversationHistoryKey prefixes the list holding each conversation's
+	// timeline
+	ConversationHistoryKey = "conversation_history"
+	// EventClockKey prefixes the time (ms) of the latest agent message or
+	// customer response applied to each conversation
+	EventClockKey = "event_clock"
+	// SeenMessagesKey prefixes the message IDs recently applied to each
+	// conversation
+	SeenMessagesKey = "seen_messages"
+	MetricsKey      = "metrics:timeouts"
+)
+
+// MembersKey scores the pods taking part in detection by their last
+// heartbeat (ms)
+const MembersKey = "timeout:members"
+
+// ShardKeys names the Redis keys of one detection shard: its waiting
+// conversations and their state, and the lease of the pod detecting them
+type ShardKeys struct {
+	Shard              int
+	Waiting            string
+	WaitingSince       string
+	NotificationStates string
+	Attributes         string
+	Paused             string
+	ForcedLevels       string
+	// HistoryPrefix followed by a conversation ID names its timeline; see
+	// History
+	HistoryPrefix string
+	Leader        string
+	LeaderEpoch   string
+}
+
+// KeysFor returns the keys of a shard, e.g. "waiting_conversations:{3}"
+func KeysFor(s int) ShardKeys {
+	return ShardKeys{
+		Shard:              s,
+		Waiting:            shard.Key(WaitingConversationsKey, s),
+		WaitingSince:       shard.Key(WaitingSinceKey, s),
+		NotificationStates: shard.Key(NotificationStatesKey, s),
+		Attributes:         shard.Key(ConversationAttributesKey, s),
+		Paused:             shard.Key(PausedConversationsKey, s),
+		ForcedLevels:       shard.Key(ForcedLevelsKey, s),
+		HistoryPrefix:      shard.Key(ConversationHistoryKey, s) + ":",
+		Leader:             shard.Key(LeaderKey, s),
+		LeaderEpoch:        shard.Key(LeaderEpochKey, s),
+	}
+}
+
+// History returns the key of a conversation's timeline in the shard, e.g.
+// "conversation_history:{3}:conv_123"
+func (k ShardKeys) History(conversationID string) string {
+	return k.HistoryPrefix + conversationID
+}
+
+// EventClock returns the key of the time of the latest event applied to a
+// conversation in the shard, e.g. "event_clock:{3}:conv_123"
+func (k ShardKeys) EventClock(conversationID string) string {
+	return shard.Key(EventClockKey, k.Shard) + ":" + conversationID
+}
+
+// SeenMessages returns the key of the message IDs recently applied to a
+// conversation in the shard, e.g. "seen_messages:{3}:conv_123"
+func (k ShardKeys) SeenMessages(conversationID string) string {
+	return shard.Key(SeenMessagesKey, k.Shard) + ":" + conversationID
+}
@@ -2,100 +2,194 @@ package phase1
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/notifier"
 )
 
-const (
-	LeaderKey               = "timeout:leader"
-	WaitingConversationsKey = "waiting_conversations"
-	NotificationStatesKey   = "notification_states"
-	MetricsKey              = "metrics:timeouts"
-)
-
+// LeaderElection is the lease on one detection shard. A Coordinator decides
+// when this pod campaigns for it; while the pod holds it, it detects the
+// shard's timeouts.
 type LeaderElection struct {
-	rdb      *redis.Client
-	config   *config.Config
-	logger   *logrus.Logger
-	metrics  *metrics.Metrics
-	isLeader bool
-	stopCh   chan struct{}
+	rdb       redis.UniversalClient
+	store     TimeoutStore
+	config    *config.Config
+	logger    *logrus.Logger
+	metrics   *metrics.Metrics
+	notifier  notifier.Notifier
+	evaluator *escalation.Evaluator
+	clock     clock.Clock
+	keys      ShardKeys
+
+	// token is the fencing token while this pod is leader and 0 otherwise;
+	// leaseDeadline (unix ns) is when its lock expires unless renewed. Both
+	// are read without locking; mu serializes transitions so callbacks see
+	// them in order.
+	token         atomic.Int64
+	leaseDeadline atomic.Int64
+	mu            sync.Mutex
+	onElected     []func()
+	onRevoked     []func()
 }
 
-func NewLeaderElection(rdb *redis.Client, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics) *LeaderElection {
+// NewLeaderElection creates the election for a detection shard, held in rdb.
+// When started with Start, it escalates the shard's conversations in store
+// according to evaluator and sends timeout notifications through notifier
+// while this pod is leader. The last three may be nil when Start isn't used,
+// as in Phase 2.
+func NewLeaderElection(rdb redis.UniversalClient, store TimeoutStore, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics, notifier notifier.Notifier, evaluator *escalation.Evaluator, clock clock.Clock, shard int) *LeaderElection {
 	return &LeaderElection{
-		rdb:     rdb,
-		config:  config,
-		logger:  logger,
-		metrics: metrics,
-		stopCh:  make(chan struct{}),
+		rdb:       rdb,
+		store:     store,
+		config:    config,
+		logger:    logger,
+		metrics:   metrics,
+		notifier:  notifier,
+		evaluator: evaluator,
+		clock:     clock,
+		keys:      KeysFor(shard),
 	}
 }
 
+// Start runs the Phase 1 timeout checker for the shard whenever this pod is
+// its leader
 func (le *LeaderElection) Start(ctx context.Context) error {
-	le.logger.Info("Starting leader election process")
+	// The callbacks are serialized, so cancel needs no lock of its own
+	var cancel context.CancelFunc
+	le.OnElected(func() {
+		var checkCtx context.Context
+		checkCtx, cancel = context.WithCancel(ctx)
+		go le.timeoutCheckLoop(checkCtx)
+	})
+	le.OnRevoked(func() {
+		cancel()
+	})
 
-	// Try to become leader immediately
-	go le.leaderElectionLoop(ctx)
+	return nil
+}
 
-	// Start timeout checking loop
-	go le.timeoutCheckLoop(ctx)
+// Shard returns the number of the shard this election is for
+func (le *LeaderElection) Shard() int {
+	return le.keys.Shard
+}
 
-	return nil
+// Keys returns the Redis keys of the shard this election is for
+func (le *LeaderElection) Keys() ShardKeys {
+	return le.keys
 }
 
-func (le *LeaderElection) Stop() {
-	close(le.stopCh)
-	if le.isLeader {
-		le.resignLeadership(context.Background())
-	}
+// OnElected registers fn to run each time this pod becomes leader. Callbacks
+// run in registration order on the goroutine making the transition; they must
+// not block or call back into the election. Register them before starting the
+// Coordinator.
+func (le *LeaderElection) OnElected(fn func()) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	le.onElected = append(le.onElected, fn)
+}
+
+// OnRevoked registers fn to run each time this pod stops being leader, whether
+// it resigned, failed to renew or was fenced off. The same rules as OnElected
+// apply.
+func (le *LeaderElection) OnRevoked(fn func()) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	le.onRevoked = append(le.onRevoked, fn)
 }
 
+// IsLeader reports whether this pod currently holds leadership: it was
+// elected and its lease hasn't run out since the last successful renewal. It
+// doesn't call Redis, so a leader that can't reach Redis stops being one when
+// its lease would have expired there.
 func (le *LeaderElection) IsLeader() bool {
-	// Always verify leadership status against Redis
-	ctx := context.Background()
-	currentLeader, err := le.rdb.Get(ctx, LeaderKey).Result()
-	if err != nil {
-		le.isLeader = false
-		return false
+	return le.token.Load() > 0 && le.clock.Now().UnixNano() < le.leaseDeadline.Load()
+}
+
+// FencingToken returns the token issued when this pod last became leader, or 0
+// when it isn't leader. Detector writes carry it so Redis can reject them once
+// a newer leader has been elected.
+func (le *LeaderElection) FencingToken() int64 {
+	return le.token.Load()
+}
+
+// StepDown marks this pod as no longer leader after one of its writes was
+// fenced off. The lock in Redis is left alone; it belongs to the newer leader.
+func (le *LeaderElection) StepDown() {
+	token := le.token.Load()
+	if le.revoke() {
+		le.logger.WithFields(logrus.Fields{
+			"shard":         le.keys.Shard,
+			"fencing_token": token,
+		}).Warn("Fencing token is stale - stepping down")
 	}
+}
 
-	isActualLeader := currentLeader == le.config.PodID
-	if le.isLeader != isActualLeader {
-		// Update local state to match Redis truth
-		le.isLeader = isActualLeader
-		if isActualLeader {
-			le.logger.Info("Confirmed leadership from Redis")
-		} else {
-			le.logger.Info("Leadership lost - not in Redis")
-		}
+// elect records token as this pod's fencing token, running the OnElected
+// callbacks if it wasn't already leader
+func (le *LeaderElection) elect(token int64) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	if le.token.Swap(token) > 0 {
+		return // Re-acquired after the lock lapsed; still leader
 	}
 
-	return le.isLeader
+	le.logger.WithFields(logrus.Fields{
+		"shard":         le.keys.Shard,
+		"fencing_token": token,
+	}).Info("Became leader")
+	le.metrics.TimeoutLeaderChanges.Inc()
+	for _, fn := range le.onElected {
+		fn()
+	}
 }
 
-func (le *LeaderElection) leaderElectionLoop(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
+// revoke clears leadership, running the OnRevoked callbacks. It returns false
+// when this pod wasn't leader.
+func (le *LeaderElection) revoke() bool {
+	le.mu.Lock()
+	defer le.mu.Unlock()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-le.stopCh:
-			return
-		case <-ticker.C:
-			le.tryBecomeLeader(ctx)
-		}
+	if le.token.Swap(0) == 0 {
+		return false
+	}
+
+	for _, fn := range le.onRevoked {
+		fn()
+	}
+	return true
+}
+
+// Campaign runs one election round: it renews the lock while this pod holds
+// it and otherwise tries to take it
+func (le *LeaderElection) Campaign(ctx context.Context) {
+	if le.FencingToken() == 0 {
+		le.tryBecomeLeader(ctx)
+		return
+	}
+
+	if !le.IsLeader() {
+		// Not renewed in time; Redis may already have handed the lock on
+		le.logger.WithField("shard", le.keys.Shard).Warn("Leader lease expired - stepping down")
+		le.revoke()
+		le.tryBecomeLeader(ctx)
+		return
 	}
+	le.renewLeadership(ctx)
 }
 
 func (le *LeaderElection) tryBecomeLeader(ctx context.Context) {
@@ -104,34 +198,19 @@ func (le *LeaderElection) tryBecomeLeader(ctx context.Context) {
 		le.metrics.LeaderElectionDuration.Observe(time.Since(start).Seconds())
 	}()
 
-	result := le.rdb.SetArgs(ctx, LeaderKey, le.config.PodID, redis.SetArgs{
-		Mode: "NX",
-		TTL:  le.config.LeaderElectionTTLDuration(),
-	})
-
-	if result.Err() != nil {
-		le.logger.WithError(result.Err()).Error("Failed to attempt leader election")
+	// The lease is counted from before the request, so it never outlasts the
+	// lock in Redis
+	sent := le.clock.Now()
+	keys := []string{le.keys.Leader, le.keys.LeaderEpoch}
+	token, err := acquireLeadershipScript.Run(ctx, le.rdb, keys, le.config.PodID, le.config.LeaderElectionTTLDuration().Milliseconds()).Int64()
+	if err != nil {
+		le.logger.WithError(err).Error("Failed to attempt leader election")
 		return
 	}
 
-	if result.Val() == "OK" {
-		if !le.isLeader {
-			le.logger.Info("Became leader")
-			le.metrics.TimeoutLeaderChanges.Inc()
-			le.isLeader = true
-		}
-		// Renew leadership
-		le.renewLeadership(ctx)
-	} else {
-		// Failed to acquire leadership, check if we think we're leader but aren't
-		if le.isLeader {
-			// Double-check by reading the current leader from Redis
-			currentLeader, err := le.rdb.Get(ctx, LeaderKey).Result()
-			if err != nil || currentLeader != le.config.PodID {
-				le.logger.Info("Lost leadership")
-				le.isLeader = false
-			}
-		}
+	if token > 0 {
+		le.extendLease(sent)
+		le.elect(token)
 	}
 }
 
@@ -139,26 +218,42 @@ func (le *LeaderElection) renewLeadership(ctx context.Context) {
 	// Check if we're still the leader and extend TTL
 	script := `
 		if redis.call("GET", KEYS[1]) == ARGV[1] then
-			return redis.call("EXPIRE", KEYS[1], ARGV[2])
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
 		else
 			return 0
 		end
 	`
 
-	result := le.rdb.Eval(ctx, script, []string{LeaderKey}, le.config.PodID, le.config.LeaderElectionTTL)
-	if result.Err() != nil {
-		le.logger.WithError(result.Err()).Error("Failed to renew leadership")
-		le.isLeader = false
+	sent := le.clock.Now()
+	renewed, err := le.rdb.Eval(ctx, script, []string{le.keys.Leader}, le.config.PodID, le.config.LeaderElectionTTLDuration().Milliseconds()).Int()
+	if err != nil {
+		// Still leader until the lease runs out; the next round retries
+		le.logger.WithError(err).Error("Failed to renew leadership")
 		return
 	}
 
-	if result.Val().(int64) == 0 {
-		le.logger.Warn("Leadership renewal failed - no longer leader")
-		le.isLeader = false
+	if renewed == 0 {
+		le.logger.WithField("shard", le.keys.Shard).Warn("Leadership renewal failed - no longer leader")
+		le.revoke()
+		return
 	}
+	le.extendLease(sent)
+}
+
+// extendLease records that the lock was set or renewed by a request sent at
+// sent
+func (le *LeaderElection) extendLease(sent time.Time) {
+	le.leaseDeadline.Store(sent.Add(le.config.LeaderElectionTTLDuration()).UnixNano())
+}
+
+// jitter returns d moved up or down at random by up to fraction of it
+func jitter(d time.Duration, fraction float64) time.Duration {
+	return d + time.Duration((rand.Float64()*2-1)*fraction*float64(d))
 }
 
-func (le *LeaderElection) resignLeadership(ctx context.Context) {
+// Resign gives up the lock, if this pod holds it, so another pod can take the
+// shard straight away
+func (le *LeaderElection) Resign(ctx context.Context) {
 	script := `
 		if redis.call("GET", KEYS[1]) == ARGV[1] then
 			return redis.call("DEL", KEYS[1])
@@ -167,27 +262,27 @@ func (le *LeaderElection) resignLeadership(ctx context.Context) {
 		end
 	`
 
-	result := le.rdb.Eval(ctx, script, []string{LeaderKey}, le.config.PodID)
+	result := le.rdb.Eval(ctx, script, []string{le.keys.Leader}, le.config.PodID)
 	if result.Err() != nil {
 		le.logger.WithError(result.Err()).Error("Failed to resign leadership")
 	} else {
-		le.logger.Info("Resigned leadership")
+		le.logger.WithField("shard", le.keys.Shard).Info("Resigned leadership")
 	}
-	le.isLeader = false
+	le.revoke()
 }
 
+// timeoutCheckLoop checks the shard's timeouts every check interval until ctx
+// is cancelled, which Start does when leadership is revoked
 func (le *LeaderElection) timeoutCheckLoop(ctx context.Context) {
-	ticker := time.NewTicker(le.config.CheckInterval())
+	ticker := le.clock.NewTicker(le.config.CheckInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-le.stopCh:
-			return
-		case <-ticker.C:
-			if le.isLeader {
+		case <-ticker.C():
+			if le.IsLeader() {
 				le.checkTimeouts(ctx)
 			}
 		}
@@ -200,103 +295,187 @@ func (le *LeaderElection) checkTimeouts(ctx context.Context) {
 		le.metrics.TimeoutCheckDuration.Observe(time.Since(start).Seconds())
 	}()
 
-	now := time.Now().UnixMilli()
+	now := le.clock.Now().UnixMilli()
 
-	// Get all waiting conversations
-	conversations, err := le.rdb.ZRangeByScoreWithScores(ctx, WaitingConversationsKey, &redis.ZRangeBy{
-		Min: "0",
-		Max: fmt.Sprintf("%d", now),
-	}).Result()
-
-	if err != nil {
-		le.logger.WithError(err).Error("Failed to get waiting conversations")
-		return
+	if count, err := le.store.Waiting(ctx, le.keys.Shard); err == nil {
+		le.metrics.WaitingConversationsCount.WithLabelValues(strconv.Itoa(le.keys.Shard)).Set(float64(count))
 	}
 
-	le.metrics.WaitingConversationsCount.Set(float64(len(conversations)))
+	// Fetch only conversations whose next deadline has passed, a batch at a
+	// time. Handled conversations are rescheduled out of the range; the rest
+	// stay at the front and are skipped with the offset.
+	batchSize := le.config.DetectionBatch()
+	var offset int64
+	for {
+		conversations, err := le.store.Due(ctx, le.keys.Shard, now, offset, batchSize)
+		if err != nil {
+			le.logger.WithError(err).Error("Failed to get waiting conversations")
+			return
+		}
 
-	for _, conv := range conversations {
-		conversationID := conv.Member.(string)
-		startTime := int64(conv.Score)
+		for _, conversationID := range conversations {
+			if !le.processConversationTimeout(ctx, conversationID, now) {
+				offset++
+			}
+			if !le.IsLeader() {
+				return // Lease lost or fenced off by a newer leader
+			}
+		}
 
-		le.processConversationTimeout(ctx, conversationID, startTime, now)
+		if int64(len(conversations)) < batchSize {
+			return
+		}
 	}
 }
 
-func (le *LeaderElection) processConversationTimeout(ctx context.Context, conversationID string, startTime, now int64) {
-	waitTime := now - startTime
-	timeoutInterval := le.config.TimeoutIntervalMS
-
-	// Get current notification level
-	currentLevelStr, err := le.rdb.HGet(ctx, NotificationStatesKey, conversationID).Result()
-	if err != nil && err != redis.Nil {
+// processConversationTimeout escalates a due conversation and sends its
+// notification. It returns true when the conversation has left the due range,
+// whether moved to its next deadline or cleared, re-tracked or paused since
+// the scan, and false when it is still due and the scan must skip it.
+func (le *LeaderElection) processConversationTimeout(ctx context.Context, conversationID string, now int64) bool {
+	state, err := le.store.State(ctx, conversationID)
+	if err != nil {
 		le.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to get notification state")
-		return
+		return false
 	}
-
-	currentLevel := 0
-	if currentLevelStr != "" {
-		if level, err := strconv.Atoi(currentLevelStr); err == nil {
-			currentLevel = level
+	if state.AgentMessageTime == 0 {
+		if state.Deadline != 0 {
+			// Scheduled without an agent message time, e.g. left behind by a
+			// half-applied clear; nothing to escalate, so skip it
+			le.logger.WithField("conversation_id", conversationID).Warn("Skipping waiting conversation without an agent message time")
+			return false
 		}
+		return true // Cleared since the scan
+	}
+	if state.Paused() {
+		// Due at the end of its snooze
+		resumed, err := ResumeSnoozed(ctx, le.store, le.evaluator, conversationID, state)
+		if err != nil {
+			le.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to resume snoozed conversation")
+			return false
+		}
+		if resumed {
+			le.record(ctx, conversationID, models.HistoryResumed, state.Level, "snooze ended")
+		}
+		// Rescheduled, or changed since the scan
+		return true
 	}
 
-	// Check for timeout levels
-	var newLevel int
-	if waitTime > timeoutInterval*3 && currentLevel < 3 {
-		newLevel = 3
-	} else if waitTime > timeoutInterval*2 && currentLevel < 2 {
-		newLevel = 2
-	} else if waitTime > timeoutInterval && currentLevel < 1 {
-		newLevel = 1
-	} else {
-		return // No new notification needed
+	// Record the new level only if the conversation is unchanged since it
+	// was read, so a customer response or re-track landing in between can't
+	// be escalated
+	policy, newLevel, applied, err := le.escalate(ctx, conversationID, state, now)
+	if errors.Is(err, ErrStaleFencingToken) {
+		le.StepDown()
+		return false
 	}
+	if err != nil {
+		le.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to update notification state")
+		return false
+	}
+	if !applied {
+		return true // Changed since the scan, so no longer due
+	}
+	if newLevel == 0 {
+		return true // No new notification needed
+	}
+	le.record(ctx, conversationID, models.HistoryEscalated, newLevel, le.evaluator.Level(policy, newLevel).Name)
 
-	// Send notification and update state
-	if err := le.sendNotification(ctx, conversationID, newLevel, startTime); err != nil {
+	// Send notification, handing the level back if delivery may succeed on a
+	// later tick
+	startTime := state.AgentMessageTime
+	if err := le.sendNotification(ctx, conversationID, policy, newLevel, startTime); err != nil {
 		le.logger.WithError(err).WithFields(logrus.Fields{
 			"conversation_id": conversationID,
 			"level":           newLevel,
 		}).Error("Failed to send notification")
-		return
-	}
-
-	// Update notification state
-	if err := le.rdb.HSet(ctx, NotificationStatesKey, conversationID, newLevel).Err(); err != nil {
-		le.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to update notification state")
-		return
+		le.record(ctx, conversationID, models.HistoryNotificationFailed, newLevel, err.Error())
+
+		if notifier.IsRetryable(err) {
+			reverted, err := le.store.Revert(ctx, conversationID, newLevel, state.Level, state.Deadline, le.FencingToken())
+			if errors.Is(err, ErrStaleFencingToken) {
+				le.StepDown()
+			} else if err != nil {
+				le.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to revert notification state")
+			}
+			// Due again only when the level was handed back
+			return !reverted
+		}
+		return true
 	}
 
 	le.metrics.TimeoutNotificationsSent.WithLabelValues(fmt.Sprintf("level%d", newLevel)).Inc()
+	le.record(ctx, conversationID, models.HistoryNotificationDelivered, newLevel, "")
 
 	le.logger.WithFields(logrus.Fields{
 		"conversation_id": conversationID,
 		"level":           newLevel,
-		"wait_time_ms":    waitTime,
+		"wait_time_ms":    now - startTime,
 	}).Info("Sent timeout notification")
+
+	return true
 }
 
-func (le *LeaderElection) sendNotification(ctx context.Context, conversationID string, level int, startTime int64) error {
-	// In a real implementation, this would call your notification service
-	// For POC, we'll just log and update metrics
+// escalate evaluates the conversation's escalation policy against state and
+// records the level it has reached and its next deadline. It returns the
+// policy used and the new level, which is 0 when the conversation was only
+// rescheduled; applied is false when the conversation changed after state was
+// read and nothing was written.
+func (le *LeaderElection) escalate(ctx context.Context, conversationID string, state ConversationState, now int64) (policy *escalation.Policy, newLevel int, applied bool, err error) {
+	policy = le.evaluator.Select(state.Attributes)
+	newLevel, due := EscalationLevel(le.evaluator, policy, state, now)
+
+	level := state.Level
+	if due {
+		level = newLevel
+	}
+	deadline := DeadlineScore(le.evaluator.NextDeadlineMS(policy, state.Attributes, state.AgentMessageTime, level))
+
+	applied, err = le.store.Advance(ctx, conversationID, state, level, deadline, le.FencingToken())
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	return policy, newLevel, applied, nil
+}
 
+// record appends an event this pod caused now to a conversation's timeline
+func (le *LeaderElection) record(ctx context.Context, conversationID, eventType string, level int, detail string) {
+	RecordHistory(ctx, le.store, le.logger, conversationID, models.HistoryEvent{
+		Type:      eventType,
+		Timestamp: le.clock.Now(),
+		ActorID:   le.config.PodID,
+		Level:     level,
+		Detail:    detail,
+	})
+}
+
+func (le *LeaderElection) sendNotification(ctx context.Context, conversationID string, policy *escalation.Policy, level int, startTime int64) error {
+	levelDef := le.evaluator.Level(policy, level)
 	notification := models.TimeoutEvent{
+		EventID:          models.TimeoutEventID(conversationID, time.UnixMilli(startTime), level),
 		ConversationID:   conversationID,
 		Level:            level,
+		LevelName:        levelDef.Name,
+		Action:           levelDef.Action,
+		Policy:           policy.Name,
 		AgentMessageTime: time.UnixMilli(startTime),
-		DetectedAt:       time.Now(),
+		DetectedAt:       le.clock.Now(),
 		Attempt:          1,
+		Shard:            le.keys.Shard,
+	}
+	if le.rdb != nil {
+		// Nobody can watch a detector running on a MemoryStore
+		PublishEscalation(ctx, le.rdb, le.logger, notification)
 	}
 
 	le.logger.WithFields(logrus.Fields{
 		"conversation_id": notification.ConversationID,
 		"level":           notification.Level,
+		"level_name":      notification.LevelName,
+		"action":          notification.Action,
 		"detected_at":     notification.DetectedAt,
 	}).Info("Sending timeout notification")
 
-	// TODO: Replace with actual notification service call
-	// Example: notificationService.SendTimeoutAlert(notification)
-
-	return nil
+	return le.notifier.Notify(ctx, notification)
 }
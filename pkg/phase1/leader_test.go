@@ -0,0 +1,827 @@
+package phase1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
+	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/notifier"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+type recordingNotifier struct {
+	events []models.TimeoutEvent
+	err    error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event models.TimeoutEvent) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.events = append(n.events, event)
+	return nil
+}
+
+func testEvaluator(t *testing.T, cfg *config.Config) *escalation.Evaluator {
+	evaluator, err := escalation.NewEvaluator(escalation.DefaultPolicySet([]int64{1, 2, 3}), cfg.TimeoutIntervalMS)
+	require.NoError(t, err)
+	return evaluator
+}
+
+// becomeLeader makes le the leader, as a tick of the election loop would
+func becomeLeader(t *testing.T, le *LeaderElection) {
+	t.Helper()
+	le.tryBecomeLeader(context.Background())
+	require.NotZero(t, le.FencingToken())
+}
+
+func TestLeaderElection_ProcessConversationTimeout(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	now := clk.Now()
+	agentMsg := models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		MessageID:      "msg_789",
+		Timestamp:      now.Add(-2500 * time.Millisecond),
+	}
+	require.NoError(t, tm.TrackAgentMessage(ctx, agentMsg))
+
+	startTime := agentMsg.Timestamp.UnixMilli()
+	assert.True(t, le.processConversationTimeout(ctx, agentMsg.ConversationID, now.UnixMilli()))
+
+	level, err := tm.GetNotificationState(ctx, agentMsg.ConversationID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, level)
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, 2, recorder.events[0].Level)
+	assert.Equal(t, models.TimeoutEventID(agentMsg.ConversationID, agentMsg.Timestamp, 2), recorder.events[0].EventID)
+
+	// Rescheduled to the 3N deadline
+	deadline, err := rdb.ZScore(ctx, testKeys.Waiting, agentMsg.ConversationID).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(startTime+3000), deadline)
+
+	// Nothing new is due until 3N
+	state, err := store.State(ctx, agentMsg.ConversationID)
+	require.NoError(t, err)
+	_, newLevel, applied, err := le.escalate(ctx, agentMsg.ConversationID, state, now.UnixMilli())
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, 0, newLevel)
+
+	_, newLevel, applied, err = le.escalate(ctx, agentMsg.ConversationID, state, now.Add(time.Second).UnixMilli())
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, 3, newLevel)
+
+	// Past the last level it is parked until cleared
+	deadline, err = rdb.ZScore(ctx, testKeys.Waiting, agentMsg.ConversationID).Result()
+	assert.NoError(t, err)
+	assert.True(t, math.IsInf(deadline, 1))
+}
+
+func TestLeaderElection_ProcessConversationTimeout_SelectsPolicy(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	set := escalation.DefaultPolicySet([]int64{2})
+	set.Policies = append(set.Policies, escalation.Policy{
+		Name:   "urgent",
+		Levels: []escalation.Level{{Name: "page", Multiplier: 1, Action: "page_supervisor"}},
+	})
+	set.Rules = []escalation.Rule{{Match: escalation.Match{Priority: "high"}, Policy: "urgent"}}
+	evaluator, err := escalation.NewEvaluator(set, cfg.TimeoutIntervalMS)
+	require.NoError(t, err)
+
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	now := clk.Now()
+	startTime := now.Add(-1500 * time.Millisecond)
+	for _, msg := range []models.AgentMessage{
+		{ConversationID: "normal_conv", AgentID: "agent_1", MessageID: "msg_1", Timestamp: startTime},
+		{ConversationID: "urgent_conv", AgentID: "agent_1", MessageID: "msg_2", Timestamp: startTime, Priority: "high"},
+	} {
+		require.NoError(t, tm.TrackAgentMessage(ctx, msg))
+		le.processConversationTimeout(ctx, msg.ConversationID, now.UnixMilli())
+	}
+
+	// Only the high priority conversation is past its first threshold
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, "urgent_conv", recorder.events[0].ConversationID)
+	assert.Equal(t, "urgent", recorder.events[0].Policy)
+	assert.Equal(t, "page_supervisor", recorder.events[0].Action)
+
+	// Attributes go away with the conversation
+	require.NoError(t, tm.ClearTimeout(ctx, models.CustomerResponse{
+		ConversationID: "urgent_conv",
+		CustomerID:     "customer_1",
+		MessageID:      "msg_3",
+		Timestamp:      now,
+	}))
+	exists, err := rdb.HExists(ctx, testKeys.Attributes, "urgent_conv").Result()
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestLeaderElection_ProcessConversationTimeout_StaleScan(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, &recordingNotifier{}, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	now := clk.Now()
+	scannedStart := now.Add(-5 * time.Second)
+
+	for _, conversationID := range []string{"retracked_conv", "cleared_conv"} {
+		require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+			ConversationID: conversationID,
+			AgentID:        "agent_456",
+			MessageID:      "msg_1",
+			Timestamp:      scannedStart,
+		}))
+	}
+
+	// Re-tracked with a newer agent message after it was read
+	state, err := store.State(ctx, "retracked_conv")
+	require.NoError(t, err)
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "retracked_conv",
+		AgentID:        "agent_456",
+		MessageID:      "msg_2",
+		Timestamp:      now,
+	}))
+	_, _, applied, err := le.escalate(ctx, "retracked_conv", state, now.UnixMilli())
+	assert.NoError(t, err)
+	assert.False(t, applied)
+
+	exists, err := rdb.HExists(ctx, testKeys.NotificationStates, "retracked_conv").Result()
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	deadline, err := rdb.ZScore(ctx, testKeys.Waiting, "retracked_conv").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(now.UnixMilli()+1000), deadline)
+
+	// Cleared by a customer response after it was read
+	state, err = store.State(ctx, "cleared_conv")
+	require.NoError(t, err)
+	require.NoError(t, tm.ClearTimeout(ctx, models.CustomerResponse{
+		ConversationID: "cleared_conv",
+		CustomerID:     "customer_123",
+		MessageID:      "msg_4",
+		Timestamp:      now,
+	}))
+	_, _, applied, err = le.escalate(ctx, "cleared_conv", state, now.UnixMilli())
+	assert.NoError(t, err)
+	assert.False(t, applied)
+
+	exists, err = rdb.HExists(ctx, testKeys.NotificationStates, "cleared_conv").Result()
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// Not re-added to the deadline index
+	_, err = rdb.ZScore(ctx, testKeys.Waiting, "cleared_conv").Result()
+	assert.Equal(t, redis.Nil, err)
+}
+
+func TestLeaderElection_ProcessConversationTimeout_StaleFencingToken(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "old-leader",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	oldLeader := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, oldLeader)
+
+	ctx := context.Background()
+	now := clk.Now()
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		MessageID:      "msg_789",
+		Timestamp:      now.Add(-1500 * time.Millisecond),
+	}))
+
+	// The old leader's lock expires while it is paused and another pod takes
+	// over with a higher token
+	require.NoError(t, rdb.Del(ctx, testKeys.Leader).Err())
+	newCfg := *cfg
+	newCfg.PodID = "new-leader"
+	newLeader := NewLeaderElection(rdb, store, &newCfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, newLeader)
+	assert.Greater(t, newLeader.FencingToken(), oldLeader.FencingToken())
+
+	assert.False(t, oldLeader.processConversationTimeout(ctx, "conv_123", now.UnixMilli()))
+	assert.False(t, oldLeader.IsLeader())
+	assert.Zero(t, oldLeader.FencingToken())
+	assert.Empty(t, recorder.events)
+
+	exists, err := rdb.HExists(ctx, testKeys.NotificationStates, "conv_123").Result()
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	// The new leader's writes go through
+	assert.True(t, newLeader.processConversationTimeout(ctx, "conv_123", now.UnixMilli()))
+	level, err := tm.GetNotificationState(ctx, "conv_123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, level)
+}
+
+func TestLeaderElection_ProcessConversationTimeout_NotificationFailure(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	failing := &recordingNotifier{err: errors.New("connection refused")}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, failing, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	now := clk.Now()
+	startTime := now.Add(-1500 * time.Millisecond)
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		MessageID:      "msg_789",
+		Timestamp:      startTime,
+	}))
+
+	// A retryable failure hands the level back and leaves it due for the
+	// next tick
+	assert.False(t, le.processConversationTimeout(ctx, "conv_123", now.UnixMilli()))
+
+	level, err := tm.GetNotificationState(ctx, "conv_123")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, level)
+
+	deadline, err := rdb.ZScore(ctx, testKeys.Waiting, "conv_123").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(startTime.UnixMilli()+1000), deadline)
+
+	// A permanent failure keeps it so the notification isn't retried forever
+	failing.err = notifier.Permanent(errors.New("webhook returned status 400"))
+	assert.True(t, le.processConversationTimeout(ctx, "conv_123", now.UnixMilli()))
+
+	level, err = tm.GetNotificationState(ctx, "conv_123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, level)
+
+	deadline, err = rdb.ZScore(ctx, testKeys.Waiting, "conv_123").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(startTime.UnixMilli()+2000), deadline)
+}
+
+func TestLeaderElection_CheckTimeouts_Batches(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:  1000,
+		DetectionBatchSize: 2,
+		PodID:              "test-pod",
+		LeaderElectionTTL:  10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	now := clk.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+			ConversationID: fmt.Sprintf("due_conv_%d", i),
+			AgentID:        "agent_456",
+			MessageID:      fmt.Sprintf("msg_%d", i),
+			Timestamp:      now.Add(-1500 * time.Millisecond),
+		}))
+	}
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "fresh_conv",
+		AgentID:        "agent_456",
+		MessageID:      "msg_fresh",
+		Timestamp:      now,
+	}))
+
+	// Failed deliveries stay due but don't stall the scan
+	recorder.err = errors.New("connection refused")
+	le.checkTimeouts(ctx)
+	assert.Empty(t, recorder.events)
+
+	recorder.err = nil
+	le.checkTimeouts(ctx)
+	assert.Len(t, recorder.events, 5)
+	for _, event := range recorder.events {
+		assert.NotEqual(t, "fresh_conv", event.ConversationID)
+	}
+
+	// Everything due has moved to its next deadline
+	due, err := rdb.ZCount(ctx, testKeys.Waiting, "-inf", fmt.Sprintf("(%d", clk.Now().UnixMilli())).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), due)
+}
+
+func TestLeaderElection_CheckTimeouts_EveryLevel(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 30000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		MessageID:      "msg_789",
+		Timestamp:      clk.Now(),
+	}))
+
+	for level := 1; level <= 3; level++ {
+		// Just short of the threshold nothing fires
+		clk.Advance(30*time.Second - time.Millisecond)
+		le.checkTimeouts(ctx)
+		require.Len(t, recorder.events, level-1)
+
+		clk.Advance(time.Millisecond)
+		le.checkTimeouts(ctx)
+		require.Len(t, recorder.events, level)
+		assert.Equal(t, level, recorder.events[level-1].Level)
+		assert.Equal(t, clk.Now(), recorder.events[level-1].DetectedAt)
+	}
+
+	// Nothing after the last level
+	clk.Advance(time.Hour)
+	le.checkTimeouts(ctx)
+	assert.Len(t, recorder.events, 3)
+}
+
+func TestLeaderElection_CheckTimeouts_Orphan(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:  30000,
+		PodID:              "test-pod",
+		LeaderElectionTTL:  10,
+		DetectionBatchSize: 1,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		Timestamp:      clk.Now(),
+	}))
+
+	// Due first, with nothing else of the conversation left
+	require.NoError(t, rdb.ZAdd(ctx, KeysFor(0).Waiting, &redis.Z{Score: 1, Member: "orphan"}).Err())
+
+	// Skipped rather than re-read forever, so the scan gets past it
+	clk.Advance(30 * time.Second)
+	le.extendLease(clk.Now())
+	done := make(chan struct{})
+	go func() {
+		le.checkTimeouts(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("checkTimeouts didn't get past the orphan")
+	}
+
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, "conv_123", recorder.events[0].ConversationID)
+}
+
+func TestLeaderElection_CheckTimeouts_MemoryStore(t *testing.T) {
+	// Detection runs against any TimeoutStore; no Redis is involved here
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+		ShardCount:        2,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewMemoryStore(cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(nil, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	le.elect(store.NewEpoch(0))
+	le.extendLease(clk.Now())
+
+	ctx := context.Background()
+	var ids []string
+	for i := 0; len(ids) < 3; i++ {
+		id := fmt.Sprintf("conv_%d", i)
+		if shard.For(id, cfg.Shards()) == 0 {
+			ids = append(ids, id)
+		}
+		require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+			ConversationID: id,
+			AgentID:        "agent_456",
+			Timestamp:      clk.Now(),
+		}))
+	}
+
+	// Only this shard's conversations are escalated
+	clk.Advance(time.Second)
+	le.checkTimeouts(ctx)
+	require.Len(t, recorder.events, len(ids))
+	for _, event := range recorder.events {
+		assert.Contains(t, ids, event.ConversationID)
+		assert.Equal(t, 1, event.Level)
+	}
+
+	// A newer leader fences this one off
+	store.NewEpoch(0)
+	clk.Advance(time.Second)
+	le.checkTimeouts(ctx)
+	assert.Len(t, recorder.events, len(ids))
+	assert.False(t, le.IsLeader())
+}
+
+func TestLeaderElection_CheckTimeouts_Holds(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.UnixMilli(1_700_000_000_000))
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	start := clk.Now()
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		Timestamp:      start,
+	}))
+
+	assert.ErrorIs(t, tm.ResumeConversation(ctx, "conv_123", "agent_456"), ErrConversationNotPaused)
+	assert.ErrorIs(t, tm.PauseConversation(ctx, "unknown", "agent_456"), ErrConversationNotTracked)
+
+	// Paused half a second in, the clock stops
+	clk.Advance(500 * time.Millisecond)
+	require.NoError(t, tm.PauseConversation(ctx, "conv_123", "agent_456"))
+	assert.ErrorIs(t, tm.ForceEscalation(ctx, "conv_123", 1, "supervisor_1"), ErrConversationPaused)
+	clk.Advance(5 * time.Second)
+	le.checkTimeouts(ctx)
+	assert.Empty(t, recorder.events)
+
+	// ...and restarts with the half second already waited
+	require.NoError(t, tm.ResumeConversation(ctx, "conv_123", "agent_456"))
+	status, err := tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusWaiting, status.Status)
+	assert.Equal(t, start.Add(5*time.Second).UnixMilli(), status.AgentMessageTime.UnixMilli())
+	clk.Advance(499 * time.Millisecond)
+	le.checkTimeouts(ctx)
+	assert.Empty(t, recorder.events)
+	clk.Advance(time.Millisecond)
+	le.checkTimeouts(ctx)
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, 1, recorder.events[0].Level)
+
+	// A snooze is resumed by detection once it ends, without counting it
+	require.NoError(t, tm.SnoozeConversation(ctx, "conv_123", clk.Now().Add(10*time.Second), "agent_456"))
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSnoozed, status.Status)
+	clk.Advance(10 * time.Second)
+	le.checkTimeouts(ctx)
+	assert.Len(t, recorder.events, 1)
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusWaiting, status.Status)
+	clk.Advance(time.Second)
+	le.checkTimeouts(ctx)
+	require.Len(t, recorder.events, 2)
+	assert.Equal(t, 2, recorder.events[1].Level)
+
+	// A forced escalation fires on the next check, however long it waited
+	assert.ErrorIs(t, tm.ForceEscalation(ctx, "conv_123", 2, "supervisor_1"), ErrInvalidLevel)
+	assert.ErrorIs(t, tm.ForceEscalation(ctx, "conv_123", 4, "supervisor_1"), ErrInvalidLevel)
+	require.NoError(t, tm.ForceEscalation(ctx, "conv_123", 3, "supervisor_1"))
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.Equal(t, 3, status.ForcedLevel)
+	le.checkTimeouts(ctx)
+	require.Len(t, recorder.events, 3)
+	assert.Equal(t, 3, recorder.events[2].Level)
+
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusExhausted, status.Status)
+	assert.Zero(t, status.ForcedLevel)
+}
+
+func TestLeaderElection_RecordsHistory(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+		HistoryTTL:        3600,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.UnixMilli(1_700_000_000_000))
+
+	evaluator := testEvaluator(t, cfg)
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, evaluator, clk)
+	recorder := &recordingNotifier{}
+	le := NewLeaderElection(rdb, store, cfg, logger, metrics, recorder, evaluator, clk, 0)
+	becomeLeader(t, le)
+
+	ctx := context.Background()
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		Timestamp:      clk.Now(),
+	}))
+
+	// A failed delivery, then a successful one
+	clk.Advance(time.Second)
+	recorder.err = errors.New("connection refused")
+	le.checkTimeouts(ctx)
+	recorder.err = nil
+	le.checkTimeouts(ctx)
+	require.Len(t, recorder.events, 1)
+
+	// Kept while the conversation is waiting
+	key := testKeys.History("conv_123")
+	ttl, err := rdb.PTTL(ctx, key).Result()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+
+	require.NoError(t, tm.ClearTimeout(ctx, models.CustomerResponse{
+		ConversationID: "conv_123",
+		CustomerID:     "customer_789",
+		Timestamp:      clk.Now(),
+	}))
+
+	history, err := tm.GetHistory(ctx, "conv_123")
+	require.NoError(t, err)
+	var types, actors []string
+	for _, event := range history {
+		types = append(types, event.Type)
+		actors = append(actors, event.ActorID)
+	}
+	assert.Equal(t, []string{
+		models.HistoryTracked,
+		models.HistoryEscalated,
+		models.HistoryNotificationFailed,
+		models.HistoryEscalated,
+		models.HistoryNotificationDelivered,
+		models.HistoryCleared,
+	}, types)
+	assert.Equal(t, []string{"agent_456", "test-pod", "test-pod", "test-pod", "test-pod", "customer_789"}, actors)
+	assert.Equal(t, 1, history[4].Level)
+	assert.Equal(t, "connection refused", history[2].Detail)
+
+	// ...and expires once it is closed
+	ttl, err = rdb.PTTL(ctx, key).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, float64(time.Hour), float64(ttl), float64(time.Minute))
+}
+
+func TestLeaderElection_Transitions(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	le := NewLeaderElection(rdb, NewRedisStore(rdb, cfg), cfg, logger, metrics, &recordingNotifier{}, testEvaluator(t, cfg), clk, 0)
+	var transitions []string
+	le.OnElected(func() { transitions = append(transitions, "elected") })
+	le.OnRevoked(func() { transitions = append(transitions, "revoked") })
+
+	ctx := context.Background()
+	le.tryBecomeLeader(ctx)
+	assert.True(t, le.IsLeader())
+
+	// Still leader on the next round
+	le.tryBecomeLeader(ctx)
+	assert.Equal(t, []string{"elected"}, transitions)
+
+	le.StepDown()
+	assert.False(t, le.IsLeader())
+	assert.Zero(t, le.FencingToken())
+	le.StepDown()
+	assert.Equal(t, []string{"elected", "revoked"}, transitions)
+
+	// Another pod holds the lock
+	require.NoError(t, rdb.Set(ctx, testKeys.Leader, "other-pod", 0).Err())
+	le.tryBecomeLeader(ctx)
+	assert.False(t, le.IsLeader())
+
+	require.NoError(t, rdb.Del(ctx, testKeys.Leader).Err())
+	le.tryBecomeLeader(ctx)
+	le.Resign(ctx)
+	assert.Equal(t, []string{"elected", "revoked", "elected", "revoked"}, transitions)
+}
+
+func TestLeaderElection_Lease(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		LeaderElectionTTL: 9,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	le := NewLeaderElection(rdb, NewRedisStore(rdb, cfg), cfg, logger, metrics, &recordingNotifier{}, testEvaluator(t, cfg), clk, 0)
+	revoked := 0
+	le.OnRevoked(func() { revoked++ })
+
+	ctx := context.Background()
+	le.Campaign(ctx)
+	require.True(t, le.IsLeader())
+
+	// Renewing pushes the lease out by a full TTL
+	clk.Advance(cfg.LeaderRenewInterval())
+	le.Campaign(ctx)
+	clk.Advance(cfg.LeaderElectionTTLDuration() - time.Millisecond)
+	assert.True(t, le.IsLeader())
+
+	// Without a renewal it runs out, even though nothing told us so
+	clk.Advance(time.Millisecond)
+	assert.False(t, le.IsLeader())
+	assert.Zero(t, revoked)
+
+	// The next round steps down. The lock is taken again, with a new token,
+	// only once it has expired in Redis too.
+	token := le.FencingToken()
+	le.Campaign(ctx)
+	assert.Equal(t, 1, revoked)
+	assert.False(t, le.IsLeader())
+
+	require.NoError(t, rdb.Del(ctx, testKeys.Leader).Err())
+	le.Campaign(ctx)
+	assert.True(t, le.IsLeader())
+	assert.Greater(t, le.FencingToken(), token)
+}
+
+func TestJitter(t *testing.T) {
+	interval := 3 * time.Second
+	for i := 0; i < 100; i++ {
+		d := jitter(interval, 0.1)
+		assert.GreaterOrEqual(t, d, 2700*time.Millisecond)
+		assert.LessOrEqual(t, d, 3300*time.Millisecond)
+	}
+}
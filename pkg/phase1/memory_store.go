@@ -0,0 +1,484 @@
+package phase1
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+// MemoryStore is a TimeoutStore kept in process memory, with the same
+// semantics as RedisStore. Nothing is shared between processes, so it suits
+// tests and single-instance runs. Its fencing tokens come from NewEpoch.
+// Histories and event clocks expire by wall-clock time, like Redis keys.
+type MemoryStore struct {
+	mu           sync.Mutex
+	shards       []*memoryShard
+	historyLimit int
+	historyTTL   time.Duration
+	dedupTTL     time.Duration
+}
+
+// memoryShard mirrors the keys of one shard in RedisStore
+type memoryShard struct {
+	waiting map[string]float64 // next deadline
+	since   map[string]int64   // agent message time
+	levels  map[string]int
+	attrs   map[string]models.ConversationAttributes
+	paused  map[string]int64 // when the clock stopped
+	forced  map[string]int
+	history map[string]*memoryHistory
+	clocks  map[string]*memoryClock
+	epoch   int64
+}
+
+// memoryHistory is a conversation's timeline; expires is zero while the
+// conversation is waiting
+type memoryHistory struct {
+	events  []models.HistoryEvent
+	expires time.Time
+}
+
+// memoryClock is the latest event applied to a conversation and the
+// timestamps of the message IDs recently applied to it
+type memoryClock struct {
+	latest  int64
+	seen    map[string]int64
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty store that spreads conversations over the
+// configured shards and keeps histories and event clocks as configured
+func NewMemoryStore(config *config.Config) *MemoryStore {
+	store := &MemoryStore{
+		shards:       make([]*memoryShard, config.Shards()),
+		historyLimit: config.HistoryEvents(),
+		historyTTL:   config.HistoryTTLDuration(),
+		dedupTTL:     config.EventDedupTTLDuration(),
+	}
+	for s := range store.shards {
+		store.shards[s] = &memoryShard{
+			waiting: make(map[string]float64),
+			since:   make(map[string]int64),
+			levels:  make(map[string]int),
+			attrs:   make(map[string]models.ConversationAttributes),
+			paused:  make(map[string]int64),
+			forced:  make(map[string]int),
+			history: make(map[string]*memoryHistory),
+			clocks:  make(map[string]*memoryClock),
+		}
+	}
+	return store
+}
+
+// NewEpoch issues a new fencing token for a shard, fencing off the holders of
+// earlier ones, as LeaderElection does when it acquires a shard's lock
+func (s *MemoryStore) NewEpoch(shard int) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shards[shard].epoch++
+	return s.shards[shard].epoch
+}
+
+func (s *MemoryStore) Track(ctx context.Context, conversationID, messageID string, agentMessageMS int64, deadline float64, attrs models.ConversationAttributes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if err := sh.applyEvent(conversationID, messageID, agentMessageMS, s.dedupTTL); err != nil {
+		return err
+	}
+
+	sh.waiting[conversationID] = deadline
+	sh.since[conversationID] = agentMessageMS
+	delete(sh.levels, conversationID)
+	delete(sh.paused, conversationID)
+	delete(sh.forced, conversationID)
+	if history := sh.liveHistory(conversationID); history != nil {
+		history.expires = time.Time{}
+	}
+	if attrs.IsZero() {
+		delete(sh.attrs, conversationID)
+	} else {
+		sh.attrs[conversationID] = attrs
+	}
+	return nil
+}
+
+func (s *MemoryStore) Clear(ctx context.Context, conversationID, messageID string, responseMS int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if err := sh.applyEvent(conversationID, messageID, responseMS, s.dedupTTL); err != nil {
+		return err
+	}
+
+	sh.remove(conversationID, s.historyTTL)
+	return nil
+}
+
+func (s *MemoryStore) Apply(ctx context.Context, events []Event) []error {
+	errs := make([]error, len(events))
+	for i, event := range events {
+		if event.Clear {
+			errs[i] = s.Clear(ctx, event.ConversationID, event.MessageID, event.TimestampMS)
+		} else {
+			errs[i] = s.Track(ctx, event.ConversationID, event.MessageID, event.TimestampMS, event.Deadline, event.Attributes)
+		}
+	}
+	return errs
+}
+
+func (s *MemoryStore) State(ctx context.Context, conversationID string) (ConversationState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.shardOf(conversationID).state(conversationID), nil
+}
+
+func (s *MemoryStore) Due(ctx context.Context, shard int, now, offset, count int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shards[shard]
+	var due []string
+	for conversationID, deadline := range sh.waiting {
+		if deadline <= float64(now) {
+			due = append(due, conversationID)
+		}
+	}
+
+	// Ordered like a sorted set: by score, then by member
+	sort.Slice(due, func(i, j int) bool {
+		a, b := sh.waiting[due[i]], sh.waiting[due[j]]
+		if a != b {
+			return a < b
+		}
+		return due[i] < due[j]
+	})
+
+	if offset >= int64(len(due)) {
+		return []string{}, nil
+	}
+	due = due[offset:]
+	if count >= 0 && count < int64(len(due)) {
+		due = due[:count]
+	}
+	return due, nil
+}
+
+func (s *MemoryStore) Waiting(ctx context.Context, shard int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return int64(len(s.shards[shard].waiting)), nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, shard int, afterMS int64, afterID string, until, count int64) (WaitingPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shards[shard]
+	var ids []string
+	for conversationID, since := range sh.since {
+		if since > until || since < afterMS || (since == afterMS && conversationID <= afterID) {
+			continue
+		}
+		ids = append(ids, conversationID)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := sh.since[ids[i]], sh.since[ids[j]]
+		if a != b {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+	page := WaitingPage{LastMS: afterMS, LastID: afterID, Done: true}
+	if count >= 0 && count < int64(len(ids)) {
+		ids = ids[:count]
+		page.Done = false
+	}
+
+	page.Conversations = make([]WaitingConversation, 0, len(ids))
+	for _, conversationID := range ids {
+		page.Conversations = append(page.Conversations, WaitingConversation{
+			ConversationID: conversationID,
+			State:          sh.state(conversationID),
+		})
+		page.LastMS, page.LastID = sh.since[conversationID], conversationID
+	}
+	return page, nil
+}
+
+func (s *MemoryStore) Advance(ctx context.Context, conversationID string, state ConversationState, level int, deadline float64, token int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if !sh.current(token) {
+		return false, ErrStaleFencingToken
+	}
+
+	if !sh.unchanged(conversationID, state) {
+		return false, nil
+	}
+	if _, paused := sh.paused[conversationID]; paused {
+		return false, nil
+	}
+
+	if level != state.Level {
+		sh.levels[conversationID] = level
+	}
+	sh.reschedule(conversationID, deadline)
+	return true, nil
+}
+
+func (s *MemoryStore) Revert(ctx context.Context, conversationID string, level, restore int, deadline float64, token int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if !sh.current(token) {
+		return false, ErrStaleFencingToken
+	}
+
+	current, ok := sh.levels[conversationID]
+	if !ok || current != level {
+		return false, nil
+	}
+
+	if restore == 0 {
+		delete(sh.levels, conversationID)
+	} else {
+		sh.levels[conversationID] = restore
+	}
+	if _, paused := sh.paused[conversationID]; !paused {
+		sh.reschedule(conversationID, deadline)
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) Pause(ctx context.Context, conversationID string, pausedAtMS int64, resumeAt float64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if _, ok := sh.since[conversationID]; !ok {
+		return false, nil
+	}
+
+	if _, paused := sh.paused[conversationID]; !paused {
+		sh.paused[conversationID] = pausedAtMS
+	}
+	sh.reschedule(conversationID, resumeAt)
+	return true, nil
+}
+
+func (s *MemoryStore) Resume(ctx context.Context, conversationID string, state ConversationState, agentMessageMS int64, deadline float64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if !sh.unchanged(conversationID, state) {
+		return false, nil
+	}
+	pausedAt, paused := sh.paused[conversationID]
+	if !paused || pausedAt != state.PausedAt {
+		return false, nil
+	}
+
+	delete(sh.paused, conversationID)
+	sh.since[conversationID] = agentMessageMS
+	sh.reschedule(conversationID, deadline)
+	return true, nil
+}
+
+func (s *MemoryStore) Force(ctx context.Context, conversationID string, state ConversationState, level int, deadline float64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	if !sh.unchanged(conversationID, state) {
+		return false, nil
+	}
+	if _, paused := sh.paused[conversationID]; paused {
+		return false, nil
+	}
+
+	sh.forced[conversationID] = level
+	sh.reschedule(conversationID, deadline)
+	return true, nil
+}
+
+func (s *MemoryStore) Cleanup(ctx context.Context, shard int, cutoff int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shards[shard]
+	var removed int64
+	for conversationID, since := range sh.since {
+		if since <= cutoff {
+			sh.remove(conversationID, s.historyTTL)
+			removed++
+		}
+	}
+
+	// Expired event clocks are otherwise only dropped when read again
+	now := time.Now()
+	for conversationID, clock := range sh.clocks {
+		if !now.Before(clock.expires) {
+			delete(sh.clocks, conversationID)
+		}
+	}
+	return removed, nil
+}
+
+func (s *MemoryStore) Record(ctx context.Context, conversationID string, event models.HistoryEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sh := s.shardOf(conversationID)
+	history := sh.liveHistory(conversationID)
+	if history == nil {
+		history = &memoryHistory{}
+		sh.history[conversationID] = history
+	}
+
+	history.events = append(history.events, event)
+	if len(history.events) > s.historyLimit {
+		history.events = append([]models.HistoryEvent(nil), history.events[len(history.events)-s.historyLimit:]...)
+	}
+	if _, waiting := sh.since[conversationID]; waiting {
+		history.expires = time.Time{}
+	} else {
+		history.expires = time.Now().Add(s.historyTTL)
+	}
+	return nil
+}
+
+func (s *MemoryStore) RecordAll(ctx context.Context, entries []HistoryEntry) error {
+	for _, entry := range entries {
+		if err := s.Record(ctx, entry.ConversationID, entry.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) History(ctx context.Context, conversationID string) ([]models.HistoryEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.shardOf(conversationID).liveHistory(conversationID)
+	if history == nil {
+		return []models.HistoryEvent{}, nil
+	}
+	return append([]models.HistoryEvent(nil), history.events...), nil
+}
+
+// shardOf returns the shard a conversation belongs to; s.mu must be held
+func (s *MemoryStore) shardOf(conversationID string) *memoryShard {
+	return s.shards[shard.For(conversationID, len(s.shards))]
+}
+
+// state returns what the shard knows about a conversation
+func (sh *memoryShard) state(conversationID string) ConversationState {
+	return ConversationState{
+		AgentMessageTime: sh.since[conversationID],
+		Level:            sh.levels[conversationID],
+		Deadline:         sh.waiting[conversationID],
+		Attributes:       sh.attrs[conversationID],
+		PausedAt:         sh.paused[conversationID],
+		ForcedLevel:      sh.forced[conversationID],
+	}
+}
+
+// unchanged reports whether a conversation is still waiting since the agent
+// message and at the level of state
+func (sh *memoryShard) unchanged(conversationID string, state ConversationState) bool {
+	since, ok := sh.since[conversationID]
+	return ok && since == state.AgentMessageTime && sh.levels[conversationID] == state.Level
+}
+
+// current reports whether token is the shard's current epoch
+func (sh *memoryShard) current(token int64) bool {
+	return sh.epoch != 0 && sh.epoch == token
+}
+
+// reschedule moves a waiting conversation to deadline, like ZADD XX
+func (sh *memoryShard) reschedule(conversationID string, deadline float64) {
+	if _, ok := sh.waiting[conversationID]; ok {
+		sh.waiting[conversationID] = deadline
+	}
+}
+
+// liveHistory returns a conversation's history, dropping it once expired
+func (sh *memoryShard) liveHistory(conversationID string) *memoryHistory {
+	history, ok := sh.history[conversationID]
+	if !ok {
+		return nil
+	}
+	if !history.expires.IsZero() && !time.Now().Before(history.expires) {
+		delete(sh.history, conversationID)
+		return nil
+	}
+	return history
+}
+
+// applyEvent moves a conversation's event clock to an event at ts, or returns
+// ErrDuplicateEvent or ErrStaleEvent when the event mustn't be applied
+func (sh *memoryShard) applyEvent(conversationID, messageID string, ts int64, ttl time.Duration) error {
+	clock, ok := sh.clocks[conversationID]
+	if ok && !time.Now().Before(clock.expires) {
+		delete(sh.clocks, conversationID)
+		ok = false
+	}
+	if !ok {
+		clock = &memoryClock{seen: make(map[string]int64)}
+		sh.clocks[conversationID] = clock
+	} else {
+		if _, seen := clock.seen[messageID]; seen && messageID != "" {
+			return ErrDuplicateEvent
+		}
+		if ts < clock.latest {
+			return ErrStaleEvent
+		}
+	}
+
+	clock.latest = ts
+	clock.expires = time.Now().Add(ttl)
+	if messageID != "" {
+		clock.seen[messageID] = ts
+		for len(clock.seen) > constants.EventDedupMessages {
+			oldest := messageID
+			for id, seenAt := range clock.seen {
+				if seenAt < clock.seen[oldest] || (seenAt == clock.seen[oldest] && id < oldest) {
+					oldest = id
+				}
+			}
+			delete(clock.seen, oldest)
+		}
+	}
+	return nil
+}
+
+// remove forgets a conversation, keeping its history for ttl
+func (sh *memoryShard) remove(conversationID string, ttl time.Duration) {
+	if history := sh.liveHistory(conversationID); history != nil {
+		history.expires = time.Now().Add(ttl)
+	}
+	delete(sh.waiting, conversationID)
+	delete(sh.since, conversationID)
+	delete(sh.levels, conversationID)
+	delete(sh.attrs, conversationID)
+	delete(sh.paused, conversationID)
+	delete(sh.forced, conversationID)
+}
@@ -0,0 +1,99 @@
+package phase1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+// MigrateUnsharded moves the conversations still waiting in the unsharded
+// keys written before sharding into their shards and returns how many were
+// moved. Each keeps its agent message time and notification level, and is
+// scheduled for its next level under the default policy. Stores without
+// unsharded keys have nothing to migrate.
+func (tm *TimeoutManager) MigrateUnsharded(ctx context.Context) (int, error) {
+	store, ok := tm.store.(*RedisStore)
+	if !ok {
+		return 0, nil
+	}
+
+	policy := tm.evaluator.Select(models.ConversationAttributes{})
+	moved, err := store.MigrateUnsharded(ctx, tm.config.DetectionBatch(), func(agentMessageMS int64, level int) float64 {
+		return DeadlineScore(tm.evaluator.NextDeadlineMS(policy, models.ConversationAttributes{}, agentMessageMS, level))
+	})
+	if moved > 0 {
+		tm.logger.WithFields(logrus.Fields{
+			"moved_count": moved,
+		}).Info("Moved unsharded waiting conversations into their shards")
+	}
+	return moved, err
+}
+
+// MigrateUnsharded moves the conversations in the unsharded
+// waiting_conversations, waiting_since and notification_states keys into
+// their shards, count at a time, and removes them from the unsharded keys.
+// deadline returns a conversation's next escalation deadline from its agent
+// message time and level. A conversation its shard already tracks, or has a
+// later event for, is only removed.
+func (s *RedisStore) MigrateUnsharded(ctx context.Context, count int64, deadline func(agentMessageMS int64, level int) float64) (int, error) {
+	moved := 0
+	for {
+		members, err := s.rdb.ZRangeWithScores(ctx, WaitingConversationsKey, 0, count-1).Result()
+		if err != nil {
+			return moved, fmt.Errorf("failed to read unsharded conversations: %w", err)
+		}
+		if len(members) == 0 {
+			return moved, nil
+		}
+
+		for _, member := range members {
+			conversationID := member.Member.(string)
+			adopted, err := s.adopt(ctx, conversationID, int64(member.Score), deadline)
+			if err != nil {
+				return moved, err
+			}
+			if adopted {
+				moved++
+			}
+		}
+	}
+}
+
+// adopt moves one unsharded conversation, whose waiting score is score, into
+// its shard
+func (s *RedisStore) adopt(ctx context.Context, conversationID string, score int64, deadline func(agentMessageMS int64, level int) float64) (bool, error) {
+	pipe := s.rdb.Pipeline()
+	since := pipe.ZScore(ctx, WaitingSinceKey, conversationID)
+	state := pipe.HGet(ctx, NotificationStatesKey, conversationID)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, fmt.Errorf("failed to read unsharded conversation: %w", err)
+	}
+
+	// Before deadline scheduling the waiting score was the agent message time
+	agentMessageMS := score
+	if since.Err() == nil {
+		agentMessageMS = int64(since.Val())
+	}
+	level, _ := state.Int()
+
+	keys := s.keysFor(conversationID)
+	adopted, err := adoptScript.Run(ctx, s.rdb,
+		[]string{keys.Waiting, keys.WaitingSince, keys.NotificationStates, keys.EventClock(conversationID)},
+		conversationID, agentMessageMS, level, deadline(agentMessageMS, level), s.dedupTTL.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to adopt unsharded conversation: %w", err)
+	}
+
+	pipe = s.rdb.Pipeline()
+	pipe.ZRem(ctx, WaitingConversationsKey, conversationID)
+	pipe.ZRem(ctx, WaitingSinceKey, conversationID)
+	pipe.HDel(ctx, NotificationStatesKey, conversationID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("failed to remove unsharded conversation: %w", err)
+	}
+	return adopted == 1, nil
+}
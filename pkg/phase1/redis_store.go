@@ -0,0 +1,451 @@
+package phase1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+// RedisStore is the TimeoutStore kept in Redis, in the keys of KeysFor. Its
+// fencing tokens are the leader epochs LeaderElection issues.
+type RedisStore struct {
+	rdb          redis.UniversalClient
+	shards       int
+	historyLimit int
+	historyTTL   time.Duration
+	dedupTTL     time.Duration
+}
+
+// NewRedisStore creates a store that spreads conversations over the
+// configured shards and keeps histories and event clocks as configured
+func NewRedisStore(rdb redis.UniversalClient, config *config.Config) *RedisStore {
+	return &RedisStore{
+		rdb:          rdb,
+		shards:       config.Shards(),
+		historyLimit: config.HistoryEvents(),
+		historyTTL:   config.HistoryTTLDuration(),
+		dedupTTL:     config.EventDedupTTLDuration(),
+	}
+}
+
+func (s *RedisStore) Track(ctx context.Context, conversationID, messageID string, agentMessageMS int64, deadline float64, attrs models.ConversationAttributes) error {
+	call, err := s.eventCall(Event{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		TimestampMS:    agentMessageMS,
+		Deadline:       deadline,
+		Attributes:     attrs,
+	})
+	if err != nil {
+		return err
+	}
+
+	outcome, err := call.script.Run(ctx, s.rdb, call.keys, call.args...).Text()
+	if err != nil {
+		return fmt.Errorf("failed to track conversation: %w", err)
+	}
+	return eventError(outcome)
+}
+
+func (s *RedisStore) Clear(ctx context.Context, conversationID, messageID string, responseMS int64) error {
+	call, err := s.eventCall(Event{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		TimestampMS:    responseMS,
+		Clear:          true,
+	})
+	if err != nil {
+		return err
+	}
+
+	outcome, err := call.script.Run(ctx, s.rdb, call.keys, call.args...).Text()
+	if err != nil {
+		return fmt.Errorf("failed to clear conversation: %w", err)
+	}
+	return eventError(outcome)
+}
+
+func (s *RedisStore) Apply(ctx context.Context, events []Event) []error {
+	errs := make([]error, len(events))
+	calls := make([]scriptCall, 0, len(events))
+	indexes := make([]int, 0, len(events))
+	for i, event := range events {
+		call, err := s.eventCall(event)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		calls = append(calls, call)
+		indexes = append(indexes, i)
+	}
+
+	for j, cmd := range evalPipelined(ctx, s.rdb, calls) {
+		outcome, err := cmd.Text()
+		if err != nil {
+			errs[indexes[j]] = fmt.Errorf("failed to apply event: %w", err)
+			continue
+		}
+		errs[indexes[j]] = eventError(outcome)
+	}
+	return errs
+}
+
+// eventCall returns the trackScript or clearScript call applying event
+func (s *RedisStore) eventCall(event Event) (scriptCall, error) {
+	keys := s.eventKeys(event.ConversationID)
+	if event.Clear {
+		return scriptCall{
+			script: clearScript,
+			keys:   keys,
+			args:   []interface{}{event.ConversationID, event.MessageID, event.TimestampMS, s.historyTTL.Milliseconds(), s.dedupTTL.Milliseconds(), constants.EventDedupMessages},
+		}, nil
+	}
+
+	// Record what the escalation policy is selected by
+	var attrsJSON []byte
+	if !event.Attributes.IsZero() {
+		var err error
+		if attrsJSON, err = json.Marshal(event.Attributes); err != nil {
+			return scriptCall{}, fmt.Errorf("failed to encode conversation attributes: %w", err)
+		}
+	}
+	return scriptCall{
+		script: trackScript,
+		keys:   keys,
+		args:   []interface{}{event.ConversationID, event.MessageID, event.TimestampMS, event.Deadline, attrsJSON, s.dedupTTL.Milliseconds(), constants.EventDedupMessages},
+	}, nil
+}
+
+// eventKeys returns the keys of trackScript and clearScript
+func (s *RedisStore) eventKeys(conversationID string) []string {
+	keys := s.keysFor(conversationID)
+	return []string{
+		keys.Waiting,
+		keys.WaitingSince,
+		keys.NotificationStates,
+		keys.Attributes,
+		keys.Paused,
+		keys.ForcedLevels,
+		keys.History(conversationID),
+		keys.EventClock(conversationID),
+		keys.SeenMessages(conversationID),
+	}
+}
+
+// eventError maps the outcome trackScript and clearScript return to an error
+func eventError(outcome string) error {
+	switch outcome {
+	case "stale":
+		return ErrStaleEvent
+	case "duplicate":
+		return ErrDuplicateEvent
+	}
+	return nil
+}
+
+func (s *RedisStore) State(ctx context.Context, conversationID string) (ConversationState, error) {
+	pipe := s.rdb.Pipeline()
+	cmds := queueState(ctx, pipe, s.keysFor(conversationID), conversationID)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return ConversationState{}, fmt.Errorf("failed to get notification state: %w", err)
+	}
+	return cmds.state()
+}
+
+func (s *RedisStore) Due(ctx context.Context, shard int, now, offset, count int64) ([]string, error) {
+	conversations, err := s.rdb.ZRangeByScore(ctx, KeysFor(shard).Waiting, &redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    strconv.FormatInt(now, 10),
+		Offset: offset,
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+func (s *RedisStore) Waiting(ctx context.Context, shard int) (int64, error) {
+	count, err := s.rdb.ZCard(ctx, KeysFor(shard).Waiting).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get waiting conversations count: %w", err)
+	}
+	return count, nil
+}
+
+func (s *RedisStore) List(ctx context.Context, shard int, afterMS int64, afterID string, until, count int64) (WaitingPage, error) {
+	keys := KeysFor(shard)
+	page := WaitingPage{Conversations: []WaitingConversation{}, LastMS: afterMS, LastID: afterID}
+
+	// Conversations waiting since afterMS up to afterID are skipped; they
+	// come first, so reading on by offset gets past them
+	var ids []string
+	for offset := int64(0); int64(len(ids)) < count; offset += count {
+		batch, err := s.rdb.ZRangeByScoreWithScores(ctx, keys.WaitingSince, &redis.ZRangeBy{
+			Min:    strconv.FormatInt(afterMS, 10),
+			Max:    strconv.FormatInt(until, 10),
+			Offset: offset,
+			Count:  count,
+		}).Result()
+		if err != nil {
+			return WaitingPage{}, fmt.Errorf("failed to list waiting conversations: %w", err)
+		}
+
+		for _, z := range batch {
+			conversationID, _ := z.Member.(string)
+			if int64(z.Score) == afterMS && conversationID <= afterID {
+				continue
+			}
+			if int64(len(ids)) < count {
+				ids = append(ids, conversationID)
+				page.LastMS, page.LastID = int64(z.Score), conversationID
+			}
+		}
+		if int64(len(batch)) < count {
+			break
+		}
+	}
+	page.Done = int64(len(ids)) < count
+	if len(ids) == 0 {
+		return page, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]stateCmds, len(ids))
+	for i, conversationID := range ids {
+		cmds[i] = queueState(ctx, pipe, keys, conversationID)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return WaitingPage{}, fmt.Errorf("failed to get notification states: %w", err)
+	}
+
+	for i, conversationID := range ids {
+		state, err := cmds[i].state()
+		if err != nil {
+			return WaitingPage{}, err
+		}
+		// Cleared since it was listed
+		if state.AgentMessageTime == 0 {
+			continue
+		}
+		page.Conversations = append(page.Conversations, WaitingConversation{ConversationID: conversationID, State: state})
+	}
+	return page, nil
+}
+
+func (s *RedisStore) Advance(ctx context.Context, conversationID string, state ConversationState, level int, deadline float64, token int64) (bool, error) {
+	shardKeys := s.keysFor(conversationID)
+	keys := []string{shardKeys.Waiting, shardKeys.NotificationStates, shardKeys.WaitingSince, shardKeys.LeaderEpoch, shardKeys.Paused}
+	applied, err := escalateScript.Run(ctx, s.rdb, keys, conversationID, state.AgentMessageTime, state.Level, level, deadline, token).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to escalate conversation: %w", AsFencingError(err))
+	}
+	return applied == 1, nil
+}
+
+func (s *RedisStore) Revert(ctx context.Context, conversationID string, level, restore int, deadline float64, token int64) (bool, error) {
+	shardKeys := s.keysFor(conversationID)
+	keys := []string{shardKeys.NotificationStates, shardKeys.Waiting, shardKeys.LeaderEpoch, shardKeys.Paused}
+	applied, err := revertEscalationScript.Run(ctx, s.rdb, keys, conversationID, level, restore, deadline, token).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to revert notification state: %w", AsFencingError(err))
+	}
+	return applied == 1, nil
+}
+
+func (s *RedisStore) Pause(ctx context.Context, conversationID string, pausedAtMS int64, resumeAt float64) (bool, error) {
+	shardKeys := s.keysFor(conversationID)
+	keys := []string{shardKeys.Waiting, shardKeys.WaitingSince, shardKeys.Paused}
+	applied, err := pauseScript.Run(ctx, s.rdb, keys, conversationID, pausedAtMS, resumeAt).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to pause conversation: %w", err)
+	}
+	return applied == 1, nil
+}
+
+func (s *RedisStore) Resume(ctx context.Context, conversationID string, state ConversationState, agentMessageMS int64, deadline float64) (bool, error) {
+	shardKeys := s.keysFor(conversationID)
+	keys := []string{shardKeys.Waiting, shardKeys.WaitingSince, shardKeys.NotificationStates, shardKeys.Paused}
+	applied, err := resumeScript.Run(ctx, s.rdb, keys, conversationID, state.AgentMessageTime, state.Level, state.PausedAt, agentMessageMS, deadline).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to resume conversation: %w", err)
+	}
+	return applied == 1, nil
+}
+
+func (s *RedisStore) Force(ctx context.Context, conversationID string, state ConversationState, level int, deadline float64) (bool, error) {
+	shardKeys := s.keysFor(conversationID)
+	keys := []string{shardKeys.Waiting, shardKeys.WaitingSince, shardKeys.NotificationStates, shardKeys.Paused, shardKeys.ForcedLevels}
+	applied, err := forceEscalationScript.Run(ctx, s.rdb, keys, conversationID, state.AgentMessageTime, state.Level, level, deadline).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to force escalation: %w", err)
+	}
+	return applied == 1, nil
+}
+
+// Cleanup lists the expired conversations and removes them a batch at a time,
+// so no single script blocks Redis for long and every key it touches is
+// declared
+func (s *RedisStore) Cleanup(ctx context.Context, shard int, cutoff int64) (int64, error) {
+	shardKeys := KeysFor(shard)
+	var removed int64
+	for {
+		expired, err := s.rdb.ZRangeByScore(ctx, shardKeys.WaitingSince, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   strconv.FormatInt(cutoff, 10),
+			Count: constants.CleanupBatchSize,
+		}).Result()
+		if err != nil {
+			return removed, fmt.Errorf("failed to list expired conversations: %w", err)
+		}
+		if len(expired) == 0 {
+			return removed, nil
+		}
+
+		keys := []string{shardKeys.WaitingSince, shardKeys.Waiting, shardKeys.NotificationStates, shardKeys.Attributes, shardKeys.Paused, shardKeys.ForcedLevels}
+		args := []interface{}{cutoff, s.historyTTL.Milliseconds()}
+		for _, conversationID := range expired {
+			keys = append(keys, shardKeys.History(conversationID))
+			args = append(args, conversationID)
+		}
+
+		n, err := cleanupExpiredScript.Run(ctx, s.rdb, keys, args...).Int64()
+		if err != nil {
+			return removed, fmt.Errorf("failed to cleanup expired conversations: %w", err)
+		}
+		removed += n
+
+		if len(expired) < constants.CleanupBatchSize {
+			return removed, nil
+		}
+	}
+}
+
+func (s *RedisStore) Record(ctx context.Context, conversationID string, event models.HistoryEvent) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode history event: %w", err)
+	}
+
+	shardKeys := s.keysFor(conversationID)
+	keys := []string{shardKeys.History(conversationID), shardKeys.WaitingSince}
+	if err := recordHistoryScript.Run(ctx, s.rdb, keys, conversationID, eventJSON, s.historyLimit, s.historyTTL.Milliseconds()).Err(); err != nil {
+		return fmt.Errorf("failed to record history event: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) RecordAll(ctx context.Context, entries []HistoryEntry) error {
+	calls := make([]scriptCall, 0, len(entries))
+	for _, entry := range entries {
+		eventJSON, err := json.Marshal(entry.Event)
+		if err != nil {
+			return fmt.Errorf("failed to encode history event: %w", err)
+		}
+		shardKeys := s.keysFor(entry.ConversationID)
+		calls = append(calls, scriptCall{
+			script: recordHistoryScript,
+			keys:   []string{shardKeys.History(entry.ConversationID), shardKeys.WaitingSince},
+			args:   []interface{}{entry.ConversationID, eventJSON, s.historyLimit, s.historyTTL.Milliseconds()},
+		})
+	}
+
+	for _, cmd := range evalPipelined(ctx, s.rdb, calls) {
+		if err := cmd.Err(); err != nil {
+			return fmt.Errorf("failed to record history events: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *RedisStore) History(ctx context.Context, conversationID string) ([]models.HistoryEvent, error) {
+	entries, err := s.rdb.LRange(ctx, s.keysFor(conversationID).History(conversationID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %w", err)
+	}
+
+	events := make([]models.HistoryEvent, 0, len(entries))
+	for _, entry := range entries {
+		var event models.HistoryEvent
+		if err := json.Unmarshal([]byte(entry), &event); err != nil {
+			return nil, fmt.Errorf("invalid history event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// keysFor returns the keys of the shard a conversation belongs to
+func (s *RedisStore) keysFor(conversationID string) ShardKeys {
+	return KeysFor(shard.For(conversationID, s.shards))
+}
+
+// stateCmds are the queued reads of a conversation's state
+type stateCmds struct {
+	since    *redis.FloatCmd
+	deadline *redis.FloatCmd
+	level    *redis.StringCmd
+	attrs    *redis.StringCmd
+	paused   *redis.StringCmd
+	forced   *redis.StringCmd
+}
+
+// queueState queues the reads of a conversation's state on pipe
+func queueState(ctx context.Context, pipe redis.Pipeliner, keys ShardKeys, conversationID string) stateCmds {
+	return stateCmds{
+		since:    pipe.ZScore(ctx, keys.WaitingSince, conversationID),
+		deadline: pipe.ZScore(ctx, keys.Waiting, conversationID),
+		level:    pipe.HGet(ctx, keys.NotificationStates, conversationID),
+		attrs:    pipe.HGet(ctx, keys.Attributes, conversationID),
+		paused:   pipe.HGet(ctx, keys.Paused, conversationID),
+		forced:   pipe.HGet(ctx, keys.ForcedLevels, conversationID),
+	}
+}
+
+// state decodes the reads once the pipeline has run
+func (c stateCmds) state() (ConversationState, error) {
+	var state ConversationState
+	state.AgentMessageTime = int64(c.since.Val())
+	state.Deadline = c.deadline.Val()
+
+	if levelStr := c.level.Val(); levelStr != "" {
+		level, err := strconv.Atoi(levelStr)
+		if err != nil {
+			return state, fmt.Errorf("invalid notification level format: %w", err)
+		}
+		state.Level = level
+	}
+
+	if pausedStr := c.paused.Val(); pausedStr != "" {
+		pausedAt, err := strconv.ParseInt(pausedStr, 10, 64)
+		if err != nil {
+			return state, fmt.Errorf("invalid pause time format: %w", err)
+		}
+		state.PausedAt = pausedAt
+	}
+
+	if forcedStr := c.forced.Val(); forcedStr != "" {
+		forced, err := strconv.Atoi(forcedStr)
+		if err != nil {
+			return state, fmt.Errorf("invalid forced level format: %w", err)
+		}
+		state.ForcedLevel = forced
+	}
+
+	if attrsJSON := c.attrs.Val(); attrsJSON != "" {
+		if err := json.Unmarshal([]byte(attrsJSON), &state.Attributes); err != nil {
+			return state, fmt.Errorf("invalid conversation attributes: %w", err)
+		}
+	}
+
+	return state, nil
+}
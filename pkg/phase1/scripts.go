@@ -0,0 +1,460 @@
+package phase1
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fencedErrorPrefix starts the error the detector scripts return when the
+// caller's fencing token is stale
+const fencedErrorPrefix = "FENCED"
+
+// ErrStaleFencingToken is returned when a write is rejected because a newer
+// leader has been elected since the caller's token was issued
+var ErrStaleFencingToken = errors.New("stale fencing token")
+
+// AsFencingError returns ErrStaleFencingToken when err is a script rejecting
+// a stale fencing token, otherwise err unchanged
+func AsFencingError(err error) error {
+	if err != nil && strings.HasPrefix(err.Error(), fencedErrorPrefix) {
+		return ErrStaleFencingToken
+	}
+	return err
+}
+
+// scriptCall is one run of a script, for evalPipelined
+type scriptCall struct {
+	script *redis.Script
+	keys   []string
+	args   []interface{}
+}
+
+// evalPipelined runs calls in one round trip and returns their results in
+// order. Scripts run by hash; when the server hasn't cached one, it is loaded
+// and the calls that found it missing, and so did nothing, are run again.
+func evalPipelined(ctx context.Context, rdb redis.UniversalClient, calls []scriptCall) []*redis.Cmd {
+	cmds := make([]*redis.Cmd, len(calls))
+	pending := make([]int, len(calls))
+	for i := range calls {
+		pending[i] = i
+	}
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		pipe := rdb.Pipeline()
+		for _, i := range pending {
+			cmds[i] = calls[i].script.EvalSha(ctx, pipe, calls[i].keys, calls[i].args...)
+		}
+		// Errors are reported by each command
+		_, _ = pipe.Exec(ctx)
+		if attempt > 0 {
+			break
+		}
+
+		var missing []int
+		loaded := make(map[*redis.Script]error)
+		for _, i := range pending {
+			if err := cmds[i].Err(); err == nil || !strings.HasPrefix(err.Error(), "NOSCRIPT") {
+				continue
+			}
+			script := calls[i].script
+			if _, ok := loaded[script]; !ok {
+				loaded[script] = script.Load(ctx, rdb).Err()
+			}
+			if loaded[script] == nil {
+				missing = append(missing, i)
+			}
+		}
+		pending = missing
+	}
+	return cmds
+}
+
+// acquireLeadershipScript takes the leader lock if it is free and issues the
+// new leader a fencing token, one higher than any issued before.
+//
+// KEYS[1] - leader lock
+// KEYS[2] - leader epoch counter
+// ARGV[1] - pod ID
+// ARGV[2] - lock TTL (ms)
+//
+// Returns the fencing token, or 0 when another pod holds the lock.
+var acquireLeadershipScript = redis.NewScript(`
+	if not redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+		return 0
+	end
+	return redis.call("INCR", KEYS[2])
+`)
+
+// escalateScript atomically advances a conversation's notification level and
+// moves it to its next escalation deadline, provided the conversation is
+// still in the state the caller evaluated and the caller is still the
+// current leader. Passing the scanned level as the new level only reschedules
+// it. Paused conversations are left alone.
+//
+// KEYS[1] - waiting conversations sorted set (scored by next deadline)
+// KEYS[2] - notification states hash
+// KEYS[3] - waiting since sorted set (scored by agent message time)
+// KEYS[4] - leader epoch counter
+// KEYS[5] - paused conversations hash
+// ARGV[1] - conversation ID
+// ARGV[2] - agent message time (ms) the caller read
+// ARGV[3] - level the caller read (0 for none)
+// ARGV[4] - new level
+// ARGV[5] - next escalation deadline (ms, or +inf after the last level)
+// ARGV[6] - caller's fencing token
+//
+// Returns 1 when the change was made, or 0 when the conversation was cleared,
+// re-tracked with a new agent message time, escalated by someone else or
+// paused since it was read. Fails with a FENCED error when the token is stale.
+var escalateScript = redis.NewScript(`
+	if redis.call("GET", KEYS[4]) ~= ARGV[6] then
+		return redis.error_reply("FENCED stale fencing token")
+	end
+
+	if redis.call("HEXISTS", KEYS[5], ARGV[1]) == 1 then
+		return 0
+	end
+
+	local since = redis.call("ZSCORE", KEYS[3], ARGV[1])
+	if not since or tonumber(since) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+
+	local current = redis.call("HGET", KEYS[2], ARGV[1]) or "0"
+	if tonumber(current) ~= tonumber(ARGV[3]) then
+		return 0
+	end
+
+	if ARGV[4] ~= ARGV[3] then
+		redis.call("HSET", KEYS[2], ARGV[1], ARGV[4])
+	end
+	redis.call("ZADD", KEYS[1], "XX", ARGV[5], ARGV[1])
+	return 1
+`)
+
+// revertEscalationScript undoes an escalation whose notification could not be
+// delivered, as long as nothing else has touched the level since, and makes
+// the conversation due again. A conversation paused meanwhile only gets its
+// level back, staying parked until it is resumed.
+//
+// KEYS[1] - notification states hash
+// KEYS[2] - waiting conversations sorted set
+// KEYS[3] - leader epoch counter
+// KEYS[4] - paused conversations hash
+// ARGV[1] - conversation ID
+// ARGV[2] - level set by escalateScript
+// ARGV[3] - level to restore (0 removes the field)
+// ARGV[4] - deadline to restore (ms)
+// ARGV[5] - caller's fencing token
+var revertEscalationScript = redis.NewScript(`
+	if redis.call("GET", KEYS[3]) ~= ARGV[5] then
+		return redis.error_reply("FENCED stale fencing token")
+	end
+
+	if redis.call("HGET", KEYS[1], ARGV[1]) ~= ARGV[2] then
+		return 0
+	end
+
+	if ARGV[3] == "0" then
+		redis.call("HDEL", KEYS[1], ARGV[1])
+	else
+		redis.call("HSET", KEYS[1], ARGV[1], ARGV[3])
+	end
+	if redis.call("HEXISTS", KEYS[4], ARGV[1]) == 0 then
+		redis.call("ZADD", KEYS[2], "XX", ARGV[4], ARGV[1])
+	end
+	return 1
+`)
+
+// pauseScript stops a waiting conversation's clock and parks it until it is
+// resumed. The time the clock stopped is kept when it is already paused.
+//
+// KEYS[1] - waiting conversations sorted set
+// KEYS[2] - waiting since sorted set
+// KEYS[3] - paused conversations hash
+// ARGV[1] - conversation ID
+// ARGV[2] - time the clock stops (ms)
+// ARGV[3] - when the conversation is due to resume (ms, or +inf)
+//
+// Returns 1 when the conversation was paused, or 0 when it isn't waiting.
+var pauseScript = redis.NewScript(`
+	if not redis.call("ZSCORE", KEYS[2], ARGV[1]) then
+		return 0
+	end
+
+	redis.call("HSETNX", KEYS[3], ARGV[1], ARGV[2])
+	redis.call("ZADD", KEYS[1], "XX", ARGV[3], ARGV[1])
+	return 1
+`)
+
+// resumeScript restarts a paused conversation's clock, provided it is still
+// in the state the caller read.
+//
+// KEYS[1] - waiting conversations sorted set
+// KEYS[2] - waiting since sorted set
+// KEYS[3] - notification states hash
+// KEYS[4] - paused conversations hash
+// ARGV[1] - conversation ID
+// ARGV[2] - agent message time (ms) the caller read
+// ARGV[3] - level the caller read (0 for none)
+// ARGV[4] - time the clock stopped (ms) the caller read
+// ARGV[5] - new agent message time (ms)
+// ARGV[6] - next escalation deadline (ms, or +inf after the last level)
+//
+// Returns 1 when the conversation was resumed, or 0 when it changed since it
+// was read.
+var resumeScript = redis.NewScript(`
+	local since = redis.call("ZSCORE", KEYS[2], ARGV[1])
+	if not since or tonumber(since) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+
+	local current = redis.call("HGET", KEYS[3], ARGV[1]) or "0"
+	if tonumber(current) ~= tonumber(ARGV[3]) then
+		return 0
+	end
+
+	local paused = redis.call("HGET", KEYS[4], ARGV[1])
+	if not paused or tonumber(paused) ~= tonumber(ARGV[4]) then
+		return 0
+	end
+
+	redis.call("HDEL", KEYS[4], ARGV[1])
+	redis.call("ZADD", KEYS[2], "XX", ARGV[5], ARGV[1])
+	redis.call("ZADD", KEYS[1], "XX", ARGV[6], ARGV[1])
+	return 1
+`)
+
+// forceEscalationScript records the level a running conversation must be
+// escalated to and makes it due, provided it is still in the state the caller
+// read.
+//
+// KEYS[1] - waiting conversations sorted set
+// KEYS[2] - waiting since sorted set
+// KEYS[3] - notification states hash
+// KEYS[4] - paused conversations hash
+// KEYS[5] - forced levels hash
+// ARGV[1] - conversation ID
+// ARGV[2] - agent message time (ms) the caller read
+// ARGV[3] - level the caller read (0 for none)
+// ARGV[4] - forced level
+// ARGV[5] - when the conversation is due (ms)
+//
+// Returns 1 when the escalation was recorded, or 0 when the conversation
+// changed since it was read or is paused.
+var forceEscalationScript = redis.NewScript(`
+	local since = redis.call("ZSCORE", KEYS[2], ARGV[1])
+	if not since or tonumber(since) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+
+	local current = redis.call("HGET", KEYS[3], ARGV[1]) or "0"
+	if tonumber(current) ~= tonumber(ARGV[3]) then
+		return 0
+	end
+
+	if redis.call("HEXISTS", KEYS[4], ARGV[1]) == 1 then
+		return 0
+	end
+
+	redis.call("HSET", KEYS[5], ARGV[1], ARGV[4])
+	redis.call("ZADD", KEYS[1], "XX", ARGV[5], ARGV[1])
+	return 1
+`)
+
+// cleanupExpiredScript removes the given conversations whose agent message
+// is at or before a cutoff, along with their deadline, notification state,
+// attributes and holds, and sets their histories to expire. Conversations
+// re-tracked since they were listed are kept.
+//
+// KEYS[1] - waiting since sorted set
+// KEYS[2] - waiting conversations sorted set
+// KEYS[3] - notification states hash
+// KEYS[4] - conversation attributes hash
+// KEYS[5] - paused conversations hash
+// KEYS[6] - forced levels hash
+// KEYS[7..] - history list of each conversation, in ARGV order
+// ARGV[1] - cutoff agent message time (ms)
+// ARGV[2] - history TTL (ms)
+// ARGV[3..] - conversation IDs
+//
+// Returns the number of conversations removed.
+var cleanupExpiredScript = redis.NewScript(`
+	local removed = 0
+	for i = 3, #ARGV do
+		local conversation = ARGV[i]
+		local since = redis.call("ZSCORE", KEYS[1], conversation)
+		if since and tonumber(since) <= tonumber(ARGV[1]) then
+			redis.call("ZREM", KEYS[1], conversation)
+			redis.call("ZREM", KEYS[2], conversation)
+			redis.call("HDEL", KEYS[3], conversation)
+			redis.call("HDEL", KEYS[4], conversation)
+			redis.call("HDEL", KEYS[5], conversation)
+			redis.call("HDEL", KEYS[6], conversation)
+			redis.call("PEXPIRE", KEYS[i + 4], ARGV[2])
+			removed = removed + 1
+		end
+	end
+	return removed
+`)
+
+// recordHistoryScript appends an event to a conversation's timeline, keeping
+// the latest events only. The timeline of a conversation that is waiting is
+// kept; otherwise it expires after the TTL.
+//
+// KEYS[1] - conversation history list
+// KEYS[2] - waiting since sorted set
+// ARGV[1] - conversation ID
+// ARGV[2] - event (JSON)
+// ARGV[3] - how many events are kept
+// ARGV[4] - history TTL (ms)
+var recordHistoryScript = redis.NewScript(`
+	redis.call("RPUSH", KEYS[1], ARGV[2])
+	redis.call("LTRIM", KEYS[1], -tonumber(ARGV[3]), -1)
+	if redis.call("ZSCORE", KEYS[2], ARGV[1]) then
+		redis.call("PERSIST", KEYS[1])
+	else
+		redis.call("PEXPIRE", KEYS[1], ARGV[4])
+	end
+	return 1
+`)
+
+// eventOrdering defines the functions trackScript and clearScript order agent
+// messages and customer responses with. A conversation's event clock holds
+// the timestamp of the latest event applied to it, and its seen messages the
+// IDs of the latest ones, scored by timestamp; both expire after the dedup
+// TTL.
+//
+// checkEvent(clock, seen, messageID, ts) returns "duplicate" or "stale" for
+// an event that mustn't be applied, or nil. recordEvent(clock, seen,
+// messageID, ts, ttl, keep) moves the clock to an applied event.
+const eventOrdering = `
+	local function checkEvent(clock, seen, messageID, ts)
+		if messageID ~= "" and redis.call("ZSCORE", seen, messageID) then
+			return "duplicate"
+		end
+		local latest = redis.call("GET", clock)
+		if latest and tonumber(ts) < tonumber(latest) then
+			return "stale"
+		end
+		return nil
+	end
+
+	local function recordEvent(clock, seen, messageID, ts, ttl, keep)
+		redis.call("SET", clock, ts, "PX", ttl)
+		if messageID ~= "" then
+			redis.call("ZADD", seen, ts, messageID)
+			redis.call("ZREMRANGEBYRANK", seen, 0, -tonumber(keep) - 1)
+			redis.call("PEXPIRE", seen, ttl)
+		end
+	end
+`
+
+// trackScript starts or restarts waiting on a conversation for an agent
+// message, unless a later event was applied to it or the message was.
+//
+// KEYS[1] - waiting conversations sorted set
+// KEYS[2] - waiting since sorted set
+// KEYS[3] - notification states hash
+// KEYS[4] - conversation attributes hash
+// KEYS[5] - paused conversations hash
+// KEYS[6] - forced levels hash
+// KEYS[7] - conversation history list
+// KEYS[8] - conversation event clock
+// KEYS[9] - conversation seen messages sorted set
+// ARGV[1] - conversation ID
+// ARGV[2] - message ID ("" for none)
+// ARGV[3] - agent message time (ms)
+// ARGV[4] - first escalation deadline (ms, or +inf)
+// ARGV[5] - attributes (JSON, or "" for none)
+// ARGV[6] - how long (ms) the event clock and seen messages are kept
+// ARGV[7] - how many message IDs are kept
+//
+// Returns "applied", "stale" or "duplicate".
+var trackScript = redis.NewScript(eventOrdering + `
+	local verdict = checkEvent(KEYS[8], KEYS[9], ARGV[2], ARGV[3])
+	if verdict then
+		return verdict
+	end
+
+	redis.call("ZADD", KEYS[1], ARGV[4], ARGV[1])
+	redis.call("ZADD", KEYS[2], ARGV[3], ARGV[1])
+	redis.call("HDEL", KEYS[3], ARGV[1])
+	if ARGV[5] == "" then
+		redis.call("HDEL", KEYS[4], ARGV[1])
+	else
+		redis.call("HSET", KEYS[4], ARGV[1], ARGV[5])
+	end
+	redis.call("HDEL", KEYS[5], ARGV[1])
+	redis.call("HDEL", KEYS[6], ARGV[1])
+	redis.call("PERSIST", KEYS[7])
+
+	recordEvent(KEYS[8], KEYS[9], ARGV[2], ARGV[3], ARGV[6], ARGV[7])
+	return "applied"
+`)
+
+// clearScript stops waiting on a conversation for a customer response,
+// unless a later event was applied to it or the response was. The history is
+// set to expire.
+//
+// KEYS[1..9] - as for trackScript
+// ARGV[1] - conversation ID
+// ARGV[2] - message ID ("" for none)
+// ARGV[3] - customer response time (ms)
+// ARGV[4] - history TTL (ms)
+// ARGV[5] - how long (ms) the event clock and seen messages are kept
+// ARGV[6] - how many message IDs are kept
+//
+// Returns "applied", "stale" or "duplicate".
+var clearScript = redis.NewScript(eventOrdering + `
+	local verdict = checkEvent(KEYS[8], KEYS[9], ARGV[2], ARGV[3])
+	if verdict then
+		return verdict
+	end
+
+	redis.call("ZREM", KEYS[1], ARGV[1])
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	redis.call("HDEL", KEYS[3], ARGV[1])
+	redis.call("HDEL", KEYS[4], ARGV[1])
+	redis.call("HDEL", KEYS[5], ARGV[1])
+	redis.call("HDEL", KEYS[6], ARGV[1])
+	redis.call("PEXPIRE", KEYS[7], ARGV[4])
+
+	recordEvent(KEYS[8], KEYS[9], ARGV[2], ARGV[3], ARGV[5], ARGV[6])
+	return "applied"
+`)
+
+// adoptScript moves a conversation left waiting in the unsharded keys into
+// its shard, unless the shard already tracks it or has a later event for it.
+//
+// KEYS[1] - waiting conversations sorted set
+// KEYS[2] - waiting since sorted set
+// KEYS[3] - notification states hash
+// KEYS[4] - conversation event clock
+// ARGV[1] - conversation ID
+// ARGV[2] - agent message time (ms)
+// ARGV[3] - notification level (0 for none)
+// ARGV[4] - next escalation deadline (ms, or +inf)
+// ARGV[5] - how long (ms) the event clock is kept
+//
+// Returns 1 when the conversation was adopted, 0 otherwise.
+var adoptScript = redis.NewScript(`
+	if redis.call("ZSCORE", KEYS[2], ARGV[1]) then
+		return 0
+	end
+	local latest = redis.call("GET", KEYS[4])
+	if latest and tonumber(ARGV[2]) <= tonumber(latest) then
+		return 0
+	end
+
+	redis.call("ZADD", KEYS[1], ARGV[4], ARGV[1])
+	redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+	if ARGV[3] ~= "0" then
+		redis.call("HSET", KEYS[3], ARGV[1], ARGV[3])
+	end
+	redis.call("SET", KEYS[4], ARGV[2], "PX", ARGV[5])
+	return 1
+`)
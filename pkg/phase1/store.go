@@ -0,0 +1,177 @@
+package phase1
+
+import (
+	"context"
+	"errors"
+
+	"redis-timeout-tracking-poc/pkg/models"
+)
+
+var (
+	// ErrStaleEvent is returned, with nothing changed, for an agent message
+	// or customer response older than the latest one applied to the
+	// conversation
+	ErrStaleEvent = errors.New("stale event")
+	// ErrDuplicateEvent is returned, with nothing changed, for an agent
+	// message or customer response whose message ID was already applied
+	ErrDuplicateEvent = errors.New("duplicate event")
+)
+
+// TimeoutStore keeps the conversations waiting for a customer response,
+// partitioned into detection shards: when each started waiting, the
+// notification level it has reached, its next escalation deadline, the
+// attributes its escalation policy is selected by, and the manual holds and
+// escalations applied to it. It also keeps a bounded timeline of each
+// conversation, which outlives it by a TTL.
+//
+// Agent messages and customer responses apply last-writer-wins by their
+// timestamp and are deduplicated by message ID: Track and Clear fail with
+// ErrStaleEvent or ErrDuplicateEvent without changing anything otherwise. An
+// empty message ID is never a duplicate.
+//
+// Writes made by detectors are fenced: they take the caller's fencing token
+// and fail with ErrStaleFencingToken unless it is the current epoch of the
+// conversation's shard.
+type TimeoutStore interface {
+	// Track starts waiting on a conversation, or restarts the wait when it is
+	// already tracked: it records the agent message time (ms), the first
+	// escalation deadline and the attributes, and clears the level and holds
+	Track(ctx context.Context, conversationID, messageID string, agentMessageMS int64, deadline float64, attrs models.ConversationAttributes) error
+
+	// Clear stops waiting on a conversation after a customer response at
+	// responseMS and forgets everything about it but its history and the
+	// response, which later events are ordered against
+	Clear(ctx context.Context, conversationID, messageID string, responseMS int64) error
+
+	// Apply tracks or clears a batch of conversations, as Track and Clear
+	// would one event at a time, in one round trip where the store allows.
+	// It returns each event's error; events don't fail together.
+	Apply(ctx context.Context, events []Event) []error
+
+	// State returns what detection needs about a conversation; its
+	// AgentMessageTime is 0 when the conversation isn't waiting
+	State(ctx context.Context, conversationID string) (ConversationState, error)
+
+	// Due returns up to count conversations of a shard whose deadline is at
+	// or before now, earliest deadline first, skipping the first offset
+	Due(ctx context.Context, shard int, now, offset, count int64) ([]string, error)
+
+	// Waiting returns how many conversations of a shard are waiting
+	Waiting(ctx context.Context, shard int) (int64, error)
+
+	// List scans up to count waiting conversations of a shard whose agent
+	// message is at or before until (ms), ordered by agent message time and
+	// then ID, starting after the conversation afterID waiting since afterMS.
+	// Pass an empty afterID to start at afterMS. Conversations cleared while
+	// they were scanned are left out of the page but still move it along.
+	List(ctx context.Context, shard int, afterMS int64, afterID string, until, count int64) (WaitingPage, error)
+
+	// Advance moves a conversation to level and deadline, provided it is
+	// still in state. Passing state.Level only reschedules it. It returns
+	// false without writing anything when the conversation was cleared,
+	// re-tracked, escalated or paused since state was read, or is paused.
+	Advance(ctx context.Context, conversationID string, state ConversationState, level int, deadline float64, token int64) (bool, error)
+
+	// Revert hands back a level set by Advance whose notification couldn't
+	// be delivered, restoring the previous level (0 for none) and deadline.
+	// A conversation paused or snoozed since Advance only gets its level
+	// back; its deadline stays where the pause parked it. It returns false
+	// without writing anything when the level is no longer level.
+	Revert(ctx context.Context, conversationID string, level, restore int, deadline float64, token int64) (bool, error)
+
+	// Pause stops a conversation's clock at pausedAtMS and parks it at
+	// resumeAt: +inf to hold it until Resume, or the end of a snooze, when
+	// detection finds it due and resumes it. Pausing a paused conversation
+	// only moves resumeAt, keeping the time the clock stopped. It returns
+	// false when the conversation isn't waiting.
+	Pause(ctx context.Context, conversationID string, pausedAtMS int64, resumeAt float64) (bool, error)
+
+	// Resume restarts the clock of a paused conversation, moving its agent
+	// message time to agentMessageMS and its next deadline to deadline,
+	// provided it is still in state. It returns false without writing
+	// anything when the conversation changed since state was read.
+	Resume(ctx context.Context, conversationID string, state ConversationState, agentMessageMS int64, deadline float64) (bool, error)
+
+	// Force records level as the level the conversation must be escalated to
+	// and makes it due at deadline, provided it is still in state and isn't
+	// paused. It returns false without writing anything otherwise.
+	Force(ctx context.Context, conversationID string, state ConversationState, level int, deadline float64) (bool, error)
+
+	// Cleanup removes the conversations of a shard whose agent message is at
+	// or before cutoff (ms), and returns how many there were
+	Cleanup(ctx context.Context, shard int, cutoff int64) (int64, error)
+
+	// Record appends an event to a conversation's history, dropping the
+	// oldest events beyond the store's limit. Once the conversation stops
+	// waiting its history expires after the store's TTL.
+	Record(ctx context.Context, conversationID string, event models.HistoryEvent) error
+
+	// RecordAll records a batch of history events, as Record would one at a
+	// time
+	RecordAll(ctx context.Context, entries []HistoryEntry) error
+
+	// History returns a conversation's history, oldest event first
+	History(ctx context.Context, conversationID string) ([]models.HistoryEvent, error)
+}
+
+// ConversationState is what detection reads about a waiting conversation
+// before deciding whether to escalate it
+type ConversationState struct {
+	// AgentMessageTime is the tracked agent message time in ms, or 0 when the
+	// conversation is no longer waiting
+	AgentMessageTime int64
+	Level            int
+	// Deadline is when (ms) the next level fires: +inf past the last level,
+	// or 0 when the conversation isn't waiting
+	Deadline   float64
+	Attributes models.ConversationAttributes
+	// PausedAt is when (ms) the conversation's clock was stopped, or 0 when
+	// it is running
+	PausedAt int64
+	// ForcedLevel is the level the conversation was manually escalated to;
+	// it is pending while above Level
+	ForcedLevel int
+}
+
+// Paused reports whether the conversation's clock is stopped
+func (s ConversationState) Paused() bool {
+	return s.PausedAt != 0
+}
+
+// Event is an agent message to Track or, when Clear is set, a customer
+// response to Clear, for TimeoutStore.Apply
+type Event struct {
+	ConversationID string
+	MessageID      string
+	// TimestampMS is the agent message or customer response time
+	TimestampMS int64
+	Clear       bool
+	// Deadline and Attributes are those of an agent message
+	Deadline   float64
+	Attributes models.ConversationAttributes
+}
+
+// HistoryEntry is a history event of a conversation, for
+// TimeoutStore.RecordAll
+type HistoryEntry struct {
+	ConversationID string
+	Event          models.HistoryEvent
+}
+
+// WaitingConversation is a conversation returned by TimeoutStore.List
+type WaitingConversation struct {
+	ConversationID string
+	State          ConversationState
+}
+
+// WaitingPage is a page of waiting conversations returned by TimeoutStore.List
+type WaitingPage struct {
+	Conversations []WaitingConversation
+	// LastMS and LastID are the agent message time and ID of the last
+	// conversation scanned, whether or not it was still waiting when read;
+	// the next page starts after them
+	LastMS int64
+	LastID string
+	// Done is set when no conversation of the shard comes after the page
+	Done bool
+}
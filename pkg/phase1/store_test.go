@@ -0,0 +1,670 @@
+package phase1
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+// storeFactory returns an empty store spreading conversations over shards
+// and keeping 3 history events, and a function issuing a new fencing token
+// for a shard of it
+type storeFactory func(t *testing.T, shards int) (TimeoutStore, func(shard int) int64)
+
+func TestRedisStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T, shards int) (TimeoutStore, func(int) int64) {
+		rdb := setupTestRedis(t)
+		t.Cleanup(func() { rdb.Close() })
+
+		newEpoch := func(s int) int64 {
+			token, err := rdb.Incr(context.Background(), KeysFor(s).LeaderEpoch).Result()
+			require.NoError(t, err)
+			return token
+		}
+		return NewRedisStore(rdb, &config.Config{ShardCount: shards, HistoryLimit: 3}), newEpoch
+	})
+}
+
+func TestRedisStore_ApplyLoadsScripts(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	ctx := context.Background()
+	require.NoError(t, rdb.ScriptFlush(ctx).Err())
+
+	store := NewRedisStore(rdb, &config.Config{})
+	errs := store.Apply(ctx, []Event{
+		{ConversationID: "a", MessageID: "m1", TimestampMS: 1000, Deadline: 2000},
+		{ConversationID: "a", MessageID: "m1", TimestampMS: 1000, Deadline: 2000},
+	})
+	assert.NoError(t, errs[0])
+	assert.ErrorIs(t, errs[1], ErrDuplicateEvent)
+}
+
+func TestRedisStore_CleanupBatches(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	ctx := context.Background()
+	store := NewRedisStore(rdb, &config.Config{})
+
+	// More than one script call's worth
+	events := make([]Event, constants.CleanupBatchSize+10)
+	for i := range events {
+		events[i] = Event{ConversationID: fmt.Sprintf("conv_%d", i), TimestampMS: 1000, Deadline: 2000}
+	}
+	for _, err := range store.Apply(ctx, events) {
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Record(ctx, "conv_0", models.HistoryEvent{Type: models.HistoryTracked}))
+
+	removed, err := store.Cleanup(ctx, 0, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(events)), removed)
+
+	waiting, err := store.Waiting(ctx, 0)
+	require.NoError(t, err)
+	assert.Zero(t, waiting)
+
+	// Histories are declared to the script and set to expire
+	ttl, err := rdb.PTTL(ctx, testKeys.History("conv_0")).Result()
+	require.NoError(t, err)
+	assert.Greater(t, ttl, time.Duration(0))
+}
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T, shards int) (TimeoutStore, func(int) int64) {
+		store := NewMemoryStore(&config.Config{ShardCount: shards, HistoryLimit: 3})
+		return store, store.NewEpoch
+	})
+}
+
+// testStoreConformance checks the TimeoutStore semantics every
+// implementation must share
+func testStoreConformance(t *testing.T, newStore storeFactory) {
+	ctx := context.Background()
+	attrs := models.ConversationAttributes{TenantID: "acme", Priority: "high"}
+
+	t.Run("TrackAndState", func(t *testing.T) {
+		store, _ := newStore(t, 1)
+
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, ConversationState{}, state)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		state, err = store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, ConversationState{AgentMessageTime: 1000, Deadline: 2000, Attributes: attrs}, state)
+
+		waiting, err := store.Waiting(ctx, 0)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), waiting)
+	})
+
+	t.Run("RetrackResetsLevel", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		applied, err := store.Advance(ctx, "conv", state, 1, 3000, token)
+		require.NoError(t, err)
+		require.True(t, applied)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 5000, 6000, models.ConversationAttributes{}))
+		state, err = store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, ConversationState{AgentMessageTime: 5000, Deadline: 6000}, state)
+
+		due, err := store.Due(ctx, 0, 5999, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+		due, err = store.Due(ctx, 0, 6000, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"conv"}, due)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		store, _ := newStore(t, 1)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		require.NoError(t, store.Clear(ctx, "conv", "", 1500))
+		require.NoError(t, store.Clear(ctx, "never_tracked", "", 1000))
+
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, ConversationState{}, state)
+
+		waiting, err := store.Waiting(ctx, 0)
+		require.NoError(t, err)
+		assert.Zero(t, waiting)
+	})
+
+	t.Run("Due", func(t *testing.T) {
+		store, _ := newStore(t, 1)
+
+		require.NoError(t, store.Track(ctx, "c", "", 0, 300, attrs))
+		require.NoError(t, store.Track(ctx, "a", "", 0, 100, attrs))
+		require.NoError(t, store.Track(ctx, "b", "", 0, 100, attrs))
+		require.NoError(t, store.Track(ctx, "d", "", 0, 400, attrs))
+		require.NoError(t, store.Track(ctx, "parked", "", 0, math.Inf(1), attrs))
+
+		// Earliest deadline first, ties by ID, deadline inclusive
+		due, err := store.Due(ctx, 0, 300, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, due)
+
+		due, err = store.Due(ctx, 0, 300, 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, due)
+
+		due, err = store.Due(ctx, 0, 300, 3, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+
+		// Conversations past their last level are never due
+		due, err = store.Due(ctx, 0, math.MaxInt64, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, due)
+	})
+
+	t.Run("Shards", func(t *testing.T) {
+		store, _ := newStore(t, 4)
+
+		counts := make(map[int]int64)
+		for _, id := range []string{"conv_1", "conv_2", "conv_3", "conv_4", "conv_5", "conv_6"} {
+			require.NoError(t, store.Track(ctx, id, "", 0, 100, attrs))
+			counts[shard.For(id, 4)]++
+		}
+
+		for s := 0; s < 4; s++ {
+			waiting, err := store.Waiting(ctx, s)
+			require.NoError(t, err)
+			assert.Equal(t, counts[s], waiting, "shard %d", s)
+
+			due, err := store.Due(ctx, s, 100, 0, 10)
+			require.NoError(t, err)
+			assert.Len(t, due, int(counts[s]), "shard %d", s)
+			for _, id := range due {
+				assert.Equal(t, s, shard.For(id, 4))
+			}
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "c", "", 2000, 3000, attrs))
+		require.NoError(t, store.Track(ctx, "b", "", 1000, 2000, attrs))
+		require.NoError(t, store.Track(ctx, "a", "", 1000, 2000, models.ConversationAttributes{}))
+		require.NoError(t, store.Track(ctx, "d", "", 3000, math.Inf(1), attrs))
+		state, err := store.State(ctx, "b")
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, "b", state, 1, 2500, token)
+		require.NoError(t, err)
+
+		ids := func(conversations []WaitingConversation) []string {
+			var ids []string
+			for _, c := range conversations {
+				ids = append(ids, c.ConversationID)
+			}
+			return ids
+		}
+
+		// Oldest agent message first, ties by ID, until inclusive
+		page, err := store.List(ctx, 0, 0, "", 2000, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, ids(page.Conversations))
+		assert.Equal(t, ConversationState{AgentMessageTime: 1000, Level: 1, Deadline: 2500, Attributes: attrs}, page.Conversations[1].State)
+		assert.True(t, page.Done)
+
+		// Paging resumes after the last conversation, within its tie
+		page, err = store.List(ctx, 0, 0, "", 3000, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, ids(page.Conversations))
+		assert.Equal(t, int64(1000), page.LastMS)
+		assert.Equal(t, "a", page.LastID)
+		assert.False(t, page.Done)
+		page, err = store.List(ctx, 0, 1000, "a", 3000, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, ids(page.Conversations))
+		page, err = store.List(ctx, 0, 2000, "c", 3000, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"d"}, ids(page.Conversations))
+		assert.True(t, math.IsInf(page.Conversations[0].State.Deadline, 1))
+		assert.True(t, page.Done)
+
+		page, err = store.List(ctx, 0, 3000, "d", 3000, 2)
+		require.NoError(t, err)
+		assert.Empty(t, page.Conversations)
+		assert.Equal(t, int64(3000), page.LastMS)
+		assert.Equal(t, "d", page.LastID)
+		assert.True(t, page.Done)
+	})
+
+	t.Run("Advance", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+
+		applied, err := store.Advance(ctx, "conv", state, 1, 3000, token)
+		require.NoError(t, err)
+		assert.True(t, applied)
+
+		advanced, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 1, advanced.Level)
+		due, err := store.Due(ctx, 0, 2999, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+
+		// The level changed since state was read
+		applied, err = store.Advance(ctx, "conv", state, 1, 3000, token)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		// Rescheduling keeps the level
+		applied, err = store.Advance(ctx, "conv", advanced, advanced.Level, 4000, token)
+		require.NoError(t, err)
+		assert.True(t, applied)
+		due, err = store.Due(ctx, 0, 3999, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+		rescheduled, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 1, rescheduled.Level)
+
+		// Re-tracked since state was read
+		require.NoError(t, store.Track(ctx, "conv", "", 1500, 2500, attrs))
+		applied, err = store.Advance(ctx, "conv", advanced, 2, 5000, token)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		// Cleared since state was read
+		require.NoError(t, store.Clear(ctx, "conv", "", 2000))
+		applied, err = store.Advance(ctx, "conv", ConversationState{AgentMessageTime: 1500}, 1, 5000, token)
+		require.NoError(t, err)
+		assert.False(t, applied)
+		waiting, err := store.Waiting(ctx, 0)
+		require.NoError(t, err)
+		assert.Zero(t, waiting)
+	})
+
+	t.Run("Revert", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, "conv", state, 1, 3000, token)
+		require.NoError(t, err)
+
+		// Only the level Advance set is handed back
+		applied, err := store.Revert(ctx, "conv", 2, 0, 2000, token)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		applied, err = store.Revert(ctx, "conv", 1, 0, 2000, token)
+		require.NoError(t, err)
+		assert.True(t, applied)
+
+		reverted, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 0, reverted.Level)
+		due, err := store.Due(ctx, 0, 2000, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"conv"}, due)
+
+		// Nothing to hand back any more
+		applied, err = store.Revert(ctx, "conv", 1, 0, 2000, token)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		// Restoring a previous level
+		_, err = store.Advance(ctx, "conv", reverted, 1, 3000, token)
+		require.NoError(t, err)
+		state, err = store.State(ctx, "conv")
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, "conv", state, 2, 4000, token)
+		require.NoError(t, err)
+		applied, err = store.Revert(ctx, "conv", 2, 1, 3000, token)
+		require.NoError(t, err)
+		assert.True(t, applied)
+		state, err = store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 1, state.Level)
+	})
+
+	t.Run("RevertWhilePaused", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, "conv", state, 1, 3000, token)
+		require.NoError(t, err)
+
+		// Paused while the notification was being sent
+		applied, err := store.Pause(ctx, "conv", 2500, math.Inf(1))
+		require.NoError(t, err)
+		require.True(t, applied)
+
+		applied, err = store.Revert(ctx, "conv", 1, 0, 2000, token)
+		require.NoError(t, err)
+		assert.True(t, applied)
+
+		// The level is handed back but the pause still holds it
+		reverted, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 0, reverted.Level)
+		assert.True(t, math.IsInf(reverted.Deadline, 1))
+		due, err := store.Due(ctx, 0, math.MaxInt64, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+	})
+
+	t.Run("PauseAndResume", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		applied, err := store.Pause(ctx, "conv", 1500, math.Inf(1))
+		require.NoError(t, err)
+		assert.False(t, applied, "only waiting conversations can be paused")
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		scanned, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+
+		applied, err = store.Pause(ctx, "conv", 1500, math.Inf(1))
+		require.NoError(t, err)
+		assert.True(t, applied)
+
+		paused, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), paused.PausedAt)
+		assert.True(t, math.IsInf(paused.Deadline, 1))
+		due, err := store.Due(ctx, 0, math.MaxInt64, 0, 10)
+		require.NoError(t, err)
+		assert.Empty(t, due)
+
+		// Detection can't escalate it, even from a scan made before the pause
+		applied, err = store.Advance(ctx, "conv", scanned, 1, 3000, token)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		// Snoozing it keeps the time the clock stopped
+		applied, err = store.Pause(ctx, "conv", 1800, 9000)
+		require.NoError(t, err)
+		assert.True(t, applied)
+		snoozed, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), snoozed.PausedAt)
+		assert.Equal(t, float64(9000), snoozed.Deadline)
+
+		// Resuming from a stale read writes nothing
+		applied, err = store.Resume(ctx, "conv", scanned, 8500, 9500)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		applied, err = store.Resume(ctx, "conv", snoozed, 8500, 9500)
+		require.NoError(t, err)
+		assert.True(t, applied)
+		resumed, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, ConversationState{AgentMessageTime: 8500, Deadline: 9500, Attributes: attrs}, resumed)
+
+		applied, err = store.Resume(ctx, "conv", snoozed, 8500, 9500)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		// Re-tracking drops the pause
+		_, err = store.Pause(ctx, "conv", 9000, math.Inf(1))
+		require.NoError(t, err)
+		require.NoError(t, store.Track(ctx, "conv", "", 10000, 11000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.False(t, state.Paused())
+	})
+
+	t.Run("Force", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+
+		applied, err := store.Force(ctx, "conv", state, 3, 1200)
+		require.NoError(t, err)
+		assert.True(t, applied)
+
+		forced, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 3, forced.ForcedLevel)
+		assert.Equal(t, float64(1200), forced.Deadline)
+
+		// The detector escalated it since state was read
+		_, err = store.Advance(ctx, "conv", forced, 3, math.Inf(1), token)
+		require.NoError(t, err)
+		applied, err = store.Force(ctx, "conv", state, 2, 1300)
+		require.NoError(t, err)
+		assert.False(t, applied)
+
+		// Paused conversations can't be forced
+		require.NoError(t, store.Track(ctx, "conv", "", 5000, 6000, attrs))
+		state, err = store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Zero(t, state.ForcedLevel, "re-tracking drops a forced level")
+		_, err = store.Pause(ctx, "conv", 5500, math.Inf(1))
+		require.NoError(t, err)
+		applied, err = store.Force(ctx, "conv", state, 1, 5500)
+		require.NoError(t, err)
+		assert.False(t, applied)
+	})
+
+	t.Run("History", func(t *testing.T) {
+		store, _ := newStore(t, 2)
+
+		history, err := store.History(ctx, "conv")
+		require.NoError(t, err)
+		assert.Empty(t, history)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		base := time.UnixMilli(1000).UTC()
+		for level := 0; level < 4; level++ {
+			require.NoError(t, store.Record(ctx, "conv", models.HistoryEvent{
+				Type:      models.HistoryEscalated,
+				Timestamp: base.Add(time.Duration(level) * time.Second),
+				ActorID:   "pod",
+				Level:     level,
+			}))
+		}
+
+		// Only the latest events are kept, oldest first
+		history, err = store.History(ctx, "conv")
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		for i, event := range history {
+			assert.Equal(t, i+1, event.Level)
+			assert.True(t, base.Add(time.Duration(i+1)*time.Second).Equal(event.Timestamp))
+		}
+
+		// ...and outlive the conversation
+		require.NoError(t, store.Clear(ctx, "conv", "", 2000))
+		require.NoError(t, store.Record(ctx, "conv", models.HistoryEvent{Type: models.HistoryCleared, Timestamp: base}))
+		history, err = store.History(ctx, "conv")
+		require.NoError(t, err)
+		require.Len(t, history, 3)
+		assert.Equal(t, models.HistoryCleared, history[2].Type)
+
+		other, err := store.History(ctx, "other")
+		require.NoError(t, err)
+		assert.Empty(t, other)
+	})
+
+	t.Run("EventOrdering", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "conv", "m1", 1000, 2000, attrs))
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, "conv", state, 1, 3000, token)
+		require.NoError(t, err)
+
+		// A retried agent message doesn't restart the wait
+		assert.ErrorIs(t, store.Track(ctx, "conv", "m1", 1000, 2000, attrs), ErrDuplicateEvent)
+		escalated, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, 1, escalated.Level)
+
+		// A customer response sent before the agent message arrives late
+		assert.ErrorIs(t, store.Clear(ctx, "conv", "r1", 900), ErrStaleEvent)
+		unchanged, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, escalated, unchanged)
+
+		// An agent message sent before the customer response arrives late
+		require.NoError(t, store.Clear(ctx, "conv", "r2", 1500))
+		assert.ErrorIs(t, store.Track(ctx, "conv", "m0", 1200, 2200, attrs), ErrStaleEvent)
+		cleared, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Zero(t, cleared.AgentMessageTime)
+		assert.ErrorIs(t, store.Clear(ctx, "conv", "r2", 1500), ErrDuplicateEvent)
+
+		// Events at the same time apply, and without a message ID nothing is
+		// a duplicate
+		require.NoError(t, store.Track(ctx, "conv", "", 1500, 2500, attrs))
+		require.NoError(t, store.Track(ctx, "conv", "", 1500, 2500, attrs))
+		tracked, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), tracked.AgentMessageTime)
+
+		// Other conversations are ordered apart
+		require.NoError(t, store.Track(ctx, "other", "m1", 100, 200, attrs))
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		store, _ := newStore(t, 2)
+
+		errs := store.Apply(ctx, []Event{
+			{ConversationID: "a", MessageID: "m1", TimestampMS: 1000, Deadline: 2000, Attributes: attrs},
+			{ConversationID: "b", MessageID: "m1", TimestampMS: 1000, Deadline: 2000},
+			{ConversationID: "a", MessageID: "m1", TimestampMS: 1000, Deadline: 2000, Attributes: attrs},
+			{ConversationID: "b", MessageID: "r1", TimestampMS: 1500, Clear: true},
+			{ConversationID: "b", MessageID: "m2", TimestampMS: 1200, Deadline: 2200},
+		})
+		require.Len(t, errs, 5)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.ErrorIs(t, errs[2], ErrDuplicateEvent)
+		assert.NoError(t, errs[3])
+		assert.ErrorIs(t, errs[4], ErrStaleEvent)
+
+		a, err := store.State(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), a.AgentMessageTime)
+		assert.Equal(t, float64(2000), a.Deadline)
+		assert.Equal(t, attrs, a.Attributes)
+		b, err := store.State(ctx, "b")
+		require.NoError(t, err)
+		assert.Zero(t, b.AgentMessageTime)
+
+		assert.Empty(t, store.Apply(ctx, nil))
+
+		require.NoError(t, store.RecordAll(ctx, []HistoryEntry{
+			{ConversationID: "a", Event: models.HistoryEvent{Type: models.HistoryTracked, Timestamp: time.UnixMilli(1000).UTC()}},
+			{ConversationID: "b", Event: models.HistoryEvent{Type: models.HistoryCleared, Timestamp: time.UnixMilli(1500).UTC()}},
+			{ConversationID: "a", Event: models.HistoryEvent{Type: models.HistoryPaused, Timestamp: time.UnixMilli(1600).UTC()}},
+		}))
+		history, err := store.History(ctx, "a")
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, models.HistoryTracked, history[0].Type)
+		assert.Equal(t, models.HistoryPaused, history[1].Type)
+	})
+
+	t.Run("Fencing", func(t *testing.T) {
+		store, newEpoch := newStore(t, 2)
+
+		require.NoError(t, store.Track(ctx, "conv", "", 1000, 2000, attrs))
+		s := shard.For("conv", 2)
+		state, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+
+		// No leader has been elected yet
+		_, err = store.Advance(ctx, "conv", state, 1, 3000, 0)
+		assert.ErrorIs(t, err, ErrStaleFencingToken)
+
+		oldToken := newEpoch(s)
+		newToken := newEpoch(s)
+
+		_, err = store.Advance(ctx, "conv", state, 1, 3000, oldToken)
+		assert.ErrorIs(t, err, ErrStaleFencingToken)
+		_, err = store.Revert(ctx, "conv", 1, 0, 2000, oldToken)
+		assert.ErrorIs(t, err, ErrStaleFencingToken)
+
+		// Nothing was written
+		unchanged, err := store.State(ctx, "conv")
+		require.NoError(t, err)
+		assert.Equal(t, state, unchanged)
+
+		// Another shard's epoch doesn't count
+		otherToken := newEpoch(1 - s)
+		require.NotEqual(t, newToken, otherToken)
+		_, err = store.Advance(ctx, "conv", state, 1, 3000, otherToken)
+		assert.ErrorIs(t, err, ErrStaleFencingToken)
+
+		applied, err := store.Advance(ctx, "conv", state, 1, 3000, newToken)
+		require.NoError(t, err)
+		assert.True(t, applied)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		store, newEpoch := newStore(t, 1)
+		token := newEpoch(0)
+
+		require.NoError(t, store.Track(ctx, "old", "", 1000, 2000, attrs))
+		require.NoError(t, store.Track(ctx, "cutoff", "", 1500, 2500, attrs))
+		require.NoError(t, store.Track(ctx, "new", "", 3000, 4000, attrs))
+		state, err := store.State(ctx, "old")
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, "old", state, 1, 3000, token)
+		require.NoError(t, err)
+
+		removed, err := store.Cleanup(ctx, 0, 1500)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), removed)
+
+		for _, id := range []string{"old", "cutoff"} {
+			state, err := store.State(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, ConversationState{}, state, id)
+		}
+		due, err := store.Due(ctx, 0, math.MaxInt64, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"new"}, due)
+
+		removed, err = store.Cleanup(ctx, 0, 1500)
+		require.NoError(t, err)
+		assert.Zero(t, removed)
+	})
+}
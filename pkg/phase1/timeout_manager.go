@@ -2,35 +2,53 @@ package phase1
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
+	"math"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/shard"
 )
 
+// ErrInvalidCursor is returned by ListConversations for a cursor it didn't
+// issue
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type TimeoutManager struct {
-	rdb     *redis.Client
-	config  *config.Config
-	logger  *logrus.Logger
-	metrics *metrics.Metrics
+	store     TimeoutStore
+	config    *config.Config
+	logger    *logrus.Logger
+	metrics   *metrics.Metrics
+	evaluator *escalation.Evaluator
+	clock     clock.Clock
 }
 
-func NewTimeoutManager(rdb *redis.Client, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics) *TimeoutManager {
+// NewTimeoutManager creates a timeout manager that schedules tracked
+// conversations in store by the first deadline of their escalation policy
+func NewTimeoutManager(store TimeoutStore, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics, evaluator *escalation.Evaluator, clock clock.Clock) *TimeoutManager {
 	return &TimeoutManager{
-		rdb:     rdb,
-		config:  config,
-		logger:  logger,
-		metrics: metrics,
+		store:     store,
+		config:    config,
+		logger:    logger,
+		metrics:   metrics,
+		evaluator: evaluator,
+		clock:     clock,
 	}
 }
 
-// TrackAgentMessage starts tracking timeout for a conversation
+// TrackAgentMessage starts tracking timeout for a conversation. It returns
+// ErrStaleEvent for a message older than the latest agent message or customer
+// response applied to the conversation, and ErrDuplicateEvent for a message
+// already applied; neither changes anything.
 func (tm *TimeoutManager) TrackAgentMessage(ctx context.Context, agentMsg models.AgentMessage) error {
 	start := time.Now()
 	defer func() {
@@ -39,25 +57,27 @@ func (tm *TimeoutManager) TrackAgentMessage(ctx context.Context, agentMsg models
 
 	timestamp := agentMsg.Timestamp.UnixMilli()
 
-	// Use Redis pipeline for atomic operations
-	pipe := tm.rdb.Pipeline()
-
-	// Add to waiting conversations sorted set
-	pipe.ZAdd(ctx, WaitingConversationsKey, &redis.Z{
-		Score:  float64(timestamp),
-		Member: agentMsg.ConversationID,
-	})
+	attrs := agentMsg.Attributes()
+	policy := tm.evaluator.Select(attrs)
+	deadline := DeadlineScore(tm.evaluator.NextDeadlineMS(policy, attrs, timestamp, 0))
 
-	// Clear any existing notification state
-	pipe.HDel(ctx, NotificationStatesKey, agentMsg.ConversationID)
-
-	// Execute pipeline
-	_, err := pipe.Exec(ctx)
-	if err != nil {
+	// Due at the first level
+	if err := tm.store.Track(ctx, agentMsg.ConversationID, agentMsg.MessageID, timestamp, deadline, attrs); err != nil {
+		if IsIgnoredEvent(err) {
+			tm.logIgnored(err, agentMsg.ConversationID, agentMsg.MessageID, agentMsg.Timestamp)
+			return err
+		}
 		tm.logger.WithError(err).WithField("conversation_id", agentMsg.ConversationID).Error("Failed to track agent message")
 		return fmt.Errorf("failed to track agent message: %w", err)
 	}
 
+	RecordHistory(ctx, tm.store, tm.logger, agentMsg.ConversationID, models.HistoryEvent{
+		Type:      models.HistoryTracked,
+		Timestamp: agentMsg.Timestamp,
+		ActorID:   agentMsg.AgentID,
+		Detail:    "policy " + policy.Name,
+	})
+
 	tm.logger.WithFields(logrus.Fields{
 		"conversation_id": agentMsg.ConversationID,
 		"agent_id":        agentMsg.AgentID,
@@ -67,29 +87,29 @@ func (tm *TimeoutManager) TrackAgentMessage(ctx context.Context, agentMsg models
 	return nil
 }
 
-// ClearTimeout removes timeout tracking when customer responds
+// ClearTimeout removes timeout tracking when customer responds. Stale and
+// duplicate responses are ignored as by TrackAgentMessage.
 func (tm *TimeoutManager) ClearTimeout(ctx context.Context, customerResp models.CustomerResponse) error {
 	start := time.Now()
 	defer func() {
 		tm.metrics.RedisOperationDuration.WithLabelValues("clear_timeout").Observe(time.Since(start).Seconds())
 	}()
 
-	// Use Redis pipeline for atomic operations
-	pipe := tm.rdb.Pipeline()
-
-	// Remove from waiting conversations
-	pipe.ZRem(ctx, WaitingConversationsKey, customerResp.ConversationID)
-
-	// Clear notification state
-	pipe.HDel(ctx, NotificationStatesKey, customerResp.ConversationID)
-
-	// Execute pipeline
-	_, err := pipe.Exec(ctx)
-	if err != nil {
+	if err := tm.store.Clear(ctx, customerResp.ConversationID, customerResp.MessageID, customerResp.Timestamp.UnixMilli()); err != nil {
+		if IsIgnoredEvent(err) {
+			tm.logIgnored(err, customerResp.ConversationID, customerResp.MessageID, customerResp.Timestamp)
+			return err
+		}
 		tm.logger.WithError(err).WithField("conversation_id", customerResp.ConversationID).Error("Failed to clear timeout")
 		return fmt.Errorf("failed to clear timeout: %w", err)
 	}
 
+	RecordHistory(ctx, tm.store, tm.logger, customerResp.ConversationID, models.HistoryEvent{
+		Type:      models.HistoryCleared,
+		Timestamp: customerResp.Timestamp,
+		ActorID:   customerResp.CustomerID,
+	})
+
 	tm.logger.WithFields(logrus.Fields{
 		"conversation_id": customerResp.ConversationID,
 		"customer_id":     customerResp.CustomerID,
@@ -99,18 +119,54 @@ func (tm *TimeoutManager) ClearTimeout(ctx context.Context, customerResp models.
 	return nil
 }
 
-// GetWaitingConversationsCount returns the current number of waiting conversations
+// IsIgnoredEvent reports whether err is ErrStaleEvent or ErrDuplicateEvent:
+// the event was a no-op rather than a failure
+func IsIgnoredEvent(err error) bool {
+	return errors.Is(err, ErrStaleEvent) || errors.Is(err, ErrDuplicateEvent)
+}
+
+// EventResult describes the outcome of TrackAgentMessage, ClearTimeout or an
+// event of ApplyEvents for API responses: "applied", "stale" or "duplicate"
+// when it succeeded, "rejected" when the event was invalid and "failed"
+// otherwise
+func EventResult(err error) string {
+	switch {
+	case err == nil:
+		return "applied"
+	case errors.Is(err, ErrStaleEvent):
+		return "stale"
+	case errors.Is(err, ErrDuplicateEvent):
+		return "duplicate"
+	case errors.Is(err, ErrInvalidEvent):
+		return "rejected"
+	}
+	return "failed"
+}
+
+func (tm *TimeoutManager) logIgnored(err error, conversationID, messageID string, timestamp time.Time) {
+	tm.logger.WithFields(logrus.Fields{
+		"conversation_id": conversationID,
+		"message_id":      messageID,
+		"timestamp":       timestamp,
+	}).Debugf("Ignored %s event", EventResult(err))
+}
+
+// GetWaitingConversationsCount returns the current number of waiting
+// conversations across all shards
 func (tm *TimeoutManager) GetWaitingConversationsCount(ctx context.Context) (int64, error) {
 	start := time.Now()
 	defer func() {
 		tm.metrics.RedisOperationDuration.WithLabelValues("get_waiting_count").Observe(time.Since(start).Seconds())
 	}()
 
-	count, err := tm.rdb.ZCard(ctx, WaitingConversationsKey).Result()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get waiting conversations count: %w", err)
+	var count int64
+	for s := 0; s < tm.config.Shards(); s++ {
+		waiting, err := tm.store.Waiting(ctx, s)
+		if err != nil {
+			return 0, err
+		}
+		count += waiting
 	}
-
 	return count, nil
 }
 
@@ -121,39 +177,181 @@ func (tm *TimeoutManager) GetNotificationState(ctx context.Context, conversation
 		tm.metrics.RedisOperationDuration.WithLabelValues("get_notification_state").Observe(time.Since(start).Seconds())
 	}()
 
-	levelStr, err := tm.rdb.HGet(ctx, NotificationStatesKey, conversationID).Result()
+	state, err := tm.store.State(ctx, conversationID)
 	if err != nil {
-		if err == redis.Nil {
-			return 0, nil // No notification sent yet
-		}
-		return 0, fmt.Errorf("failed to get notification state: %w", err)
+		return 0, err
 	}
+	return state.Level, nil // 0 when no notification was sent yet
+}
+
+// GetConversation returns the escalation status of a conversation; it is
+// reported as not tracked when it isn't waiting for a customer response
+func (tm *TimeoutManager) GetConversation(ctx context.Context, conversationID string) (models.ConversationStatus, error) {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues("get_conversation").Observe(time.Since(start).Seconds())
+	}()
+
+	state, err := tm.store.State(ctx, conversationID)
+	if err != nil {
+		return models.ConversationStatus{}, fmt.Errorf("failed to get conversation: %w", err)
+	}
+	return tm.conversationStatus(conversationID, state), nil
+}
+
+// ConversationFilter narrows the conversations ListConversations returns
+type ConversationFilter struct {
+	// Level, when set, keeps only conversations at that notification level
+	Level *int
+	// OlderThan keeps only conversations whose agent message is at least
+	// that old
+	OlderThan time.Duration
+}
 
-	level, err := strconv.Atoi(levelStr)
+// ListConversations returns up to limit waiting conversations matching
+// filter, shard by shard and oldest agent message first within a shard. Pass
+// the returned cursor to get the next page; it is empty after the last one.
+func (tm *TimeoutManager) ListConversations(ctx context.Context, filter ConversationFilter, cursor string, limit int) ([]models.ConversationStatus, string, error) {
+	start := time.Now()
+	defer func() {
+		tm.metrics.RedisOperationDuration.WithLabelValues("list_conversations").Observe(time.Since(start).Seconds())
+	}()
+
+	pos, err := decodeListCursor(cursor)
 	if err != nil {
-		return 0, fmt.Errorf("invalid notification level format: %w", err)
+		return nil, "", err
+	}
+	until := tm.clock.Now().Add(-filter.OlderThan).UnixMilli()
+
+	conversations := make([]models.ConversationStatus, 0, limit)
+	for pos.Shard < tm.config.Shards() {
+		page, err := tm.store.List(ctx, pos.Shard, pos.AgentMessageTime, pos.ConversationID, until, int64(limit))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list conversations: %w", err)
+		}
+
+		for _, c := range page.Conversations {
+			pos.AgentMessageTime, pos.ConversationID = c.State.AgentMessageTime, c.ConversationID
+			if filter.Level != nil && c.State.Level != *filter.Level {
+				continue
+			}
+
+			conversations = append(conversations, tm.conversationStatus(c.ConversationID, c.State))
+			if len(conversations) == limit {
+				return conversations, pos.encode(), nil
+			}
+		}
+
+		// Resume after the last conversation scanned, even when it and the
+		// rest of the page were cleared while the page was read
+		if page.Done {
+			pos = listCursor{Shard: pos.Shard + 1}
+		} else {
+			pos.AgentMessageTime, pos.ConversationID = page.LastMS, page.LastID
+		}
+	}
+	return conversations, "", nil
+}
+
+// conversationStatus describes a conversation from its stored state
+func (tm *TimeoutManager) conversationStatus(conversationID string, state ConversationState) models.ConversationStatus {
+	status := models.ConversationStatus{
+		ConversationID: conversationID,
+		Status:         models.StatusNotTracked,
+		Shard:          shard.For(conversationID, tm.config.Shards()),
+	}
+	if state.AgentMessageTime == 0 {
+		return status
+	}
+
+	policy := tm.evaluator.Select(state.Attributes)
+	agentMessageTime := time.UnixMilli(state.AgentMessageTime)
+
+	status.Tracked = true
+	status.Status = models.StatusWaiting
+	status.AgentMessageTime = &agentMessageTime
+	status.Level = state.Level
+	status.Policy = policy.Name
+	status.Attributes = state.Attributes
+	if state.Level > 0 {
+		status.LevelName = tm.evaluator.Level(policy, state.Level).Name
+	}
+
+	if state.ForcedLevel > state.Level {
+		status.ForcedLevel = state.ForcedLevel
+	}
+
+	if state.Paused() {
+		pausedAt := time.UnixMilli(state.PausedAt)
+		status.Status = models.StatusPaused
+		status.PausedAt = &pausedAt
+		if !math.IsInf(state.Deadline, 1) {
+			until := time.UnixMilli(int64(state.Deadline))
+			status.Status = models.StatusSnoozed
+			status.SnoozedUntil = &until
+		}
+		return status
+	}
+
+	if math.IsInf(state.Deadline, 1) {
+		status.Status = models.StatusExhausted
+		return status
 	}
+	deadline := time.UnixMilli(int64(state.Deadline))
+	status.NextLevel = tm.evaluator.Level(policy, state.Level+1).Name
+	status.NextDeadline = &deadline
+	status.Overdue = !deadline.After(tm.clock.Now())
+	return status
+}
 
-	return level, nil
+// listCursor is where ListConversations resumes: after the conversation
+// waiting since AgentMessageTime in Shard
+type listCursor struct {
+	Shard            int    `json:"s"`
+	AgentMessageTime int64  `json:"t"`
+	ConversationID   string `json:"c"`
 }
 
-// CleanupExpiredConversations removes conversations that have been waiting too long
-func (tm *TimeoutManager) CleanupExpiredConversations(ctx context.Context, maxAge time.Duration) error {
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(cursor string) (listCursor, error) {
+	var pos listCursor
+	if cursor == "" {
+		return pos, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pos, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &pos); err != nil || pos.Shard < 0 {
+		return pos, ErrInvalidCursor
+	}
+	return pos, nil
+}
+
+// CleanupExpiredConversations removes conversations of a shard that have been
+// waiting too long
+func (tm *TimeoutManager) CleanupExpiredConversations(ctx context.Context, shard int, maxAge time.Duration) error {
 	start := time.Now()
 	defer func() {
 		tm.metrics.RedisOperationDuration.WithLabelValues("cleanup_expired").Observe(time.Since(start).Seconds())
 	}()
 
-	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	cutoff := tm.clock.Now().Add(-maxAge).UnixMilli()
 
 	// Remove conversations older than maxAge
-	removed, err := tm.rdb.ZRemRangeByScore(ctx, WaitingConversationsKey, "0", fmt.Sprintf("%d", cutoff)).Result()
+	removed, err := tm.store.Cleanup(ctx, shard, cutoff)
 	if err != nil {
-		return fmt.Errorf("failed to cleanup expired conversations: %w", err)
+		return err
 	}
 
 	if removed > 0 {
 		tm.logger.WithFields(logrus.Fields{
+			"shard":         shard,
 			"removed_count": removed,
 			"max_age":       maxAge,
 		}).Info("Cleaned up expired conversations")
@@ -161,3 +359,13 @@ func (tm *TimeoutManager) CleanupExpiredConversations(ctx context.Context, maxAg
 
 	return nil
 }
+
+// DeadlineScore turns a deadline from escalation.Evaluator.NextDeadlineMS into
+// a waiting conversations score; conversations past their last level are
+// parked at +inf until they are cleared
+func DeadlineScore(deadlineMS int64, ok bool) float64 {
+	if !ok {
+		return math.Inf(1)
+	}
+	return float64(deadlineMS)
+}
@@ -3,19 +3,27 @@ package phase1
 import (
 	"context"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
 	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/shard"
 )
 
+// testKeys are the keys of the only shard the tests run with, unless they set
+// ShardCount
+var testKeys = KeysFor(0)
+
 func setupTestRedis(t *testing.T) *redis.Client {
 	rdb := redis.NewClient(&redis.Options{
 		Addr: "localhost:6379",
@@ -43,9 +51,10 @@ func TestTimeoutManager_TrackAgentMessage(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel) // Reduce noise in tests
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	tm := NewTimeoutManager(rdb, cfg, logger, metrics)
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	ctx := context.Background()
 	agentMsg := models.AgentMessage{
@@ -58,13 +67,17 @@ func TestTimeoutManager_TrackAgentMessage(t *testing.T) {
 	err := tm.TrackAgentMessage(ctx, agentMsg)
 	assert.NoError(t, err)
 
-	// Verify conversation is tracked
-	score, err := rdb.ZScore(ctx, WaitingConversationsKey, agentMsg.ConversationID).Result()
+	// Verify conversation is tracked, due at the first level
+	score, err := rdb.ZScore(ctx, testKeys.Waiting, agentMsg.ConversationID).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(agentMsg.Timestamp.UnixMilli()+30000), score)
+
+	since, err := rdb.ZScore(ctx, testKeys.WaitingSince, agentMsg.ConversationID).Result()
 	assert.NoError(t, err)
-	assert.Equal(t, float64(agentMsg.Timestamp.UnixMilli()), score)
+	assert.Equal(t, float64(agentMsg.Timestamp.UnixMilli()), since)
 
 	// Verify notification state is cleared
-	exists, err := rdb.HExists(ctx, NotificationStatesKey, agentMsg.ConversationID).Result()
+	exists, err := rdb.HExists(ctx, testKeys.NotificationStates, agentMsg.ConversationID).Result()
 	assert.NoError(t, err)
 	assert.False(t, exists)
 }
@@ -79,9 +92,10 @@ func TestTimeoutManager_ClearTimeout(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	tm := NewTimeoutManager(rdb, cfg, logger, metrics)
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	ctx := context.Background()
 	conversationID := "conv_123"
@@ -97,7 +111,7 @@ func TestTimeoutManager_ClearTimeout(t *testing.T) {
 	require.NoError(t, err)
 
 	// Set a notification state
-	err = rdb.HSet(ctx, NotificationStatesKey, conversationID, 1).Err()
+	err = rdb.HSet(ctx, testKeys.NotificationStates, conversationID, 1).Err()
 	require.NoError(t, err)
 
 	// Clear timeout
@@ -111,16 +125,146 @@ func TestTimeoutManager_ClearTimeout(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Verify conversation is removed from waiting list
-	_, err = rdb.ZScore(ctx, WaitingConversationsKey, conversationID).Result()
+	_, err = rdb.ZScore(ctx, testKeys.Waiting, conversationID).Result()
 	assert.Error(t, err) // Should return redis.Nil error
 	assert.Equal(t, redis.Nil, err)
 
 	// Verify notification state is cleared
-	exists, err := rdb.HExists(ctx, NotificationStatesKey, conversationID).Result()
+	exists, err := rdb.HExists(ctx, testKeys.NotificationStates, conversationID).Result()
 	assert.NoError(t, err)
 	assert.False(t, exists)
 }
 
+func TestTimeoutManager_OutOfOrderEvents(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 30000,
+		PodID:             "test-pod",
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	now := time.Now().Truncate(time.Millisecond)
+	clk := clock.NewManual(now)
+
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	ctx := context.Background()
+	conversationID := "conv_123"
+
+	// The customer responded after the agent, but the response arrives first
+	err := tm.ClearTimeout(ctx, models.CustomerResponse{
+		ConversationID: conversationID,
+		CustomerID:     "customer_123",
+		MessageID:      "msg_2",
+		Timestamp:      now.Add(time.Second),
+	})
+	require.NoError(t, err)
+
+	err = tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: conversationID,
+		AgentID:        "agent_456",
+		MessageID:      "msg_1",
+		Timestamp:      now,
+	})
+	assert.ErrorIs(t, err, ErrStaleEvent)
+	assert.Equal(t, "stale", EventResult(err))
+
+	status, err := tm.GetConversation(ctx, conversationID)
+	require.NoError(t, err)
+	assert.False(t, status.Tracked)
+
+	// A redelivered agent message is applied once
+	agentMsg := models.AgentMessage{
+		ConversationID: conversationID,
+		AgentID:        "agent_456",
+		MessageID:      "msg_3",
+		Timestamp:      now.Add(2 * time.Second),
+	}
+	require.NoError(t, tm.TrackAgentMessage(ctx, agentMsg))
+	err = tm.TrackAgentMessage(ctx, agentMsg)
+	assert.ErrorIs(t, err, ErrDuplicateEvent)
+	assert.Equal(t, "duplicate", EventResult(err))
+	assert.Equal(t, "applied", EventResult(nil))
+
+	// Only applied events are recorded
+	history, err := tm.GetHistory(ctx, conversationID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, models.HistoryCleared, history[0].Type)
+	assert.Equal(t, models.HistoryTracked, history[1].Type)
+}
+
+func TestTimeoutManager_ApplyEvents(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 30000,
+		PodID:             "test-pod",
+		ShardCount:        4,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	now := time.Now().Truncate(time.Millisecond)
+	clk := clock.NewManual(now)
+
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	ctx := context.Background()
+	events := []models.ConversationEvent{
+		{Type: models.EventAgentMessage, ConversationID: "conv_1", AgentID: "agent_1", MessageID: "msg_1", Timestamp: now},
+		{Type: models.EventAgentMessage, ConversationID: "conv_2", AgentID: "agent_1", MessageID: "msg_2"},
+		{Type: models.EventCustomerResponse, ConversationID: "conv_2", CustomerID: "customer_1", MessageID: "msg_3", Timestamp: now.Add(time.Second)},
+		{Type: models.EventAgentMessage, ConversationID: "conv_1", AgentID: "agent_1", MessageID: "msg_1", Timestamp: now},
+		{Type: "unknown", ConversationID: "conv_3"},
+		{Type: models.EventAgentMessage, AgentID: "agent_1"},
+		{Type: models.EventAgentMessage, ConversationID: "conv_4", Policy: "undefined"},
+	}
+
+	errs := tm.ApplyEvents(ctx, events)
+	require.Len(t, errs, len(events))
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.NoError(t, errs[2])
+	assert.ErrorIs(t, errs[3], ErrDuplicateEvent)
+	assert.ErrorIs(t, errs[4], ErrInvalidEventType)
+	assert.ErrorIs(t, errs[5], ErrMissingConversationID)
+	assert.ErrorIs(t, errs[6], ErrUnknownPolicy)
+
+	// Events without a timestamp happen now
+	status, err := tm.GetConversation(ctx, "conv_1")
+	require.NoError(t, err)
+	assert.True(t, status.Tracked)
+	status, err = tm.GetConversation(ctx, "conv_2")
+	require.NoError(t, err)
+	assert.False(t, status.Tracked)
+
+	count, err := tm.GetWaitingConversationsCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Applied events are recorded, once
+	history, err := tm.GetHistory(ctx, "conv_2")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, models.HistoryTracked, history[0].Type)
+	assert.Equal(t, models.HistoryCleared, history[1].Type)
+	history, err = tm.GetHistory(ctx, "conv_1")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	results := BatchResults(events, errs)
+	require.Len(t, results, len(events))
+	assert.Equal(t, models.BatchEventResult{Index: 3, Type: models.EventAgentMessage, ConversationID: "conv_1", Success: true, Result: "duplicate"}, results[3])
+	assert.Equal(t, "rejected", results[6].Result)
+	assert.False(t, results[6].Success)
+	assert.Equal(t, ErrUnknownPolicy.Error(), results[6].Error)
+}
+
 func TestTimeoutManager_GetWaitingConversationsCount(t *testing.T) {
 	rdb := setupTestRedis(t)
 	defer rdb.Close()
@@ -131,9 +275,10 @@ func TestTimeoutManager_GetWaitingConversationsCount(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	tm := NewTimeoutManager(rdb, cfg, logger, metrics)
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	ctx := context.Background()
 
@@ -170,9 +315,10 @@ func TestTimeoutManager_GetNotificationState(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	tm := NewTimeoutManager(rdb, cfg, logger, metrics)
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	ctx := context.Background()
 	conversationID := "conv_123"
@@ -183,7 +329,7 @@ func TestTimeoutManager_GetNotificationState(t *testing.T) {
 	assert.Equal(t, 0, level)
 
 	// Set notification level
-	err = rdb.HSet(ctx, NotificationStatesKey, conversationID, 2).Err()
+	err = rdb.HSet(ctx, testKeys.NotificationStates, conversationID, 2).Err()
 	require.NoError(t, err)
 
 	// Should return 2
@@ -192,6 +338,117 @@ func TestTimeoutManager_GetNotificationState(t *testing.T) {
 	assert.Equal(t, 2, level)
 }
 
+func TestTimeoutManager_Shards(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 30000,
+		PodID:             "test-pod",
+		ShardCount:        4,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	ctx := context.Background()
+	perShard := make(map[int]int64)
+	for i := 0; i < 20; i++ {
+		conversationID := fmt.Sprintf("conv_%d", i)
+		require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+			ConversationID: conversationID,
+			AgentID:        "agent_456",
+			MessageID:      fmt.Sprintf("msg_%d", i),
+			Timestamp:      clk.Now(),
+		}))
+		perShard[shard.For(conversationID, 4)]++
+	}
+
+	// Each conversation is in its own shard's keys only
+	for s := 0; s < 4; s++ {
+		count, err := rdb.ZCard(ctx, KeysFor(s).Waiting).Result()
+		assert.NoError(t, err)
+		assert.Equal(t, perShard[s], count, "shard %d", s)
+	}
+	assert.Greater(t, len(perShard), 1)
+
+	count, err := tm.GetWaitingConversationsCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), count)
+}
+
+func TestTimeoutManager_MigrateUnsharded(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:  30000,
+		PodID:              "test-pod",
+		ShardCount:         4,
+		DetectionBatchSize: 1,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	ctx := context.Background()
+	sent := clk.Now().Add(-time.Minute).UnixMilli()
+
+	// Written before sharding: scored by agent message time, one already
+	// notified, one re-tracked since in its shard
+	rdb.ZAdd(ctx, WaitingConversationsKey,
+		&redis.Z{Score: float64(sent), Member: "waiting"},
+		&redis.Z{Score: float64(sent), Member: "notified"},
+		&redis.Z{Score: float64(sent), Member: "retracked"})
+	rdb.HSet(ctx, NotificationStatesKey, "notified", 1)
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "retracked",
+		MessageID:      "msg_1",
+		Timestamp:      clk.Now(),
+	}))
+
+	moved, err := tm.MigrateUnsharded(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, moved)
+
+	waiting, err := tm.GetConversation(ctx, "waiting")
+	require.NoError(t, err)
+	require.NotNil(t, waiting.AgentMessageTime)
+	assert.Equal(t, sent, waiting.AgentMessageTime.UnixMilli())
+	assert.Equal(t, 0, waiting.Level)
+
+	// Keeps its level and is due at the next one
+	notified, err := tm.GetConversation(ctx, "notified")
+	require.NoError(t, err)
+	assert.Equal(t, 1, notified.Level)
+	keys := KeysFor(shard.For("notified", 4))
+	score, err := rdb.ZScore(ctx, keys.Waiting, "notified").Result()
+	require.NoError(t, err)
+	assert.Equal(t, float64(sent+60000), score)
+
+	retracked, err := tm.GetConversation(ctx, "retracked")
+	require.NoError(t, err)
+	require.NotNil(t, retracked.AgentMessageTime)
+	assert.Equal(t, clk.Now().UnixMilli(), retracked.AgentMessageTime.UnixMilli())
+
+	for _, key := range []string{WaitingConversationsKey, NotificationStatesKey} {
+		exists, err := rdb.Exists(ctx, key).Result()
+		require.NoError(t, err)
+		assert.Zero(t, exists, key)
+	}
+
+	// Nothing left to move
+	moved, err = tm.MigrateUnsharded(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+}
+
 func TestTimeoutManager_CleanupExpiredConversations(t *testing.T) {
 	rdb := setupTestRedis(t)
 	defer rdb.Close()
@@ -202,9 +459,10 @@ func TestTimeoutManager_CleanupExpiredConversations(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	tm := NewTimeoutManager(rdb, cfg, logger, metrics)
+	tm := NewTimeoutManager(NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	ctx := context.Background()
 
@@ -231,14 +489,228 @@ func TestTimeoutManager_CleanupExpiredConversations(t *testing.T) {
 	require.NoError(t, err)
 
 	// Cleanup conversations older than 1 hour
-	err = tm.CleanupExpiredConversations(ctx, 1*time.Hour)
+	err = tm.CleanupExpiredConversations(ctx, 0, time.Hour)
 	assert.NoError(t, err)
 
 	// Old conversation should be removed
-	_, err = rdb.ZScore(ctx, WaitingConversationsKey, "old_conv").Result()
+	_, err = rdb.ZScore(ctx, testKeys.Waiting, "old_conv").Result()
 	assert.Equal(t, redis.Nil, err)
 
 	// New conversation should remain
-	_, err = rdb.ZScore(ctx, WaitingConversationsKey, "new_conv").Result()
+	_, err = rdb.ZScore(ctx, testKeys.Waiting, "new_conv").Result()
 	assert.NoError(t, err)
 }
+
+func TestTimeoutManager_GetConversation(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		ShardCount:        4,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.UnixMilli(1_700_000_000_000))
+
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	ctx := context.Background()
+	status, err := tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.False(t, status.Tracked)
+	assert.Equal(t, models.StatusNotTracked, status.Status)
+	assert.Equal(t, shard.For("conv_123", 4), status.Shard)
+
+	sent := clk.Now()
+	require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		MessageID:      "msg_789",
+		Timestamp:      sent,
+		TenantID:       "acme",
+	}))
+
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.True(t, status.Tracked)
+	assert.Equal(t, models.StatusWaiting, status.Status)
+	assert.Equal(t, sent.UnixMilli(), status.AgentMessageTime.UnixMilli())
+	assert.Equal(t, 0, status.Level)
+	assert.Empty(t, status.LevelName)
+	assert.Equal(t, "level1", status.NextLevel)
+	assert.Equal(t, sent.Add(time.Second).UnixMilli(), status.NextDeadline.UnixMilli())
+	assert.False(t, status.Overdue)
+	assert.Equal(t, "default", status.Policy)
+	assert.Equal(t, "acme", status.Attributes.TenantID)
+
+	// Past the deadline without detection running
+	clk.Advance(2 * time.Second)
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.True(t, status.Overdue)
+
+	// Past the last level
+	token, err := rdb.Incr(ctx, KeysFor(status.Shard).LeaderEpoch).Result()
+	require.NoError(t, err)
+	state, err := store.State(ctx, "conv_123")
+	require.NoError(t, err)
+	_, err = store.Advance(ctx, "conv_123", state, 3, math.Inf(1), token)
+	require.NoError(t, err)
+
+	status, err = tm.GetConversation(ctx, "conv_123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusExhausted, status.Status)
+	assert.Equal(t, 3, status.Level)
+	assert.Equal(t, "level3", status.LevelName)
+	assert.Nil(t, status.NextDeadline)
+	assert.False(t, status.Overdue)
+}
+
+func TestTimeoutManager_ListConversations(t *testing.T) {
+	rdb := setupTestRedis(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+		ShardCount:        4,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.UnixMilli(1_700_000_000_000))
+
+	store := NewRedisStore(rdb, cfg)
+	tm := NewTimeoutManager(store, cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	// Agent messages a minute apart, the oldest first; every third one
+	// notified once
+	ctx := context.Background()
+	levels := make(map[string]int)
+	tokens := make(map[int]int64)
+	for i := 0; i < 12; i++ {
+		conversationID := fmt.Sprintf("conv_%d", i)
+		require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+			ConversationID: conversationID,
+			AgentID:        "agent_456",
+			MessageID:      fmt.Sprintf("msg_%d", i),
+			Timestamp:      clk.Now().Add(time.Duration(i-12) * time.Minute),
+		}))
+		if i%3 != 0 {
+			continue
+		}
+
+		s := shard.For(conversationID, 4)
+		if tokens[s] == 0 {
+			token, err := rdb.Incr(ctx, KeysFor(s).LeaderEpoch).Result()
+			require.NoError(t, err)
+			tokens[s] = token
+		}
+		state, err := store.State(ctx, conversationID)
+		require.NoError(t, err)
+		_, err = store.Advance(ctx, conversationID, state, 1, state.Deadline+1000, tokens[s])
+		require.NoError(t, err)
+		levels[conversationID] = 1
+	}
+
+	// listAll pages through every conversation matching filter
+	listAll := func(filter ConversationFilter, limit int) []string {
+		var ids []string
+		cursor := ""
+		for pages := 0; pages < 20; pages++ {
+			page, next, err := tm.ListConversations(ctx, filter, cursor, limit)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(page), limit)
+			for _, status := range page {
+				assert.Equal(t, levels[status.ConversationID], status.Level)
+				ids = append(ids, status.ConversationID)
+			}
+			if next == "" {
+				return ids
+			}
+			cursor = next
+		}
+		t.Fatal("pagination did not terminate")
+		return nil
+	}
+
+	all := listAll(ConversationFilter{}, 5)
+	assert.Len(t, all, 12)
+	assert.ElementsMatch(t, all, listAll(ConversationFilter{}, 100))
+
+	level := 1
+	notified := listAll(ConversationFilter{Level: &level}, 2)
+	assert.ElementsMatch(t, []string{"conv_0", "conv_3", "conv_6", "conv_9"}, notified)
+
+	// Agent messages at least 5 minutes old: conv_0 to conv_7
+	old := listAll(ConversationFilter{OlderThan: 5 * time.Minute}, 3)
+	assert.Len(t, old, 8)
+	assert.NotContains(t, old, "conv_8")
+
+	_, _, err := tm.ListConversations(ctx, ConversationFilter{}, "not-a-cursor", 10)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+// clearingStore clears every conversation its first List scans, as if the
+// customers responded while the page was read
+type clearingStore struct {
+	TimeoutStore
+	cleared bool
+}
+
+func (s *clearingStore) List(ctx context.Context, shard int, afterMS int64, afterID string, until, count int64) (WaitingPage, error) {
+	page, err := s.TimeoutStore.List(ctx, shard, afterMS, afterID, until, count)
+	if err != nil || s.cleared {
+		return page, err
+	}
+
+	s.cleared = true
+	for _, c := range page.Conversations {
+		if err := s.Clear(ctx, c.ConversationID, "", c.State.AgentMessageTime+1); err != nil {
+			return WaitingPage{}, err
+		}
+	}
+	page.Conversations = nil
+	return page, nil
+}
+
+func TestTimeoutManager_ListConversations_ClearedPage(t *testing.T) {
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-pod",
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.UnixMilli(1_700_000_000_000))
+
+	store := &clearingStore{TimeoutStore: NewMemoryStore(cfg)}
+	tm := NewTimeoutManager(store, cfg, logger, metrics, testEvaluator(t, cfg), clk)
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, tm.TrackAgentMessage(ctx, models.AgentMessage{
+			ConversationID: fmt.Sprintf("conv_%d", i),
+			MessageID:      fmt.Sprintf("msg_%d", i),
+			Timestamp:      clk.Now().Add(time.Duration(i-5) * time.Minute),
+		}))
+	}
+
+	// The first page scanned is cleared entirely; the rest of the shard
+	// still follows
+	page, next, err := tm.ListConversations(ctx, ConversationFilter{}, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "conv_2", page[0].ConversationID)
+	assert.Equal(t, "conv_3", page[1].ConversationID)
+
+	page, next, err = tm.ListConversations(ctx, ConversationFilter{}, next, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "conv_4", page[0].ConversationID)
+	assert.Empty(t, next)
+}
@@ -0,0 +1,249 @@
+package phase2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/shard"
+)
+
+const (
+	DeadLetterStream = TimeoutEventsStream + ":dlq"
+	// FailureReasonsKey is the base name of the per-shard hashes holding the
+	// last failure of pending events, e.g. "timeout_events:failures:{3}"
+	FailureReasonsKey = TimeoutEventsStream + ":failures"
+
+	// deadLetterFieldPrefix marks the fields added to an entry when it is
+	// dead-lettered, next to its original fields
+	deadLetterFieldPrefix = "dlq_"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead-letter entry not found")
+
+// DeadLetterEntry is a timeout event that exhausted its delivery attempts or
+// could not be processed at all
+type DeadLetterEntry struct {
+	ID             string                 `json:"id"`
+	OriginalID     string                 `json:"original_id"`
+	Reason         string                 `json:"reason"`
+	Attempts       int                    `json:"attempts"`
+	Shard          int                    `json:"shard"`
+	DeadLetteredAt time.Time              `json:"dead_lettered_at"`
+	Values         map[string]interface{} `json:"values"`
+}
+
+// DeadLetterQueue moves failed entries off the shards' timeout events streams
+// into timeout_events:dlq, where they can be inspected, replayed or purged
+type DeadLetterQueue struct {
+	rdb    redis.UniversalClient
+	group  string
+	logger *logrus.Logger
+	clock  clock.Clock
+}
+
+func NewDeadLetterQueue(rdb redis.UniversalClient, group string, logger *logrus.Logger, clock clock.Clock) *DeadLetterQueue {
+	return &DeadLetterQueue{
+		rdb:    rdb,
+		group:  group,
+		logger: logger,
+		clock:  clock,
+	}
+}
+
+// ValidEntryID reports whether id is a stream entry ID, "<ms>-<seq>". With
+// partial, a bare "<ms>" is accepted too, as a range bound.
+func ValidEntryID(id string, partial bool) bool {
+	ms, seq, found := strings.Cut(id, "-")
+	if !found && !partial {
+		return false
+	}
+	if _, err := strconv.ParseUint(ms, 10, 64); err != nil {
+		return false
+	}
+	if found {
+		if _, err := strconv.ParseUint(seq, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Add copies a message from shard's stream to the dead-letter stream with the
+// reason it failed, then acknowledges the original. On a cluster the two
+// streams live in different slots and can't share a transaction; copying
+// first means a failure in between leaves the entry pending, to be
+// dead-lettered again, rather than lost.
+func (d *DeadLetterQueue) Add(ctx context.Context, shard int, message redis.XMessage, reason string, attempts int) error {
+	values := make(map[string]interface{}, len(message.Values)+5)
+	for field, value := range message.Values {
+		values[field] = value
+	}
+	values[deadLetterFieldPrefix+"original_id"] = message.ID
+	values[deadLetterFieldPrefix+"reason"] = reason
+	values[deadLetterFieldPrefix+"attempts"] = attempts
+	values[deadLetterFieldPrefix+"failed_at"] = d.clock.Now().UnixMilli()
+	values[deadLetterFieldPrefix+"shard"] = shard
+
+	err := d.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: DeadLetterStream,
+		Values: values,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter message: %w", err)
+	}
+
+	pipe := d.rdb.TxPipeline()
+	pipe.XAck(ctx, EventsStream(shard), d.group, message.ID)
+	pipe.HDel(ctx, FailureReasonsKeyFor(shard), message.ID)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to acknowledge dead-lettered message: %w", err)
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"shard":      shard,
+		"reason":     reason,
+		"attempts":   attempts,
+	}).Warn("Moved timeout event to dead-letter stream")
+
+	return nil
+}
+
+// List returns up to count entries starting at entry ID start ("-" for the
+// oldest)
+func (d *DeadLetterQueue) List(ctx context.Context, start string, count int64) ([]DeadLetterEntry, error) {
+	messages, err := d.rdb.XRangeN(ctx, DeadLetterStream, start, "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead-letter entries: %w", err)
+	}
+
+	entries := make([]DeadLetterEntry, 0, len(messages))
+	for _, message := range messages {
+		entries = append(entries, newDeadLetterEntry(message))
+	}
+	return entries, nil
+}
+
+// Get returns a single dead-letter entry
+func (d *DeadLetterQueue) Get(ctx context.Context, id string) (*DeadLetterEntry, error) {
+	messages, err := d.rdb.XRange(ctx, DeadLetterStream, id, id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead-letter entry: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, ErrDeadLetterNotFound
+	}
+
+	entry := newDeadLetterEntry(messages[0])
+	return &entry, nil
+}
+
+// Replay puts a dead-letter entry back on the stream of the shard it came
+// from with its attempt count reset, and returns the new stream entry ID. The
+// entry is deleted from the dead-letter stream only once it is back, so a
+// replay that fails halfway leaves it in both; the consumers' idempotency
+// ledger still notifies once.
+func (d *DeadLetterQueue) Replay(ctx context.Context, id string) (string, error) {
+	messages, err := d.rdb.XRange(ctx, DeadLetterStream, id, id).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to replay dead-letter entry: %w", err)
+	}
+	if len(messages) == 0 {
+		return "", ErrDeadLetterNotFound
+	}
+
+	entry := newDeadLetterEntry(messages[0])
+	if _, ok := entry.Values["attempt"]; ok {
+		entry.Values["attempt"] = 1
+	}
+
+	messageID, err := d.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: EventsStream(entry.Shard),
+		Values: entry.Values,
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to replay dead-letter entry: %w", err)
+	}
+
+	if err := d.rdb.XDel(ctx, DeadLetterStream, id).Err(); err != nil {
+		return "", fmt.Errorf("failed to remove replayed dead-letter entry: %w", err)
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"dead_letter_id": id,
+		"shard":          entry.Shard,
+		"message_id":     messageID,
+	}).Info("Replayed dead-letter entry")
+
+	return messageID, nil
+}
+
+// Purge deletes a single dead-letter entry
+func (d *DeadLetterQueue) Purge(ctx context.Context, id string) error {
+	deleted, err := d.rdb.XDel(ctx, DeadLetterStream, id).Result()
+	if err != nil {
+		return fmt.Errorf("failed to purge dead-letter entry: %w", err)
+	}
+	if deleted == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// PurgeAll deletes every dead-letter entry and returns how many there were
+func (d *DeadLetterQueue) PurgeAll(ctx context.Context) (int64, error) {
+	pipe := d.rdb.TxPipeline()
+	length := pipe.XLen(ctx, DeadLetterStream)
+	pipe.Del(ctx, DeadLetterStream)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter entries: %w", err)
+	}
+	return length.Val(), nil
+}
+
+// FailureReasonsKeyFor returns the failure reasons hash of a shard's stream
+func FailureReasonsKeyFor(s int) string {
+	return shard.Key(FailureReasonsKey, s)
+}
+
+func newDeadLetterEntry(message redis.XMessage) DeadLetterEntry {
+	entry := DeadLetterEntry{
+		ID:     message.ID,
+		Values: make(map[string]interface{}, len(message.Values)),
+	}
+
+	for field, value := range message.Values {
+		if !strings.HasPrefix(field, deadLetterFieldPrefix) {
+			entry.Values[field] = value
+			continue
+		}
+
+		str, _ := value.(string)
+		switch strings.TrimPrefix(field, deadLetterFieldPrefix) {
+		case "original_id":
+			entry.OriginalID = str
+		case "reason":
+			entry.Reason = str
+		case "attempts":
+			entry.Attempts, _ = strconv.Atoi(str)
+		case "shard":
+			entry.Shard, _ = strconv.Atoi(str)
+		case "failed_at":
+			if ms, err := strconv.ParseInt(str, 10, 64); err == nil {
+				entry.DeadLetteredAt = time.UnixMilli(ms)
+			}
+		}
+	}
+
+	return entry
+}
@@ -3,150 +3,156 @@ package phase2
 import (
 	"encoding/json"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gorilla/mux"
-	"github.com/sirupsen/logrus"
 
-	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/problem"
 )
 
-func (s *Service) handleAgentMessage(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	conversationID := vars["id"]
-
-	if conversationID == "" {
-		http.Error(w, "Missing conversation ID", http.StatusBadRequest)
-		return
-	}
-
-	var request struct {
-		AgentID   string    `json:"agent_id"`
-		MessageID string    `json:"message_id"`
-		Timestamp time.Time `json:"timestamp,omitempty"`
-	}
+// RegisterRoutes adds the Phase 2 endpoints to the shared HTTP API: the
+// dead-letter stream
+func (s *Service) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/dlq", s.handleListDeadLetters).Methods("GET")
+	router.HandleFunc("/dlq", s.handlePurgeDeadLetters).Methods("DELETE")
+	router.HandleFunc("/dlq/{id}", s.handleGetDeadLetter).Methods("GET")
+	router.HandleFunc("/dlq/{id}", s.handlePurgeDeadLetter).Methods("DELETE")
+	router.HandleFunc("/dlq/{id}/replay", s.handleReplayDeadLetter).Methods("POST")
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+func (s *Service) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	start := r.URL.Query().Get("start")
+	if start == "" {
+		start = "-"
+	} else if !ValidEntryID(start, true) {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid start entry ID")
 		return
 	}
 
-	if request.Timestamp.IsZero() {
-		request.Timestamp = time.Now()
-	}
-
-	agentMsg := models.AgentMessage{
-		ConversationID: conversationID,
-		AgentID:        request.AgentID,
-		MessageID:      request.MessageID,
-		Timestamp:      request.Timestamp,
+	count := int64(100)
+	if countStr := r.URL.Query().Get("count"); countStr != "" {
+		parsed, err := strconv.ParseInt(countStr, 10, 64)
+		if err != nil || parsed <= 0 {
+			problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid count")
+			return
+		}
+		count = parsed
 	}
 
-	if err := s.timeoutManager.TrackAgentMessage(r.Context(), agentMsg); err != nil {
-		s.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to track agent message")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	entries, err := s.deadLetters.List(r.Context(), start, count)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list dead-letter entries")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
 	response := map[string]interface{}{
-		"success":         true,
-		"conversation_id": conversationID,
-		"tracked_at":      request.Timestamp,
+		"entries": entries,
+		"count":   len(entries),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-
-	s.logger.WithFields(logrus.Fields{
-		"conversation_id": conversationID,
-		"agent_id":        request.AgentID,
-	}).Debug("Tracked agent message")
 }
 
-func (s *Service) handleCustomerResponse(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	conversationID := vars["id"]
-
-	if conversationID == "" {
-		http.Error(w, "Missing conversation ID", http.StatusBadRequest)
+func (s *Service) handleGetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
 		return
 	}
 
-	var request struct {
-		CustomerID string    `json:"customer_id"`
-		MessageID  string    `json:"message_id"`
-		Timestamp  time.Time `json:"timestamp,omitempty"`
+	entry, err := s.deadLetters.Get(r.Context(), id)
+	if err == ErrDeadLetterNotFound {
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeNotFound, "Dead-letter entry not found")
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err != nil {
+		s.logger.WithError(err).WithField("dead_letter_id", id).Error("Failed to get dead-letter entry")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
-	if request.Timestamp.IsZero() {
-		request.Timestamp = time.Now()
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entry)
+}
 
-	customerResp := models.CustomerResponse{
-		ConversationID: conversationID,
-		CustomerID:     request.CustomerID,
-		MessageID:      request.MessageID,
-		Timestamp:      request.Timestamp,
+func (s *Service) handleReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
 	}
 
-	if err := s.timeoutManager.ClearTimeout(r.Context(), customerResp); err != nil {
-		s.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to clear timeout")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	messageID, err := s.deadLetters.Replay(r.Context(), id)
+	if err == ErrDeadLetterNotFound {
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeNotFound, "Dead-letter entry not found")
+		return
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("dead_letter_id", id).Error("Failed to replay dead-letter entry")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
 	response := map[string]interface{}{
-		"success":         true,
-		"conversation_id": conversationID,
-		"cleared_at":      request.Timestamp,
+		"success":        true,
+		"dead_letter_id": id,
+		"message_id":     messageID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-
-	s.logger.WithFields(logrus.Fields{
-		"conversation_id": conversationID,
-		"customer_id":     request.CustomerID,
-	}).Debug("Cleared conversation timeout")
 }
 
-func (s *Service) handleHealth(w http.ResponseWriter, r *http.Request) {
-	count, err := s.timeoutManager.GetWaitingConversationsCount(r.Context())
+func (s *Service) handlePurgeDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, ok := deadLetterID(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.deadLetters.Purge(r.Context(), id)
+	if err == ErrDeadLetterNotFound {
+		problem.Respond(w, r, http.StatusNotFound, problem.CodeNotFound, "Dead-letter entry not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Health check failed", http.StatusServiceUnavailable)
+		s.logger.WithError(err).WithField("dead_letter_id", id).Error("Failed to purge dead-letter entry")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
 	response := map[string]interface{}{
-		"status":                "healthy",
-		"is_leader":             s.IsLeader(),
-		"waiting_conversations": count,
-		"timestamp":             time.Now(),
+		"success":        true,
+		"dead_letter_id": id,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (s *Service) handleStatus(w http.ResponseWriter, r *http.Request) {
-	count, err := s.timeoutManager.GetWaitingConversationsCount(r.Context())
+func (s *Service) handlePurgeDeadLetters(w http.ResponseWriter, r *http.Request) {
+	purged, err := s.deadLetters.PurgeAll(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to get status", http.StatusInternalServerError)
+		s.logger.WithError(err).Error("Failed to purge dead-letter entries")
+		problem.Respond(w, r, http.StatusInternalServerError, problem.CodeInternal, "")
 		return
 	}
 
 	response := map[string]interface{}{
-		"pod_id":                s.config.PodID,
-		"is_leader":             s.IsLeader(),
-		"waiting_conversations": count,
-		"timestamp":             time.Now(),
+		"success": true,
+		"purged":  purged,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// deadLetterID returns the entry ID of the request's path. It answers the
+// request with a problem and returns false when it isn't a stream entry ID.
+func deadLetterID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := mux.Vars(r)["id"]
+	if !ValidEntryID(id, false) {
+		problem.Respond(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, "Invalid dead-letter entry ID")
+		return "", false
+	}
+	return id, true
+}
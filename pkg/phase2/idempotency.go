@@ -0,0 +1,88 @@
+package phase2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ProcessedEventsKeyPrefix = "processed_events:"
+
+	// pendingClaimMinIdle is how long a stream entry stays unacknowledged
+	// before another consumer reclaims it
+	pendingClaimMinIdle = 1 * time.Minute
+
+	// eventProcessingMargin pads the processing claim beyond the reclaim idle
+	// time and the send, for clock drift and slow Redis round trips
+	eventProcessingMargin = 10 * time.Second
+)
+
+// eventProcessingTTL bounds how long a consumer that died mid-send can hold
+// an event. It outlasts the reclaim idle time plus a whole send, so an entry
+// reclaimed while its first consumer may still be sending finds the event
+// reserved rather than sending it a second time.
+func eventProcessingTTL(sendTimeout time.Duration) time.Duration {
+	return pendingClaimMinIdle + sendTimeout + eventProcessingMargin
+}
+
+// EventState is what the idempotency ledger knows about an event
+type EventState string
+
+const (
+	EventStateNew        EventState = "new"
+	EventStateProcessing EventState = "processing"
+	EventStateDone       EventState = "done"
+)
+
+// IdempotencyLedger records which timeout events have already been notified,
+// so a redelivered stream entry is acknowledged instead of sent again
+type IdempotencyLedger struct {
+	rdb           redis.UniversalClient
+	ttl           time.Duration
+	processingTTL time.Duration
+}
+
+// NewIdempotencyLedger creates a ledger remembering notified events for ttl
+// and holding processing claims for processingTTL
+func NewIdempotencyLedger(rdb redis.UniversalClient, ttl, processingTTL time.Duration) *IdempotencyLedger {
+	return &IdempotencyLedger{
+		rdb:           rdb,
+		ttl:           ttl,
+		processingTTL: processingTTL,
+	}
+}
+
+// Reserve claims an event for processing. EventStateNew means the caller holds
+// the claim and must follow up with Complete or Release; any other state means
+// the event is already done or being processed elsewhere.
+func (l *IdempotencyLedger) Reserve(ctx context.Context, eventID string) (EventState, error) {
+	state, err := reserveEventScript.Run(ctx, l.rdb, []string{l.key(eventID)}, l.processingTTL.Milliseconds()).Text()
+	if err != nil {
+		return "", fmt.Errorf("failed to reserve event: %w", err)
+	}
+
+	return EventState(state), nil
+}
+
+// Complete marks an event as notified for the ledger TTL
+func (l *IdempotencyLedger) Complete(ctx context.Context, eventID string) error {
+	if err := l.rdb.Set(ctx, l.key(eventID), string(EventStateDone), l.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to record processed event: %w", err)
+	}
+	return nil
+}
+
+// Release drops a claim after a failed attempt so the event can be retried
+func (l *IdempotencyLedger) Release(ctx context.Context, eventID string) error {
+	if err := l.rdb.Del(ctx, l.key(eventID)).Err(); err != nil {
+		return fmt.Errorf("failed to release event: %w", err)
+	}
+	return nil
+}
+
+func (l *IdempotencyLedger) key(eventID string) string {
+	return ProcessedEventsKeyPrefix + eventID
+}
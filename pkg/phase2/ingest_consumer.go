@@ -0,0 +1,292 @@
+package phase2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/phase1"
+	"redis-timeout-tracking-poc/pkg/validation"
+)
+
+// IngestConsumer feeds the timeout manager from the agent messages and
+// customer responses the chat backend publishes to the input stream, as an
+// alternative to the HTTP API. Pods read the stream through a consumer group,
+// so each entry is applied by one of them, and an entry is acknowledged only
+// once its event is stored; entries left pending by a failed write or a dead
+// pod are reclaimed and applied again. Stale and duplicate events are
+// acknowledged as no-ops, so redeliveries are harmless as long as events
+// carry message IDs.
+type IngestConsumer struct {
+	rdb            redis.UniversalClient
+	config         *config.Config
+	logger         *logrus.Logger
+	metrics        *metrics.Metrics
+	timeoutManager *phase1.TimeoutManager
+	reclaimer      *pendingReclaimer
+	consumerName   string
+	claimMinIdle   time.Duration
+	clock          clock.Clock
+	stopCh         chan struct{}
+}
+
+func NewIngestConsumer(rdb redis.UniversalClient, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics, timeoutManager *phase1.TimeoutManager, clock clock.Clock) *IngestConsumer {
+	consumerName := fmt.Sprintf("ingester-%s", config.PodID)
+
+	return &IngestConsumer{
+		rdb:            rdb,
+		config:         config,
+		logger:         logger,
+		metrics:        metrics,
+		timeoutManager: timeoutManager,
+		reclaimer: &pendingReclaimer{
+			rdb:         rdb,
+			group:       config.IngestConsumerGroup,
+			consumer:    consumerName,
+			maxAttempts: config.DeliveryAttempts(),
+			logger:      logger,
+		},
+		consumerName: consumerName,
+		claimMinIdle: pendingClaimMinIdle,
+		clock:        clock,
+		stopCh:       make(chan struct{}),
+	}
+}
+
+func (ic *IngestConsumer) Start(ctx context.Context) error {
+	ic.logger.WithFields(logrus.Fields{
+		"stream":        ic.config.IngestStream,
+		"consumer_name": ic.consumerName,
+	}).Info("Starting ingest consumer")
+
+	// New groups start at the end of the stream: events published before
+	// ingestion was enabled went through the HTTP API
+	err := ic.rdb.XGroupCreateMkStream(ctx, ic.config.IngestStream, ic.config.IngestConsumerGroup, "$").Err()
+	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+		return fmt.Errorf("failed to create ingest consumer group: %w", err)
+	}
+
+	go ic.consumeLoop(ctx)
+	go ic.pendingMessagesRecovery(ctx)
+
+	return nil
+}
+
+func (ic *IngestConsumer) Stop() {
+	close(ic.stopCh)
+}
+
+func (ic *IngestConsumer) consumeLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ic.stopCh:
+			return
+		default:
+			ic.consumeMessages(ctx)
+		}
+	}
+}
+
+func (ic *IngestConsumer) consumeMessages(ctx context.Context) {
+	start := time.Now()
+
+	streams, err := ic.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    ic.config.IngestConsumerGroup,
+		Consumer: ic.consumerName,
+		Streams:  []string{ic.config.IngestStream, ">"},
+		Count:    ic.config.IngestBatch(),
+		Block:    1 * time.Second,
+	}).Result()
+
+	if err != nil {
+		if err != redis.Nil {
+			ic.logger.WithError(err).Error("Failed to read from ingest stream")
+		}
+		return
+	}
+
+	for _, stream := range streams {
+		entries := make([]claimedEntry, 0, len(stream.Messages))
+		for _, message := range stream.Messages {
+			// First delivery
+			entries = append(entries, claimedEntry{message: message, attempt: 1})
+		}
+		ic.applyMessages(ctx, entries)
+	}
+
+	if len(streams) > 0 {
+		ic.metrics.StreamProcessingDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+// applyMessages applies the events of a batch of input stream entries in one
+// call to the timeout manager, and acknowledges the entries that are done
+// with: applied, ignored as stale or duplicate, or dead-lettered as unusable.
+// Entries whose write failed stay pending, to be reclaimed.
+func (ic *IngestConsumer) applyMessages(ctx context.Context, entries []claimedEntry) {
+	events := make([]models.ConversationEvent, 0, len(entries))
+	parsed := make([]claimedEntry, 0, len(entries))
+	var done []string
+
+	now := ic.clock.Now()
+	for _, entry := range entries {
+		event, err := parseConversationEvent(entry.message)
+		if err != nil {
+			// Retrying can't fix a malformed entry
+			if ic.deadLetter(ctx, entry.message, "parse_error", err) {
+				done = append(done, entry.message.ID)
+			}
+			continue
+		}
+		if err := validation.Event(now, event); err != nil {
+			if ic.deadLetter(ctx, entry.message, "rejected", err) {
+				done = append(done, entry.message.ID)
+			}
+			continue
+		}
+		events = append(events, event)
+		parsed = append(parsed, entry)
+	}
+
+	for i, err := range ic.timeoutManager.ApplyEvents(ctx, events) {
+		message := parsed[i].message
+		switch {
+		case err == nil || phase1.IsIgnoredEvent(err):
+			ic.metrics.IngestEventsProcessed.WithLabelValues(phase1.EventResult(err)).Inc()
+			done = append(done, message.ID)
+		case errors.Is(err, phase1.ErrInvalidEvent):
+			if ic.deadLetter(ctx, message, "rejected", err) {
+				done = append(done, message.ID)
+			}
+		default:
+			ic.metrics.IngestEventsProcessed.WithLabelValues("failed").Inc()
+			ic.logger.WithError(err).WithFields(logrus.Fields{
+				"conversation_id": events[i].ConversationID,
+				"message_id":      message.ID,
+				"attempt":         parsed[i].attempt,
+			}).Error("Failed to apply ingested event")
+			// Don't acknowledge - let it retry
+		}
+	}
+
+	if len(done) == 0 {
+		return
+	}
+	if err := ic.rdb.XAck(ctx, ic.config.IngestStream, ic.config.IngestConsumerGroup, done...).Err(); err != nil {
+		// Left pending; reapplying them is a no-op
+		ic.logger.WithError(err).WithField("count", len(done)).Error("Failed to acknowledge ingested events")
+	}
+}
+
+// IngestDeadLetterStream returns the stream the unusable entries of an input
+// stream are moved to, e.g. "chat_events:dlq"
+func IngestDeadLetterStream(stream string) string {
+	return stream + ":dlq"
+}
+
+// deadLetter copies an entry that can never be applied to the input stream's
+// dead-letter stream, with the reason, and reports whether it may be
+// acknowledged. An entry that couldn't be copied stays pending, to be
+// dead-lettered when it is reclaimed.
+func (ic *IngestConsumer) deadLetter(ctx context.Context, message redis.XMessage, status string, err error) bool {
+	values := make(map[string]interface{}, len(message.Values)+3)
+	for field, value := range message.Values {
+		values[field] = value
+	}
+	values[deadLetterFieldPrefix+"original_id"] = message.ID
+	values[deadLetterFieldPrefix+"reason"] = err.Error()
+	values[deadLetterFieldPrefix+"failed_at"] = ic.clock.Now().UnixMilli()
+
+	if xerr := ic.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: IngestDeadLetterStream(ic.config.IngestStream),
+		Values: values,
+	}).Err(); xerr != nil {
+		ic.logger.WithError(xerr).WithField("message_id", message.ID).Error("Failed to dead-letter ingested event")
+		return false
+	}
+
+	ic.metrics.IngestEventsProcessed.WithLabelValues(status).Inc()
+	ic.logger.WithError(err).WithFields(logrus.Fields{
+		"message_id": message.ID,
+		"values":     message.Values,
+	}).Warn("Moved unusable ingested event to dead-letter stream")
+	return true
+}
+
+// parseConversationEvent reads an input stream entry: the fields of the
+// event, named as in its JSON form, with the timestamp in RFC 3339 or in ms
+// since the epoch. The fields are validated before the event is applied.
+func parseConversationEvent(message redis.XMessage) (models.ConversationEvent, error) {
+	field := func(name string) string {
+		value, _ := message.Values[name].(string)
+		return value
+	}
+
+	event := models.ConversationEvent{
+		Type:           field("type"),
+		ConversationID: field("conversation_id"),
+		AgentID:        field("agent_id"),
+		CustomerID:     field("customer_id"),
+		MessageID:      field("message_id"),
+		Policy:         field("policy"),
+		TenantID:       field("tenant_id"),
+		Channel:        field("channel"),
+		Priority:       field("priority"),
+	}
+
+	if timestamp := field("timestamp"); timestamp != "" {
+		if ms, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+			event.Timestamp = time.UnixMilli(ms)
+		} else if event.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp); err != nil {
+			return models.ConversationEvent{}, fmt.Errorf("invalid timestamp format: %w", err)
+		}
+	}
+
+	return event, nil
+}
+
+func (ic *IngestConsumer) pendingMessagesRecovery(ctx context.Context) {
+	ticker := ic.clock.NewTicker(30 * time.Second) // Check for pending messages every 30 seconds
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ic.stopCh:
+			return
+		case <-ticker.C():
+			ic.processPendingMessages(ctx)
+		}
+	}
+}
+
+// processPendingMessages reclaims the stalled entries of the input stream
+// and applies them again
+func (ic *IngestConsumer) processPendingMessages(ctx context.Context) {
+	exhausted := func(entry redis.XPendingExt) {
+		ic.metrics.IngestEventsProcessed.WithLabelValues("dropped").Inc()
+		ic.logger.WithFields(logrus.Fields{
+			"message_id": entry.ID,
+			"attempts":   entry.RetryCount,
+		}).Error("Dropped ingested event after exhausting its delivery attempts")
+		if err := ic.rdb.XAck(ctx, ic.config.IngestStream, ic.config.IngestConsumerGroup, entry.ID).Err(); err != nil {
+			ic.logger.WithError(err).WithField("message_id", entry.ID).Error("Failed to acknowledge ingested event")
+		}
+	}
+
+	if claimed := ic.reclaimer.reclaim(ctx, ic.config.IngestStream, ic.claimMinIdle, exhausted); len(claimed) > 0 {
+		ic.applyMessages(ctx, claimed)
+	}
+}
@@ -2,17 +2,24 @@ package phase2
 
 import (
 	"context"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/notifier"
 	"redis-timeout-tracking-poc/pkg/phase1"
 )
 
@@ -32,6 +39,12 @@ func setupTestRedisPhase2(t *testing.T) *redis.Client {
 	return rdb
 }
 
+func testEvaluator(t *testing.T, cfg *config.Config) *escalation.Evaluator {
+	evaluator, err := escalation.NewEvaluator(escalation.DefaultPolicySet([]int64{1, 2, 3}), cfg.TimeoutIntervalMS)
+	require.NoError(t, err)
+	return evaluator
+}
+
 func TestStreamProducer_CreateConsumerGroup(t *testing.T) {
 	rdb := setupTestRedisPhase2(t)
 	defer rdb.Close()
@@ -44,16 +57,17 @@ func TestStreamProducer_CreateConsumerGroup(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	producer := NewStreamProducer(rdb, cfg, logger, metrics)
+	producer := NewStreamProducer(rdb, cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	ctx := context.Background()
 	err := producer.createConsumerGroup(ctx)
 	assert.NoError(t, err)
 
 	// Verify consumer group exists
-	groups, err := rdb.XInfoGroups(ctx, TimeoutEventsStream).Result()
+	groups, err := rdb.XInfoGroups(ctx, EventsStream(0)).Result()
 	assert.NoError(t, err)
 	assert.Len(t, groups, 1)
 	assert.Equal(t, cfg.ConsumerGroupName, groups[0].Name)
@@ -67,32 +81,141 @@ func TestStreamProducer_PublishTimeoutEvent(t *testing.T) {
 		TimeoutIntervalMS: 5000,
 		PodID:             "test-producer",
 		ConsumerGroupName: "test-processors",
+		LeaderElectionTTL: 10,
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	producer := NewStreamProducer(rdb, cfg, logger, metrics)
+	producer := NewStreamProducer(rdb, cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	err := producer.createConsumerGroup(ctx)
 	require.NoError(t, err)
 
+	// Writes carry the fencing token of the shard leader
+	lease := producer.leases[0]
+	lease.Campaign(ctx)
+	require.True(t, lease.IsLeader())
+	keys := lease.Keys()
+
 	conversationID := "test_conv_123"
 	level := 1
 	startTime := time.Now().Add(-1 * time.Minute).UnixMilli()
 
-	err = producer.publishTimeoutEvent(ctx, conversationID, level, startTime)
+	timeoutManager := phase1.NewTimeoutManager(producer.store, cfg, logger, metrics, producer.evaluator, clk)
+	err = timeoutManager.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: conversationID,
+		AgentID:        "agent_1",
+		MessageID:      "msg_1",
+		Timestamp:      time.UnixMilli(startTime),
+	})
+	require.NoError(t, err)
+
+	policy := producer.evaluator.Policy("")
+	scanned, err := producer.store.State(ctx, conversationID)
+	require.NoError(t, err)
+	published, err := producer.publishTimeoutEvent(ctx, lease, conversationID, policy, scanned, level)
 	assert.NoError(t, err)
+	assert.True(t, published)
 
 	// Verify message was added to stream
-	messages, err := rdb.XRange(ctx, TimeoutEventsStream, "-", "+").Result()
+	messages, err := rdb.XRange(ctx, EventsStream(0), "-", "+").Result()
 	assert.NoError(t, err)
 	assert.Len(t, messages, 1)
 
 	message := messages[0]
 	assert.Equal(t, conversationID, message.Values["conversation_id"])
 	assert.Equal(t, "1", message.Values["level"])
+	assert.Equal(t, "level1", message.Values["level_name"])
+	assert.Equal(t, models.TimeoutEventID(conversationID, time.UnixMilli(startTime), level), message.Values["event_id"])
+	assert.Equal(t, strconv.FormatInt(lease.FencingToken(), 10), message.Values["fencing_token"])
+
+	// Verify the level advanced with it
+	state, err := rdb.HGet(ctx, keys.NotificationStates, conversationID).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", state)
+
+	// ...and the conversation moved to its level 2 deadline
+	deadline, err := rdb.ZScore(ctx, keys.Waiting, conversationID).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(startTime+2*cfg.TimeoutIntervalMS), deadline)
+
+	// Publishing from a stale scan writes nothing
+	published, err = producer.publishTimeoutEvent(ctx, lease, conversationID, policy, scanned, level)
+	assert.NoError(t, err)
+	assert.False(t, published)
+
+	length, err := rdb.XLen(ctx, EventsStream(0)).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+
+	// Once a newer leader has been elected the producer is fenced off
+	require.NoError(t, rdb.Incr(ctx, keys.LeaderEpoch).Err())
+	scanned, err = producer.store.State(ctx, conversationID)
+	require.NoError(t, err)
+	published, err = producer.publishTimeoutEvent(ctx, lease, conversationID, policy, scanned, level+1)
+	assert.ErrorIs(t, err, phase1.ErrStaleFencingToken)
+	assert.False(t, published)
+
+	state, err = rdb.HGet(ctx, keys.NotificationStates, conversationID).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, "1", state)
+
+	length, err = rdb.XLen(ctx, EventsStream(0)).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+}
+
+func TestStreamProducer_Holds(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 1000,
+		PodID:             "test-producer",
+		ConsumerGroupName: "test-processors",
+		LeaderElectionTTL: 10,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	producer := NewStreamProducer(rdb, cfg, logger, metrics, testEvaluator(t, cfg), clk)
+	timeoutManager := phase1.NewTimeoutManager(producer.store, cfg, logger, metrics, producer.evaluator, clk)
+
+	ctx := context.Background()
+	lease := producer.leases[0]
+	lease.Campaign(ctx)
+	require.True(t, lease.IsLeader())
+
+	require.NoError(t, timeoutManager.TrackAgentMessage(ctx, models.AgentMessage{
+		ConversationID: "conv_123",
+		AgentID:        "agent_1",
+		Timestamp:      clk.Now(),
+	}))
+
+	// Nothing is published while the conversation is paused
+	require.NoError(t, timeoutManager.PauseConversation(ctx, "conv_123", "agent_456"))
+	clk.Advance(10 * time.Second)
+	producer.detectAndPublishTimeouts(ctx, lease)
+	length, err := rdb.XLen(ctx, EventsStream(0)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, length)
+
+	// A forced escalation is published straight away
+	require.NoError(t, timeoutManager.ResumeConversation(ctx, "conv_123", "agent_456"))
+	require.NoError(t, timeoutManager.ForceEscalation(ctx, "conv_123", 2, "supervisor_1"))
+	producer.detectAndPublishTimeouts(ctx, lease)
+
+	messages, err := rdb.XRange(ctx, EventsStream(0), "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "conv_123", messages[0].Values["conversation_id"])
+	assert.Equal(t, "2", messages[0].Values["level"])
 }
 
 func TestStreamConsumer_ProcessMessage(t *testing.T) {
@@ -106,20 +229,21 @@ func TestStreamConsumer_ProcessMessage(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	consumer := NewStreamConsumer(rdb, cfg, logger, metrics)
+	consumer := NewStreamConsumer(rdb, cfg, logger, metrics, notifier.NewLogNotifier(logger), clk)
 
 	ctx := context.Background()
 
 	// Create consumer group first
-	err := rdb.XGroupCreateMkStream(ctx, TimeoutEventsStream, cfg.ConsumerGroupName, "$").Err()
+	err := rdb.XGroupCreateMkStream(ctx, EventsStream(0), cfg.ConsumerGroupName, "$").Err()
 	require.NoError(t, err)
 
 	// Add a test message to the stream
-	now := time.Now()
+	now := clk.Now()
 	streamArgs := &redis.XAddArgs{
-		Stream: TimeoutEventsStream,
+		Stream: EventsStream(0),
 		Values: map[string]interface{}{
 			"conversation_id":    "test_conv_123",
 			"level":              "2",
@@ -136,7 +260,7 @@ func TestStreamConsumer_ProcessMessage(t *testing.T) {
 	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
 		Group:    cfg.ConsumerGroupName,
 		Consumer: consumer.consumerName,
-		Streams:  []string{TimeoutEventsStream, ">"},
+		Streams:  []string{EventsStream(0), ">"},
 		Count:    1,
 		Block:    100 * time.Millisecond,
 	}).Result()
@@ -146,12 +270,158 @@ func TestStreamConsumer_ProcessMessage(t *testing.T) {
 	require.Len(t, streams[0].Messages, 1)
 
 	message := streams[0].Messages[0]
-	consumer.processMessage(ctx, message)
+	consumer.processMessage(ctx, 0, message, 1)
 
 	// Verify message was acknowledged
-	pending, err := rdb.XPending(ctx, TimeoutEventsStream, cfg.ConsumerGroupName).Result()
+	pending, err := rdb.XPending(ctx, EventsStream(0), cfg.ConsumerGroupName).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+
+	// ...and the delivery recorded in the conversation's history
+	history, err := consumer.store.History(ctx, "test_conv_123")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, models.HistoryNotificationDelivered, history[0].Type)
+	assert.Equal(t, 2, history[0].Level)
+	assert.Equal(t, consumer.consumerName, history[0].ActorID)
+}
+
+func TestStreamConsumer_ProcessMessage_SkipsDuplicate(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS: 5000,
+		PodID:             "test-consumer",
+		ConsumerGroupName: "test-processors",
+		IdempotencyTTL:    60,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	consumer := NewStreamConsumer(rdb, cfg, logger, metrics, notifier.NewLogNotifier(logger), clk)
+
+	ctx := context.Background()
+	err := rdb.XGroupCreateMkStream(ctx, EventsStream(0), cfg.ConsumerGroupName, "$").Err()
+	require.NoError(t, err)
+
+	// The same escalation delivered twice, as after a failed ack
+	now := clk.Now()
+	agentTime := now.Add(-2 * time.Minute)
+	eventID := models.TimeoutEventID("test_conv_123", agentTime, 2)
+	for i := 0; i < 2; i++ {
+		_, err = rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: EventsStream(0),
+			Values: map[string]interface{}{
+				"event_id":           eventID,
+				"conversation_id":    "test_conv_123",
+				"level":              "2",
+				"agent_message_time": agentTime.UnixMilli(),
+				"detected_at":        now.UnixMilli(),
+				"attempt":            "1",
+			},
+		}).Result()
+		require.NoError(t, err)
+	}
+
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    cfg.ConsumerGroupName,
+		Consumer: consumer.consumerName,
+		Streams:  []string{EventsStream(0), ">"},
+		Count:    2,
+		Block:    100 * time.Millisecond,
+	}).Result()
+	require.NoError(t, err)
+	require.Len(t, streams[0].Messages, 2)
+
+	for _, message := range streams[0].Messages {
+		consumer.processMessage(ctx, 0, message, 1)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.StreamMessagesProcessed.WithLabelValues("success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.StreamMessagesProcessed.WithLabelValues("duplicate_skipped")))
+
+	// Both deliveries are acknowledged
+	pending, err := rdb.XPending(ctx, EventsStream(0), cfg.ConsumerGroupName).Result()
 	assert.NoError(t, err)
 	assert.Equal(t, int64(0), pending.Count)
+
+	state, err := rdb.Get(ctx, ProcessedEventsKeyPrefix+eventID).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, string(EventStateDone), state)
+}
+
+func TestStreamConsumer_ProcessMessage_ReservedElsewhere(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:   5000,
+		PodID:               "test-consumer",
+		ConsumerGroupName:   "test-processors",
+		MaxDeliveryAttempts: 2,
+		WebhookTimeoutMS:    5000,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	// A consumer still sending when its entry is reclaimed keeps its claim
+	assert.Greater(t, eventProcessingTTL(cfg.WebhookTimeout()), pendingClaimMinIdle+cfg.WebhookTimeout())
+
+	sending := &failingNotifier{}
+	consumer := NewStreamConsumer(rdb, cfg, logger, metrics, sending, clk)
+
+	ctx := context.Background()
+	err := rdb.XGroupCreateMkStream(ctx, EventsStream(0), cfg.ConsumerGroupName, "$").Err()
+	require.NoError(t, err)
+
+	now := clk.Now()
+	agentTime := now.Add(-time.Minute)
+	eventID := models.TimeoutEventID("reserved_conv", agentTime, 1)
+	_, err = rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: EventsStream(0),
+		Values: map[string]interface{}{
+			"event_id":           eventID,
+			"conversation_id":    "reserved_conv",
+			"level":              "1",
+			"agent_message_time": agentTime.UnixMilli(),
+			"detected_at":        now.UnixMilli(),
+		},
+	}).Result()
+	require.NoError(t, err)
+	require.NoError(t, rdb.Set(ctx, ProcessedEventsKeyPrefix+eventID, string(EventStateProcessing), time.Minute).Err())
+
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    cfg.ConsumerGroupName,
+		Consumer: consumer.consumerName,
+		Streams:  []string{EventsStream(0), ">"},
+		Count:    1,
+		Block:    100 * time.Millisecond,
+	}).Result()
+	require.NoError(t, err)
+	message := streams[0].Messages[0]
+
+	// Left pending while the other consumer may still deliver it
+	consumer.processMessage(ctx, 0, message, 1)
+	pending, err := rdb.XPending(ctx, EventsStream(0), cfg.ConsumerGroupName).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pending.Count)
+
+	// But not beyond the last delivery
+	consumer.processMessage(ctx, 0, message, 2)
+	pending, err = rdb.XPending(ctx, EventsStream(0), cfg.ConsumerGroupName).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+	assert.Zero(t, sending.calls)
+
+	entries, err := NewDeadLetterQueue(rdb, cfg.ConsumerGroupName, logger, clk).List(ctx, "-", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Reason, "reserved by another consumer")
 }
 
 func TestIntegration_Phase2_EndToEnd(t *testing.T) {
@@ -167,14 +437,15 @@ func TestIntegration_Phase2_EndToEnd(t *testing.T) {
 	}
 	logger := logrus.New()
 	logger.SetLevel(logrus.ErrorLevel)
-	metrics := metrics.NewMetrics()
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
 	// Create timeout manager
-	timeoutManager := phase1.NewTimeoutManager(rdb, cfg, logger, metrics)
+	timeoutManager := phase1.NewTimeoutManager(phase1.NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
 
 	// Create stream producer and consumer
-	producer := NewStreamProducer(rdb, cfg, logger, metrics)
-	consumer := NewStreamConsumer(rdb, cfg, logger, metrics)
+	producer := NewStreamProducer(rdb, cfg, logger, metrics, testEvaluator(t, cfg), clk)
+	consumer := NewStreamConsumer(rdb, cfg, logger, metrics, notifier.NewLogNotifier(logger), clk)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -188,27 +459,381 @@ func TestIntegration_Phase2_EndToEnd(t *testing.T) {
 	require.NoError(t, err)
 	defer consumer.Stop()
 
+	// The first election round runs on start. Wait for it, and for the
+	// election, pending recovery and detection loops before moving time.
+	require.Eventually(t, producer.IsLeader, time.Second, 10*time.Millisecond)
+	clk.BlockUntil(3)
+
 	// Track an agent message
 	agentMsg := models.AgentMessage{
 		ConversationID: "integration_test_conv",
 		AgentID:        "agent_123",
 		MessageID:      "msg_456",
-		Timestamp:      time.Now().Add(-2 * time.Second), // 2 seconds ago
+		Timestamp:      clk.Now(),
 	}
 
 	err = timeoutManager.TrackAgentMessage(ctx, agentMsg)
 	require.NoError(t, err)
 
-	// Wait for timeout detection and stream processing
-	time.Sleep(2 * time.Second)
+	// Each base interval that passes escalates one level, published to the
+	// stream and consumed
+	for level := 1; level <= 3; level++ {
+		clk.Advance(time.Duration(cfg.TimeoutIntervalMS) * time.Millisecond)
 
-	// Check that stream has messages
-	length, err := rdb.XLen(ctx, TimeoutEventsStream).Result()
+		require.Eventually(t, func() bool {
+			current, err := timeoutManager.GetNotificationState(ctx, agentMsg.ConversationID)
+			return err == nil && current == level
+		}, time.Second, 10*time.Millisecond, "level %d", level)
+
+		require.Eventually(t, func() bool {
+			return testutil.ToFloat64(metrics.StreamMessagesProcessed.WithLabelValues("success")) == float64(level)
+		}, time.Second, 10*time.Millisecond, "level %d consumed", level)
+	}
+
+	length, err := rdb.XLen(ctx, EventsStream(0)).Result()
 	assert.NoError(t, err)
-	assert.Greater(t, length, int64(0))
+	assert.Equal(t, int64(3), length)
+}
+
+type failingNotifier struct {
+	err   error
+	calls int
+}
+
+func (n *failingNotifier) Notify(ctx context.Context, event models.TimeoutEvent) error {
+	n.calls++
+	return n.err
+}
+
+func TestStreamConsumer_DeadLettersAfterMaxAttempts(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:   5000,
+		PodID:               "test-consumer",
+		ConsumerGroupName:   "test-processors",
+		MaxDeliveryAttempts: 3,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
 
-	// Check notification state was updated
-	level, err := timeoutManager.GetNotificationState(ctx, agentMsg.ConversationID)
+	failing := &failingNotifier{err: errors.New("connection refused")}
+	consumer := NewStreamConsumer(rdb, cfg, logger, metrics, failing, clk)
+	consumer.claimMinIdle = 0
+
+	ctx := context.Background()
+	err := rdb.XGroupCreateMkStream(ctx, EventsStream(0), cfg.ConsumerGroupName, "$").Err()
+	require.NoError(t, err)
+
+	now := clk.Now()
+	originalID, err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: EventsStream(0),
+		Values: map[string]interface{}{
+			"conversation_id":    "poison_conv",
+			"level":              "1",
+			"agent_message_time": now.Add(-time.Minute).UnixMilli(),
+			"detected_at":        now.UnixMilli(),
+			"attempt":            "1",
+		},
+	}).Result()
+	require.NoError(t, err)
+
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    cfg.ConsumerGroupName,
+		Consumer: consumer.consumerName,
+		Streams:  []string{EventsStream(0), ">"},
+		Count:    1,
+		Block:    100 * time.Millisecond,
+	}).Result()
+	require.NoError(t, err)
+	consumer.processMessage(ctx, 0, streams[0].Messages[0], 1)
+
+	// Two reclaimed retries, then the third pass dead-letters it
+	for i := 0; i < 3; i++ {
+		consumer.processPendingMessages(ctx)
+	}
+	assert.Equal(t, 3, failing.calls)
+
+	pending, err := rdb.XPending(ctx, EventsStream(0), cfg.ConsumerGroupName).Result()
 	assert.NoError(t, err)
-	assert.Equal(t, 1, level) // Should have sent level 1 notification
+	assert.Equal(t, int64(0), pending.Count)
+
+	dlq := NewDeadLetterQueue(rdb, cfg.ConsumerGroupName, logger, clk)
+	entries, err := dlq.List(ctx, "-", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, originalID, entries[0].OriginalID)
+	assert.Equal(t, 3, entries[0].Attempts)
+	assert.Contains(t, entries[0].Reason, "connection refused")
+	assert.Equal(t, "poison_conv", entries[0].Values["conversation_id"])
+
+	// Replaying puts it back on the stream with a fresh attempt count
+	messageID, err := dlq.Replay(ctx, entries[0].ID)
+	require.NoError(t, err)
+
+	replayed, err := rdb.XRange(ctx, EventsStream(0), messageID, messageID).Result()
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+	assert.Equal(t, "poison_conv", replayed[0].Values["conversation_id"])
+	assert.Equal(t, "1", replayed[0].Values["attempt"])
+	assert.NotContains(t, replayed[0].Values, "dlq_reason")
+
+	_, err = dlq.Get(ctx, entries[0].ID)
+	assert.Equal(t, ErrDeadLetterNotFound, err)
+}
+
+func TestStreamConsumer_DeadLettersPermanentFailure(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:   5000,
+		PodID:               "test-consumer",
+		ConsumerGroupName:   "test-processors",
+		MaxDeliveryAttempts: 3,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Now())
+
+	rejecting := &failingNotifier{err: notifier.Permanent(errors.New("webhook returned status 400"))}
+	consumer := NewStreamConsumer(rdb, cfg, logger, metrics, rejecting, clk)
+
+	ctx := context.Background()
+	err := rdb.XGroupCreateMkStream(ctx, EventsStream(0), cfg.ConsumerGroupName, "$").Err()
+	require.NoError(t, err)
+
+	now := clk.Now()
+	_, err = rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: EventsStream(0),
+		Values: map[string]interface{}{
+			"conversation_id":    "rejected_conv",
+			"level":              "1",
+			"agent_message_time": now.Add(-time.Minute).UnixMilli(),
+			"detected_at":        now.UnixMilli(),
+		},
+	}).Result()
+	require.NoError(t, err)
+
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    cfg.ConsumerGroupName,
+		Consumer: consumer.consumerName,
+		Streams:  []string{EventsStream(0), ">"},
+		Count:    1,
+		Block:    100 * time.Millisecond,
+	}).Result()
+	require.NoError(t, err)
+	consumer.processMessage(ctx, 0, streams[0].Messages[0], 1)
+
+	length, err := rdb.XLen(ctx, DeadLetterStream).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), length)
+
+	pending, err := rdb.XPending(ctx, EventsStream(0), cfg.ConsumerGroupName).Result()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+}
+
+func TestDeadLetterQueue_ReplaysToShardStream(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	clk := clock.NewManual(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	group := "test-processors"
+	stream := EventsStream(2)
+
+	require.NoError(t, rdb.XGroupCreateMkStream(ctx, stream, group, "$").Err())
+	require.NoError(t, rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		Values: map[string]interface{}{"conversation_id": "sharded_conv", "level": 1, "attempt": 2},
+	}).Err())
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: "test-consumer",
+		Streams:  []string{stream, ">"},
+		Count:    1,
+	}).Result()
+	require.NoError(t, err)
+	require.Len(t, streams[0].Messages, 1)
+
+	dlq := NewDeadLetterQueue(rdb, group, logger, clk)
+	require.NoError(t, dlq.Add(ctx, 2, streams[0].Messages[0], "boom", 2))
+
+	// Acknowledged on the shard's stream
+	pending, err := rdb.XPending(ctx, stream, group).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+
+	entries, err := dlq.List(ctx, "-", 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 2, entries[0].Shard)
+	assert.True(t, clk.Now().Equal(entries[0].DeadLetteredAt))
+
+	// Replayed onto the stream it came from
+	messageID, err := dlq.Replay(ctx, entries[0].ID)
+	require.NoError(t, err)
+	replayed, err := rdb.XRange(ctx, stream, messageID, messageID).Result()
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+	assert.Equal(t, "sharded_conv", replayed[0].Values["conversation_id"])
+	assert.Equal(t, "1", replayed[0].Values["attempt"])
+
+	length, err := rdb.XLen(ctx, EventsStream(0)).Result()
+	require.NoError(t, err)
+	assert.Zero(t, length)
+}
+
+func TestIngestConsumer_AppliesEvents(t *testing.T) {
+	rdb := setupTestRedisPhase2(t)
+	defer rdb.Close()
+
+	cfg := &config.Config{
+		TimeoutIntervalMS:   5000,
+		PodID:               "test-ingester",
+		IngestStream:        "test_ingest",
+		IngestConsumerGroup: "test-ingesters",
+		MaxDeliveryAttempts: 3,
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.FatalLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	now := time.Now().Truncate(time.Millisecond)
+	clk := clock.NewManual(now)
+
+	timeoutManager := phase1.NewTimeoutManager(phase1.NewRedisStore(rdb, cfg), cfg, logger, metrics, testEvaluator(t, cfg), clk)
+	consumer := NewIngestConsumer(rdb, cfg, logger, metrics, timeoutManager, clk)
+	consumer.claimMinIdle = 0
+
+	ctx := context.Background()
+	require.NoError(t, rdb.XGroupCreateMkStream(ctx, cfg.IngestStream, cfg.IngestConsumerGroup, "$").Err())
+
+	publish := func(values map[string]interface{}) {
+		require.NoError(t, rdb.XAdd(ctx, &redis.XAddArgs{Stream: cfg.IngestStream, Values: values}).Err())
+	}
+	publish(map[string]interface{}{
+		"type":            models.EventAgentMessage,
+		"conversation_id": "conv_1",
+		"agent_id":        "agent_1",
+		"message_id":      "msg_1",
+		"timestamp":       now.UnixMilli(),
+	})
+	publish(map[string]interface{}{
+		"type":            models.EventAgentMessage,
+		"conversation_id": "conv_2",
+		"agent_id":        "agent_1",
+		"message_id":      "msg_2",
+		"timestamp":       now.Format(time.RFC3339Nano),
+	})
+	publish(map[string]interface{}{
+		"type":            models.EventCustomerResponse,
+		"conversation_id": "conv_2",
+		"customer_id":     "customer_1",
+		"message_id":      "msg_3",
+		"timestamp":       now.Add(time.Second).Format(time.RFC3339Nano),
+	})
+	publish(map[string]interface{}{
+		"type":            models.EventAgentMessage,
+		"conversation_id": "conv_1",
+		"agent_id":        "agent_1",
+		"message_id":      "msg_1",
+		"timestamp":       now.UnixMilli(),
+	})
+	publish(map[string]interface{}{"type": models.EventAgentMessage, "conversation_id": "conv_3", "timestamp": "yesterday"})
+	publish(map[string]interface{}{"type": "typing", "conversation_id": "conv_3", "message_id": "msg_5"})
+	// Validated as the HTTP API validates events
+	publish(map[string]interface{}{
+		"type":            models.EventAgentMessage,
+		"conversation_id": "conv {5}",
+		"agent_id":        "agent_1",
+		"message_id":      "msg_6",
+	})
+	publish(map[string]interface{}{
+		"type":            models.EventAgentMessage,
+		"conversation_id": "conv_6",
+		"agent_id":        "agent_1",
+		"message_id":      "msg_7",
+		"timestamp":       now.Add(-48 * time.Hour).UnixMilli(),
+	})
+
+	consumer.consumeMessages(ctx)
+
+	status, err := timeoutManager.GetConversation(ctx, "conv_1")
+	require.NoError(t, err)
+	assert.True(t, status.Tracked)
+	assert.Equal(t, now.UnixMilli(), status.AgentMessageTime.UnixMilli())
+	status, err = timeoutManager.GetConversation(ctx, "conv_2")
+	require.NoError(t, err)
+	assert.False(t, status.Tracked)
+
+	// Every entry is done with, unusable ones included
+	pending, err := rdb.XPending(ctx, cfg.IngestStream, cfg.IngestConsumerGroup).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.IngestEventsProcessed.WithLabelValues("applied")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.IngestEventsProcessed.WithLabelValues("duplicate")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.IngestEventsProcessed.WithLabelValues("parse_error")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(metrics.IngestEventsProcessed.WithLabelValues("rejected")))
+
+	// Unusable entries are moved to the dead-letter stream with the reason
+	deadLetters, err := rdb.XRange(ctx, IngestDeadLetterStream(cfg.IngestStream), "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, deadLetters, 4)
+	assert.Equal(t, "conv_3", deadLetters[0].Values["conversation_id"])
+	assert.Contains(t, deadLetters[0].Values["dlq_reason"], "timestamp")
+	assert.Equal(t, "conv {5}", deadLetters[1].Values["conversation_id"])
+	assert.Contains(t, deadLetters[1].Values["dlq_reason"], "conversation_id")
+	assert.Equal(t, "conv_6", deadLetters[2].Values["conversation_id"])
+	assert.Contains(t, deadLetters[2].Values["dlq_reason"], "timestamp")
+	assert.Contains(t, deadLetters[3].Values["dlq_reason"], "unknown event type")
+	for _, entry := range deadLetters {
+		assert.NotEmpty(t, entry.Values["dlq_original_id"])
+		assert.Equal(t, strconv.FormatInt(now.UnixMilli(), 10), entry.Values["dlq_failed_at"])
+	}
+
+	// An entry left pending by a pod that died before applying it is
+	// reclaimed and applied
+	publish(map[string]interface{}{
+		"type":            models.EventAgentMessage,
+		"conversation_id": "conv_4",
+		"agent_id":        "agent_1",
+		"message_id":      "msg_4",
+		"timestamp":       now.UnixMilli(),
+	})
+	streams, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    cfg.IngestConsumerGroup,
+		Consumer: "ingester-dead-pod",
+		Streams:  []string{cfg.IngestStream, ">"},
+		Count:    1,
+		Block:    100 * time.Millisecond,
+	}).Result()
+	require.NoError(t, err)
+	require.Len(t, streams[0].Messages, 1)
+
+	consumer.processPendingMessages(ctx)
+
+	status, err = timeoutManager.GetConversation(ctx, "conv_4")
+	require.NoError(t, err)
+	assert.True(t, status.Tracked)
+	pending, err = rdb.XPending(ctx, cfg.IngestStream, cfg.IngestConsumerGroup).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+}
+
+func TestValidEntryID(t *testing.T) {
+	assert.True(t, ValidEntryID("1700000000000-0", false))
+	assert.False(t, ValidEntryID("1700000000000", false))
+	assert.True(t, ValidEntryID("1700000000000", true))
+	assert.False(t, ValidEntryID("", true))
+	assert.False(t, ValidEntryID("abc-1", false))
+	assert.False(t, ValidEntryID("1-2-3", false))
+	assert.False(t, ValidEntryID("99999999999999999999-0", false))
 }
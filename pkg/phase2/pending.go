@@ -0,0 +1,100 @@
+package phase2
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// reclaimBatchSize is how many stalled entries a consumer reclaims per stream
+// at a time
+const reclaimBatchSize = 10
+
+// pendingReclaimer hands a consumer of a group the entries that stalled on
+// other consumers: delivered but left unacknowledged, e.g. because their
+// consumer died or failed to process them
+type pendingReclaimer struct {
+	rdb         redis.UniversalClient
+	group       string
+	consumer    string
+	maxAttempts int
+	logger      *logrus.Logger
+}
+
+// claimedEntry is an entry reclaimed by pendingReclaimer. attempt is its
+// delivery count as tracked by the consumer group, the claim included.
+type claimedEntry struct {
+	message redis.XMessage
+	attempt int
+}
+
+// reclaim claims the entries of stream pending for longer than minIdle. The
+// ones already delivered maxAttempts times are passed to exhausted instead,
+// unclaimed.
+func (r *pendingReclaimer) reclaim(ctx context.Context, stream string, minIdle time.Duration, exhausted func(redis.XPendingExt)) []claimedEntry {
+	pending, err := r.rdb.XPending(ctx, stream, r.group).Result()
+	if err != nil {
+		r.logger.WithError(err).WithField("stream", stream).Error("Failed to get pending messages")
+		return nil
+	}
+
+	if pending.Count == 0 {
+		return nil
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"stream":        stream,
+		"pending_count": pending.Count,
+	}).Info("Processing pending messages")
+
+	entries, err := r.rdb.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: stream,
+		Group:  r.group,
+		Idle:   minIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  reclaimBatchSize,
+	}).Result()
+
+	if err != nil {
+		r.logger.WithError(err).WithField("stream", stream).Error("Failed to get pending message details")
+		return nil
+	}
+
+	var claimIDs []string
+	deliveries := make(map[string]int64, len(entries))
+	for _, entry := range entries {
+		if entry.RetryCount >= int64(r.maxAttempts) {
+			exhausted(entry)
+			continue
+		}
+		claimIDs = append(claimIDs, entry.ID)
+		deliveries[entry.ID] = entry.RetryCount
+	}
+
+	if len(claimIDs) == 0 {
+		return nil
+	}
+
+	messages, err := r.rdb.XClaim(ctx, &redis.XClaimArgs{
+		Stream:   stream,
+		Group:    r.group,
+		Consumer: r.consumer,
+		MinIdle:  minIdle,
+		Messages: claimIDs,
+	}).Result()
+
+	if err != nil {
+		r.logger.WithError(err).WithField("stream", stream).Error("Failed to claim pending messages")
+		return nil
+	}
+
+	claimed := make([]claimedEntry, 0, len(messages))
+	for _, message := range messages {
+		// Claiming counts as another delivery
+		claimed = append(claimed, claimedEntry{message: message, attempt: int(deliveries[message.ID]) + 1})
+	}
+	return claimed
+}
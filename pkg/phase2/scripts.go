@@ -0,0 +1,66 @@
+package phase2
+
+import "github.com/go-redis/redis/v8"
+
+// publishTimeoutEventScript is the outbox for timeout events: it advances the
+// notification level, reschedules the conversation to its next deadline and
+// appends the event to the stream in one step, so the writes either all
+// happen or none does.
+//
+// KEYS[1] - waiting conversations sorted set (scored by next deadline)
+// KEYS[2] - notification states hash
+// KEYS[3] - the shard's timeout events stream
+// KEYS[4] - waiting since sorted set (scored by agent message time)
+// KEYS[5] - leader epoch counter
+// KEYS[6] - paused conversations hash
+// ARGV[1] - conversation ID
+// ARGV[2] - agent message time (ms) the caller read
+// ARGV[3] - level the caller read (0 for none)
+// ARGV[4] - new level
+// ARGV[5] - next escalation deadline (ms, or +inf after the last level)
+// ARGV[6] - caller's fencing token
+// ARGV[7..] - stream entry field/value pairs
+//
+// Returns the stream entry ID, or false when the conversation was cleared,
+// re-tracked, paused, or its level changed since it was read. Fails with a
+// FENCED error when the token is stale.
+var publishTimeoutEventScript = redis.NewScript(`
+	if redis.call("GET", KEYS[5]) ~= ARGV[6] then
+		return redis.error_reply("FENCED stale fencing token")
+	end
+
+	if redis.call("HEXISTS", KEYS[6], ARGV[1]) == 1 then
+		return false
+	end
+
+	local since = redis.call("ZSCORE", KEYS[4], ARGV[1])
+	if not since or tonumber(since) ~= tonumber(ARGV[2]) then
+		return false
+	end
+
+	local current = redis.call("HGET", KEYS[2], ARGV[1]) or "0"
+	if tonumber(current) ~= tonumber(ARGV[3]) then
+		return false
+	end
+
+	redis.call("HSET", KEYS[2], ARGV[1], ARGV[4])
+	redis.call("ZADD", KEYS[1], "XX", ARGV[5], ARGV[1])
+	return redis.call("XADD", KEYS[3], "*", unpack(ARGV, 7))
+`)
+
+// reserveEventScript claims an event in the idempotency ledger.
+//
+// KEYS[1] - ledger key for the event
+// ARGV[1] - how long (ms) the processing claim is held
+//
+// Returns "new" when the caller now holds the claim, otherwise the state
+// already recorded for the event.
+var reserveEventScript = redis.NewScript(`
+	local state = redis.call("GET", KEYS[1])
+	if state then
+		return state
+	end
+
+	redis.call("SET", KEYS[1], "processing", "PX", ARGV[1])
+	return "new"
+`)
@@ -3,16 +3,15 @@ package phase2
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"time"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/notifier"
 	"redis-timeout-tracking-poc/pkg/phase1"
 )
 
@@ -20,31 +19,49 @@ type Service struct {
 	config         *config.Config
 	logger         *logrus.Logger
 	metrics        *metrics.Metrics
+	evaluator      *escalation.Evaluator
+	clock          clock.Clock
 	timeoutManager *phase1.TimeoutManager
 	streamProducer *StreamProducer
 	streamConsumer *StreamConsumer
-	server         *http.Server
+	ingestConsumer *IngestConsumer
+	deadLetters    *DeadLetterQueue
 }
 
-func NewService(rdb *redis.Client, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics) *Service {
-	timeoutManager := phase1.NewTimeoutManager(rdb, config, logger, metrics)
-	streamProducer := NewStreamProducer(rdb, config, logger, metrics)
-	streamConsumer := NewStreamConsumer(rdb, config, logger, metrics)
+func NewService(rdb redis.UniversalClient, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics, notifier notifier.Notifier, evaluator *escalation.Evaluator, clock clock.Clock) *Service {
+	timeoutManager := phase1.NewTimeoutManager(phase1.NewRedisStore(rdb, config), config, logger, metrics, evaluator, clock)
+	streamProducer := NewStreamProducer(rdb, config, logger, metrics, evaluator, clock)
+	streamConsumer := NewStreamConsumer(rdb, config, logger, metrics, notifier, clock)
+
+	// Events are also read from the input stream when one is configured
+	var ingestConsumer *IngestConsumer
+	if config.IngestStream != "" {
+		ingestConsumer = NewIngestConsumer(rdb, config, logger, metrics, timeoutManager, clock)
+	}
 
 	return &Service{
 		config:         config,
 		logger:         logger,
 		metrics:        metrics,
+		evaluator:      evaluator,
+		clock:          clock,
 		timeoutManager: timeoutManager,
 		streamProducer: streamProducer,
 		streamConsumer: streamConsumer,
+		ingestConsumer: ingestConsumer,
+		deadLetters:    NewDeadLetterQueue(rdb, config.ConsumerGroupName, logger, clock),
 	}
 }
 
 func (s *Service) Start(ctx context.Context) error {
 	s.logger.Info("Starting Phase 2 timeout tracking service")
 
-	// Start stream producer (handles leader election internally)
+	// Conversations tracked before sharding move to their shards
+	if _, err := s.timeoutManager.MigrateUnsharded(ctx); err != nil {
+		return fmt.Errorf("failed to migrate unsharded conversations: %w", err)
+	}
+
+	// Start stream producer (handles shard leader election internally)
 	if err := s.streamProducer.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start stream producer: %w", err)
 	}
@@ -54,9 +71,11 @@ func (s *Service) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to start stream consumer: %w", err)
 	}
 
-	// Start HTTP server
-	if err := s.startHTTPServer(ctx); err != nil {
-		return fmt.Errorf("failed to start HTTP server: %w", err)
+	// Start ingesting events (all pods ingest)
+	if s.ingestConsumer != nil {
+		if err := s.ingestConsumer.Start(ctx); err != nil {
+			return fmt.Errorf("failed to start ingest consumer: %w", err)
+		}
 	}
 
 	s.logger.WithField("pod_id", s.config.PodID).Info("Phase 2 service started successfully")
@@ -69,60 +88,24 @@ func (s *Service) Stop(ctx context.Context) error {
 	// Stop stream components
 	s.streamProducer.Stop()
 	s.streamConsumer.Stop()
-
-	// Stop HTTP server
-	if s.server != nil {
-		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
-
-		if err := s.server.Shutdown(shutdownCtx); err != nil {
-			s.logger.WithError(err).Error("Failed to shutdown HTTP server gracefully")
-			return err
-		}
+	if s.ingestConsumer != nil {
+		s.ingestConsumer.Stop()
 	}
 
 	s.logger.Info("Phase 2 service stopped")
 	return nil
 }
 
+// IsLeader reports whether this pod leads any detection shard
 func (s *Service) IsLeader() bool {
 	return s.streamProducer.IsLeader()
 }
 
-func (s *Service) GetTimeoutManager() *phase1.TimeoutManager {
-	return s.timeoutManager
-}
-
-func (s *Service) startHTTPServer(ctx context.Context) error {
-	s.server = s.createHTTPServer()
-
-	go func() {
-		s.logger.WithField("port", s.config.Port).Info("Starting HTTP server")
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.WithError(err).Fatal("HTTP server failed")
-		}
-	}()
-
-	return nil
+// OwnedShards returns the detection shards this pod leads
+func (s *Service) OwnedShards() []int {
+	return s.streamProducer.OwnedShards()
 }
 
-func (s *Service) createHTTPServer() *http.Server {
-	router := mux.NewRouter()
-
-	// API routes
-	router.HandleFunc("/conversations/{id}/agent-message", s.handleAgentMessage).Methods("POST")
-	router.HandleFunc("/conversations/{id}/customer-response", s.handleCustomerResponse).Methods("POST")
-	router.HandleFunc("/health", s.handleHealth).Methods("GET")
-	router.HandleFunc("/status", s.handleStatus).Methods("GET")
-
-	// Metrics endpoint
-	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
-
-	return &http.Server{
-		Addr:         ":" + s.config.Port,
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
+func (s *Service) GetTimeoutManager() *phase1.TimeoutManager {
+	return s.timeoutManager
 }
@@ -9,29 +9,52 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
 	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/notifier"
+	"redis-timeout-tracking-poc/pkg/phase1"
 )
 
 type StreamConsumer struct {
-	rdb          *redis.Client
+	rdb          redis.UniversalClient
 	config       *config.Config
 	logger       *logrus.Logger
 	metrics      *metrics.Metrics
+	notifier     notifier.Notifier
+	store        *phase1.RedisStore
+	ledger       *IdempotencyLedger
+	deadLetters  *DeadLetterQueue
+	reclaimer    *pendingReclaimer
 	consumerName string
+	claimMinIdle time.Duration
+	clock        clock.Clock
 	stopCh       chan struct{}
 }
 
-func NewStreamConsumer(rdb *redis.Client, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics) *StreamConsumer {
+func NewStreamConsumer(rdb redis.UniversalClient, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics, notifier notifier.Notifier, clock clock.Clock) *StreamConsumer {
 	consumerName := fmt.Sprintf("consumer-%s", config.PodID)
 
 	return &StreamConsumer{
-		rdb:          rdb,
-		config:       config,
-		logger:       logger,
-		metrics:      metrics,
+		rdb:         rdb,
+		config:      config,
+		logger:      logger,
+		metrics:     metrics,
+		notifier:    notifier,
+		store:       phase1.NewRedisStore(rdb, config),
+		ledger:      NewIdempotencyLedger(rdb, config.IdempotencyTTLDuration(), eventProcessingTTL(config.WebhookTimeout())),
+		deadLetters: NewDeadLetterQueue(rdb, config.ConsumerGroupName, logger, clock),
+		reclaimer: &pendingReclaimer{
+			rdb:         rdb,
+			group:       config.ConsumerGroupName,
+			consumer:    consumerName,
+			maxAttempts: config.DeliveryAttempts(),
+			logger:      logger,
+		},
 		consumerName: consumerName,
+		claimMinIdle: pendingClaimMinIdle,
+		clock:        clock,
 		stopCh:       make(chan struct{}),
 	}
 }
@@ -39,8 +62,11 @@ func NewStreamConsumer(rdb *redis.Client, config *config.Config, logger *logrus.
 func (sc *StreamConsumer) Start(ctx context.Context) error {
 	sc.logger.WithField("consumer_name", sc.consumerName).Info("Starting stream consumer")
 
-	// Start consuming messages
-	go sc.consumeLoop(ctx)
+	// Start consuming messages, one reader per shard stream: on a cluster
+	// the streams live in different slots and can't be read together
+	for s := 0; s < sc.config.Shards(); s++ {
+		go sc.consumeLoop(ctx, s)
+	}
 
 	// Start pending messages recovery
 	go sc.pendingMessagesRecovery(ctx)
@@ -53,7 +79,7 @@ func (sc *StreamConsumer) Stop() {
 	close(sc.stopCh)
 }
 
-func (sc *StreamConsumer) consumeLoop(ctx context.Context) {
+func (sc *StreamConsumer) consumeLoop(ctx context.Context, shard int) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -61,19 +87,19 @@ func (sc *StreamConsumer) consumeLoop(ctx context.Context) {
 		case <-sc.stopCh:
 			return
 		default:
-			sc.consumeMessages(ctx)
+			sc.consumeMessages(ctx, shard)
 		}
 	}
 }
 
-func (sc *StreamConsumer) consumeMessages(ctx context.Context) {
+func (sc *StreamConsumer) consumeMessages(ctx context.Context, shard int) {
 	start := time.Now()
 
 	// Read messages from stream
 	streams, err := sc.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
 		Group:    sc.config.ConsumerGroupName,
 		Consumer: sc.consumerName,
-		Streams:  []string{TimeoutEventsStream, ">"},
+		Streams:  []string{EventsStream(shard), ">"},
 		Count:    10,
 		Block:    1 * time.Second,
 	}).Result()
@@ -87,7 +113,8 @@ func (sc *StreamConsumer) consumeMessages(ctx context.Context) {
 
 	for _, stream := range streams {
 		for _, message := range stream.Messages {
-			sc.processMessage(ctx, message)
+			// First delivery
+			sc.processMessage(ctx, shard, message, 1)
 		}
 	}
 
@@ -96,7 +123,9 @@ func (sc *StreamConsumer) consumeMessages(ctx context.Context) {
 	}
 }
 
-func (sc *StreamConsumer) processMessage(ctx context.Context, message redis.XMessage) {
+// processMessage handles one delivery of an entry of shard's stream. attempt
+// is the entry's delivery count as tracked by the consumer group.
+func (sc *StreamConsumer) processMessage(ctx context.Context, shard int, message redis.XMessage, attempt int) {
 	start := time.Now()
 	defer func() {
 		sc.metrics.RedisOperationDuration.WithLabelValues("process_message").Observe(time.Since(start).Seconds())
@@ -107,8 +136,41 @@ func (sc *StreamConsumer) processMessage(ctx context.Context, message redis.XMes
 	if err != nil {
 		sc.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to parse timeout event")
 		sc.metrics.StreamMessagesProcessed.WithLabelValues("parse_error").Inc()
-		// Acknowledge message to prevent reprocessing
-		sc.acknowledgeMessage(ctx, message.ID)
+		// Retrying can't fix a malformed entry
+		sc.deadLetter(ctx, shard, message, fmt.Sprintf("parse error: %v", err), attempt)
+		return
+	}
+	event.Attempt = attempt
+
+	// Skip events that were already notified, e.g. redelivered after a failed ack
+	state, err := sc.ledger.Reserve(ctx, event.EventID)
+	if err != nil {
+		sc.logger.WithError(err).WithField("event_id", event.EventID).Error("Failed to check idempotency ledger")
+		sc.metrics.StreamMessagesProcessed.WithLabelValues("ledger_error").Inc()
+		// Don't acknowledge - let it retry
+		return
+	}
+
+	switch state {
+	case EventStateDone:
+		if err := sc.acknowledgeMessage(ctx, shard, message.ID); err != nil {
+			sc.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to acknowledge message")
+			return
+		}
+		sc.metrics.StreamMessagesProcessed.WithLabelValues("duplicate_skipped").Inc()
+		sc.logger.WithFields(logrus.Fields{
+			"event_id":   event.EventID,
+			"message_id": message.ID,
+		}).Debug("Skipped already processed timeout event")
+		return
+	case EventStateProcessing:
+		sc.metrics.StreamMessagesProcessed.WithLabelValues("duplicate_in_flight").Inc()
+		if attempt >= sc.config.DeliveryAttempts() {
+			// Held elsewhere for every delivery; don't wait on it any longer
+			sc.deadLetter(ctx, shard, message, "event still reserved by another consumer", attempt)
+			return
+		}
+		// Another consumer holds it; leave it pending in case that one fails
 		return
 	}
 
@@ -119,18 +181,43 @@ func (sc *StreamConsumer) processMessage(ctx context.Context, message redis.XMes
 			"level":           event.Level,
 			"message_id":      message.ID,
 		}).Error("Failed to send notification")
+		sc.record(ctx, event, models.HistoryNotificationFailed, err.Error())
+		if err := sc.ledger.Release(ctx, event.EventID); err != nil {
+			sc.logger.WithError(err).WithField("event_id", event.EventID).Error("Failed to release event")
+		}
+
+		if !notifier.IsRetryable(err) {
+			// Retrying won't help
+			sc.metrics.StreamMessagesProcessed.WithLabelValues("notification_rejected").Inc()
+			sc.deadLetter(ctx, shard, message, err.Error(), attempt)
+			return
+		}
+
 		sc.metrics.StreamMessagesProcessed.WithLabelValues("notification_error").Inc()
-		// Don't acknowledge - let it retry
+		// Don't acknowledge - let it retry, remembering why it failed in case it
+		// ends up dead-lettered
+		if err := sc.rdb.HSet(ctx, FailureReasonsKeyFor(shard), message.ID, err.Error()).Err(); err != nil {
+			sc.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to record failure reason")
+		}
+		return
+	}
+
+	// Unrecorded, the event would be sent again once its claim lapses; leave
+	// the entry pending rather than acknowledge it
+	if err := sc.ledger.Complete(ctx, event.EventID); err != nil {
+		sc.logger.WithError(err).WithField("event_id", event.EventID).Error("Failed to record processed event")
+		sc.metrics.StreamMessagesProcessed.WithLabelValues("ledger_error").Inc()
 		return
 	}
 
 	// Acknowledge successful processing
-	if err := sc.acknowledgeMessage(ctx, message.ID); err != nil {
+	if err := sc.acknowledgeMessage(ctx, shard, message.ID); err != nil {
 		sc.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to acknowledge message")
 		return
 	}
 
 	sc.metrics.StreamMessagesProcessed.WithLabelValues("success").Inc()
+	sc.record(ctx, event, models.HistoryNotificationDelivered, "")
 	sc.metrics.TimeoutNotificationsSent.WithLabelValues(fmt.Sprintf("level%d", event.Level)).Inc()
 
 	sc.logger.WithFields(logrus.Fields{
@@ -180,6 +267,24 @@ func (sc *StreamConsumer) parseTimeoutEvent(message redis.XMessage) (*models.Tim
 		return nil, fmt.Errorf("missing or invalid detected_at")
 	}
 
+	// Escalation details are informational and absent from older entries
+	event.LevelName, _ = message.Values["level_name"].(string)
+	event.Action, _ = message.Values["action"].(string)
+	event.Policy, _ = message.Values["policy"].(string)
+	if shardStr, ok := message.Values["shard"].(string); ok {
+		event.Shard, _ = strconv.Atoi(shardStr)
+	}
+	if tokenStr, ok := message.Values["fencing_token"].(string); ok {
+		event.FencingToken, _ = strconv.ParseInt(tokenStr, 10, 64)
+	}
+
+	if eventID, ok := message.Values["event_id"].(string); ok {
+		event.EventID = eventID
+	} else {
+		// Entries published before event IDs existed
+		event.EventID = models.TimeoutEventID(event.ConversationID, event.AgentMessageTime, event.Level)
+	}
+
 	if attemptStr, ok := message.Values["attempt"].(string); ok {
 		if attempt, err := strconv.Atoi(attemptStr); err == nil {
 			event.Attempt = attempt
@@ -194,34 +299,36 @@ func (sc *StreamConsumer) parseTimeoutEvent(message redis.XMessage) (*models.Tim
 }
 
 func (sc *StreamConsumer) sendNotification(ctx context.Context, event *models.TimeoutEvent) error {
-	// In a real implementation, this would call your notification service
-	// For POC, we'll simulate the notification sending
-
 	sc.logger.WithFields(logrus.Fields{
+		"event_id":        event.EventID,
 		"conversation_id": event.ConversationID,
 		"level":           event.Level,
 		"detected_at":     event.DetectedAt,
 		"attempt":         event.Attempt,
 	}).Info("Sending timeout notification via stream consumer")
 
-	// Simulate notification service call
-	time.Sleep(10 * time.Millisecond) // Simulate API call latency
+	return sc.notifier.Notify(ctx, *event)
+}
 
-	// TODO: Replace with actual notification service call
-	// Example:
-	// if err := notificationService.SendTimeoutAlert(ctx, event); err != nil {
-	//     return fmt.Errorf("notification service error: %w", err)
-	// }
+func (sc *StreamConsumer) acknowledgeMessage(ctx context.Context, shard int, messageID string) error {
+	pipe := sc.rdb.Pipeline()
+	pipe.XAck(ctx, EventsStream(shard), sc.config.ConsumerGroupName, messageID)
+	pipe.HDel(ctx, FailureReasonsKeyFor(shard), messageID)
 
-	return nil
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (sc *StreamConsumer) acknowledgeMessage(ctx context.Context, messageID string) error {
-	return sc.rdb.XAck(ctx, TimeoutEventsStream, sc.config.ConsumerGroupName, messageID).Err()
+func (sc *StreamConsumer) deadLetter(ctx context.Context, shard int, message redis.XMessage, reason string, attempts int) {
+	if err := sc.deadLetters.Add(ctx, shard, message, reason, attempts); err != nil {
+		sc.logger.WithError(err).WithField("message_id", message.ID).Error("Failed to dead-letter message")
+		return
+	}
+	sc.metrics.StreamMessagesProcessed.WithLabelValues("dead_lettered").Inc()
 }
 
 func (sc *StreamConsumer) pendingMessagesRecovery(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Second) // Check for pending messages every 30 seconds
+	ticker := sc.clock.NewTicker(30 * time.Second) // Check for pending messages every 30 seconds
 	defer ticker.Stop()
 
 	for {
@@ -230,43 +337,58 @@ func (sc *StreamConsumer) pendingMessagesRecovery(ctx context.Context) {
 			return
 		case <-sc.stopCh:
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			sc.processPendingMessages(ctx)
 		}
 	}
 }
 
 func (sc *StreamConsumer) processPendingMessages(ctx context.Context) {
-	// Get pending messages for this consumer
-	pending, err := sc.rdb.XPending(ctx, TimeoutEventsStream, sc.config.ConsumerGroupName).Result()
-	if err != nil {
-		sc.logger.WithError(err).Error("Failed to get pending messages")
-		return
+	for s := 0; s < sc.config.Shards(); s++ {
+		sc.processPendingShard(ctx, s)
 	}
+}
 
-	if pending.Count == 0 {
-		return
+// processPendingShard reclaims the stalled entries of shard's stream
+func (sc *StreamConsumer) processPendingShard(ctx context.Context, shard int) {
+	exhausted := func(entry redis.XPendingExt) {
+		sc.deadLetterExhausted(ctx, shard, entry)
 	}
+	for _, claimed := range sc.reclaimer.reclaim(ctx, EventsStream(shard), sc.claimMinIdle, exhausted) {
+		sc.processMessage(ctx, shard, claimed.message, claimed.attempt)
+	}
+}
 
-	sc.logger.WithField("pending_count", pending.Count).Info("Processing pending messages")
-
-	// Claim messages that have been pending for more than 1 minute
-	minIdleTime := 1 * time.Minute
-	messages, _, err := sc.rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
-		Stream:   TimeoutEventsStream,
-		Group:    sc.config.ConsumerGroupName,
-		Consumer: sc.consumerName,
-		MinIdle:  minIdleTime,
-		Count:    10,
-		Start:    "0-0",
-	}).Result()
-
+// deadLetterExhausted moves a pending entry that ran out of delivery attempts
+// to the dead-letter stream, with the last failure recorded for it
+func (sc *StreamConsumer) deadLetterExhausted(ctx context.Context, shard int, entry redis.XPendingExt) {
+	messages, err := sc.rdb.XRange(ctx, EventsStream(shard), entry.ID, entry.ID).Result()
 	if err != nil {
-		sc.logger.WithError(err).Error("Failed to auto-claim pending messages")
+		sc.logger.WithError(err).WithField("message_id", entry.ID).Error("Failed to read exhausted message")
+		return
+	}
+	if len(messages) == 0 {
+		// Trimmed from the stream; nothing left to keep
+		sc.acknowledgeMessage(ctx, shard, entry.ID)
 		return
 	}
 
-	for _, message := range messages {
-		sc.processMessage(ctx, message)
+	reason := fmt.Sprintf("exceeded %d delivery attempts", sc.config.DeliveryAttempts())
+	if lastErr, err := sc.rdb.HGet(ctx, FailureReasonsKeyFor(shard), entry.ID).Result(); err == nil {
+		reason += ": " + lastErr
 	}
+
+	sc.deadLetter(ctx, shard, messages[0], reason, int(entry.RetryCount))
+}
+
+// record appends what became of a timeout event's notification to its
+// conversation's timeline
+func (sc *StreamConsumer) record(ctx context.Context, event *models.TimeoutEvent, eventType, detail string) {
+	phase1.RecordHistory(ctx, sc.store, sc.logger, event.ConversationID, models.HistoryEvent{
+		Type:      eventType,
+		Timestamp: sc.clock.Now(),
+		ActorID:   sc.consumerName,
+		Level:     event.Level,
+		Detail:    detail,
+	})
 }
@@ -3,6 +3,7 @@ package phase2
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -10,208 +11,344 @@ import (
 	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/models"
 	"redis-timeout-tracking-poc/pkg/phase1"
+	"redis-timeout-tracking-poc/pkg/shard"
 )
 
 const (
+	// TimeoutEventsStream is the base name of the per-shard event streams;
+	// see EventsStream
 	TimeoutEventsStream = "timeout_events"
 )
 
+// EventsStream returns the stream a shard's timeout events are published to,
+// e.g. "timeout_events:{3}". It shares the shard's hash tag, so the publish
+// script can write it together with the shard's keys on a cluster.
+func EventsStream(s int) string {
+	return shard.Key(TimeoutEventsStream, s)
+}
+
 type StreamProducer struct {
-	rdb            *redis.Client
-	config         *config.Config
-	logger         *logrus.Logger
-	metrics        *metrics.Metrics
-	leaderElection *phase1.LeaderElection
+	rdb         redis.UniversalClient
+	store       *phase1.RedisStore
+	config      *config.Config
+	logger      *logrus.Logger
+	metrics     *metrics.Metrics
+	evaluator   *escalation.Evaluator
+	clock       clock.Clock
+	leases      []*phase1.LeaderElection
+	coordinator *phase1.Coordinator
 }
 
-func NewStreamProducer(rdb *redis.Client, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics) *StreamProducer {
-	leaderElection := phase1.NewLeaderElection(rdb, config, logger, metrics)
+func NewStreamProducer(rdb redis.UniversalClient, config *config.Config, logger *logrus.Logger, metrics *metrics.Metrics, evaluator *escalation.Evaluator, clock clock.Clock) *StreamProducer {
+	// The publish script writes the store's keys along with the stream, so
+	// the store has to be the Redis one
+	store := phase1.NewRedisStore(rdb, config)
+
+	// Only the shard leases are used here; detection and publishing are ours
+	leases := make([]*phase1.LeaderElection, config.Shards())
+	for s := range leases {
+		leases[s] = phase1.NewLeaderElection(rdb, store, config, logger, metrics, nil, nil, clock, s)
+	}
 
 	return &StreamProducer{
-		rdb:            rdb,
-		config:         config,
-		logger:         logger,
-		metrics:        metrics,
-		leaderElection: leaderElection,
+		rdb:         rdb,
+		store:       store,
+		config:      config,
+		logger:      logger,
+		metrics:     metrics,
+		evaluator:   evaluator,
+		clock:       clock,
+		leases:      leases,
+		coordinator: phase1.NewCoordinator(rdb, config, logger, clock, leases),
 	}
 }
 
 func (sp *StreamProducer) Start(ctx context.Context) error {
-	sp.logger.Info("Starting Phase 2 stream producer (leader-only timeout detector)")
+	sp.logger.Info("Starting Phase 2 stream producer (per-shard timeout detector)")
 
-	// Create consumer group if it doesn't exist
+	// Create consumer groups if they don't exist
 	if err := sp.createConsumerGroup(ctx); err != nil {
 		return fmt.Errorf("failed to create consumer group: %w", err)
 	}
 
+	// Nothing reads the unsharded stream of earlier versions any more
+	if pending, err := sp.rdb.XLen(ctx, TimeoutEventsStream).Result(); err == nil && pending > 0 {
+		sp.logger.WithFields(logrus.Fields{
+			"stream":  TimeoutEventsStream,
+			"entries": pending,
+		}).Warn("Unsharded timeout event stream still has entries; they will not be delivered")
+	}
+
+	// Run each shard's timeout detection only while leading it. A lease's
+	// callbacks are serialized, so its cancel needs no lock of its own.
+	for _, lease := range sp.leases {
+		lease := lease
+		var cancel context.CancelFunc
+		lease.OnElected(func() {
+			var detectCtx context.Context
+			detectCtx, cancel = context.WithCancel(ctx)
+			go sp.timeoutDetectionLoop(detectCtx, lease)
+		})
+		lease.OnRevoked(func() {
+			cancel()
+		})
+	}
+
 	// Start leader election
-	if err := sp.leaderElection.Start(ctx); err != nil {
+	if err := sp.coordinator.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start leader election: %w", err)
 	}
 
-	// Start timeout detection loop (leader only)
-	go sp.timeoutDetectionLoop(ctx)
-
 	sp.logger.Info("Stream producer started successfully")
 	return nil
 }
 
 func (sp *StreamProducer) Stop() {
-	sp.leaderElection.Stop()
+	sp.coordinator.Stop()
 }
 
+// IsLeader reports whether this pod leads any detection shard
 func (sp *StreamProducer) IsLeader() bool {
-	return sp.leaderElection.IsLeader()
+	return sp.coordinator.IsLeader()
+}
+
+// OwnedShards returns the detection shards this pod leads
+func (sp *StreamProducer) OwnedShards() []int {
+	return sp.coordinator.OwnedShards()
 }
 
 func (sp *StreamProducer) createConsumerGroup(ctx context.Context) error {
-	// Create consumer group (idempotent operation)
-	err := sp.rdb.XGroupCreateMkStream(ctx, TimeoutEventsStream, sp.config.ConsumerGroupName, "$").Err()
-	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
-		return fmt.Errorf("failed to create consumer group: %w", err)
+	// Create the consumer group on every shard's stream (idempotent operation)
+	for s := 0; s < sp.config.Shards(); s++ {
+		err := sp.rdb.XGroupCreateMkStream(ctx, EventsStream(s), sp.config.ConsumerGroupName, "$").Err()
+		if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+			return fmt.Errorf("failed to create consumer group: %w", err)
+		}
 	}
 
 	sp.logger.WithField("consumer_group", sp.config.ConsumerGroupName).Info("Consumer group ready")
 	return nil
 }
 
-func (sp *StreamProducer) timeoutDetectionLoop(ctx context.Context) {
-	ticker := time.NewTicker(sp.config.CheckInterval())
+// timeoutDetectionLoop detects a shard's timeouts every check interval until
+// ctx is cancelled, which happens when its lease is revoked
+func (sp *StreamProducer) timeoutDetectionLoop(ctx context.Context, lease *phase1.LeaderElection) {
+	ticker := sp.clock.NewTicker(sp.config.CheckInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			if sp.leaderElection.IsLeader() {
-				sp.detectAndPublishTimeouts(ctx)
+		case <-ticker.C():
+			if lease.IsLeader() {
+				sp.detectAndPublishTimeouts(ctx, lease)
 			}
 		}
 	}
 }
 
-func (sp *StreamProducer) detectAndPublishTimeouts(ctx context.Context) {
+func (sp *StreamProducer) detectAndPublishTimeouts(ctx context.Context, lease *phase1.LeaderElection) {
 	start := time.Now()
 	defer func() {
 		sp.metrics.TimeoutCheckDuration.Observe(time.Since(start).Seconds())
 	}()
 
-	now := time.Now().UnixMilli()
+	now := sp.clock.Now().UnixMilli()
 
-	// Get all waiting conversations
-	conversations, err := sp.rdb.ZRangeByScoreWithScores(ctx, phase1.WaitingConversationsKey, &redis.ZRangeBy{
-		Min: "0",
-		Max: fmt.Sprintf("%d", now),
-	}).Result()
-
-	if err != nil {
-		sp.logger.WithError(err).Error("Failed to get waiting conversations")
-		return
+	keys := lease.Keys()
+	if count, err := sp.store.Waiting(ctx, keys.Shard); err == nil {
+		sp.metrics.WaitingConversationsCount.WithLabelValues(strconv.Itoa(keys.Shard)).Set(float64(count))
 	}
 
-	sp.metrics.WaitingConversationsCount.Set(float64(len(conversations)))
+	// Fetch only conversations whose next deadline has passed, a batch at a
+	// time. Handled conversations are rescheduled out of the range; the rest
+	// stay at the front and are skipped with the offset.
+	batchSize := sp.config.DetectionBatch()
+	var offset int64
+	for {
+		conversations, err := sp.store.Due(ctx, keys.Shard, now, offset, batchSize)
+		if err != nil {
+			sp.logger.WithError(err).Error("Failed to get waiting conversations")
+			return
+		}
 
-	for _, conv := range conversations {
-		conversationID := conv.Member.(string)
-		startTime := int64(conv.Score)
+		for _, conversationID := range conversations {
+			if !sp.processTimeoutDetection(ctx, lease, conversationID, now) {
+				offset++
+			}
+			if !lease.IsLeader() {
+				return // Lease lost or fenced off by a newer leader
+			}
+		}
 
-		sp.processTimeoutDetection(ctx, conversationID, startTime, now)
+		if int64(len(conversations)) < batchSize {
+			return
+		}
 	}
 }
 
-func (sp *StreamProducer) processTimeoutDetection(ctx context.Context, conversationID string, startTime, now int64) {
-	waitTime := now - startTime
-	timeoutInterval := sp.config.TimeoutIntervalMS
-
-	// Get current notification level
-	currentLevelStr, err := sp.rdb.HGet(ctx, phase1.NotificationStatesKey, conversationID).Result()
-	if err != nil && err != redis.Nil {
+// processTimeoutDetection publishes the timeout event for a due conversation.
+// It returns true when the conversation has left the due range, whether moved
+// to its next deadline or cleared, re-tracked or paused since the scan, and
+// false when it is still due and the scan must skip it.
+func (sp *StreamProducer) processTimeoutDetection(ctx context.Context, lease *phase1.LeaderElection, conversationID string, now int64) bool {
+	// Get current notification level and the conversation's policy
+	state, err := sp.store.State(ctx, conversationID)
+	if err != nil {
 		sp.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to get notification state")
-		return
+		return false
 	}
-
-	currentLevel := 0
-	if currentLevelStr != "" {
-		if level, err := strconv.Atoi(currentLevelStr); err == nil {
-			currentLevel = level
+	if state.AgentMessageTime == 0 {
+		if state.Deadline != 0 {
+			// Scheduled without an agent message time, e.g. left behind by a
+			// half-applied clear; nothing to escalate, so skip it
+			sp.logger.WithField("conversation_id", conversationID).Warn("Skipping waiting conversation without an agent message time")
+			return false
 		}
+		return true // Cleared since the scan
 	}
-
-	// Check for timeout levels
-	var newLevel int
-	if waitTime > timeoutInterval*3 && currentLevel < 3 {
-		newLevel = 3
-	} else if waitTime > timeoutInterval*2 && currentLevel < 2 {
-		newLevel = 2
-	} else if waitTime > timeoutInterval && currentLevel < 1 {
-		newLevel = 1
-	} else {
-		return // No new notification needed
+	if state.Paused() {
+		// Due at the end of its snooze
+		resumed, err := phase1.ResumeSnoozed(ctx, sp.store, sp.evaluator, conversationID, state)
+		if err != nil {
+			sp.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to resume snoozed conversation")
+			return false
+		}
+		if resumed {
+			sp.record(ctx, conversationID, models.HistoryResumed, state.Level, "snooze ended")
+		}
+		// Rescheduled, or changed since the scan
+		return true
+	}
+	policy := sp.evaluator.Select(state.Attributes)
+	waitTime := sp.evaluator.WaitMS(policy, state.Attributes, state.AgentMessageTime, now)
+
+	// Check for timeout levels, or a manual escalation
+	newLevel, due := phase1.EscalationLevel(sp.evaluator, policy, state, now)
+	if !due {
+		// Due by a stale deadline, e.g. after a policy change; move it on
+		deadline := phase1.DeadlineScore(sp.evaluator.NextDeadlineMS(policy, state.Attributes, state.AgentMessageTime, state.Level))
+		_, err := sp.store.Advance(ctx, conversationID, state, state.Level, deadline, lease.FencingToken())
+		if errors.Is(err, phase1.ErrStaleFencingToken) {
+			lease.StepDown()
+			return false
+		}
+		if err != nil {
+			sp.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to reschedule conversation")
+			return false
+		}
+		// Rescheduled, or changed since the scan
+		return true
 	}
 
-	// Publish timeout event to stream
-	if err := sp.publishTimeoutEvent(ctx, conversationID, newLevel, startTime); err != nil {
+	// Publish the event and advance the level together
+	published, err := sp.publishTimeoutEvent(ctx, lease, conversationID, policy, state, newLevel)
+	if errors.Is(err, phase1.ErrStaleFencingToken) {
+		lease.StepDown()
+		return false
+	}
+	if err != nil {
 		sp.logger.WithError(err).WithFields(logrus.Fields{
 			"conversation_id": conversationID,
 			"level":           newLevel,
 		}).Error("Failed to publish timeout event")
-		return
+		return false
 	}
-
-	// Update notification state to prevent duplicate detection
-	if err := sp.rdb.HSet(ctx, phase1.NotificationStatesKey, conversationID, newLevel).Err(); err != nil {
-		sp.logger.WithError(err).WithField("conversation_id", conversationID).Error("Failed to update notification state")
+	if !published {
+		// Cleared, re-tracked or escalated since it was read, so no longer
+		// due; the next tick re-evaluates it from fresh state
+		return true
 	}
+	sp.record(ctx, conversationID, models.HistoryEscalated, newLevel, sp.evaluator.Level(policy, newLevel).Name)
 
 	sp.logger.WithFields(logrus.Fields{
 		"conversation_id": conversationID,
 		"level":           newLevel,
 		"wait_time_ms":    waitTime,
 	}).Debug("Published timeout event to stream")
+
+	return true
+}
+
+// record appends an event this pod caused now to a conversation's timeline
+func (sp *StreamProducer) record(ctx context.Context, conversationID, eventType string, level int, detail string) {
+	phase1.RecordHistory(ctx, sp.store, sp.logger, conversationID, models.HistoryEvent{
+		Type:      eventType,
+		Timestamp: sp.clock.Now(),
+		ActorID:   sp.config.PodID,
+		Level:     level,
+		Detail:    detail,
+	})
 }
 
-func (sp *StreamProducer) publishTimeoutEvent(ctx context.Context, conversationID string, level int, startTime int64) error {
+// publishTimeoutEvent appends a timeout event to the stream and advances the
+// conversation's notification level from state to level atomically. It
+// returns false without writing anything when the conversation no longer
+// matches state, and phase1.ErrStaleFencingToken when a newer leader of the
+// shard has been elected.
+func (sp *StreamProducer) publishTimeoutEvent(ctx context.Context, lease *phase1.LeaderElection, conversationID string, policy *escalation.Policy, state phase1.ConversationState, level int) (bool, error) {
+	startTime := state.AgentMessageTime
+	deadline := phase1.DeadlineScore(sp.evaluator.NextDeadlineMS(policy, state.Attributes, startTime, level))
+	levelDef := sp.evaluator.Level(policy, level)
 	event := models.TimeoutEvent{
+		EventID:          models.TimeoutEventID(conversationID, time.UnixMilli(startTime), level),
 		ConversationID:   conversationID,
 		Level:            level,
+		LevelName:        levelDef.Name,
+		Action:           levelDef.Action,
+		Policy:           policy.Name,
 		AgentMessageTime: time.UnixMilli(startTime),
-		DetectedAt:       time.Now(),
+		DetectedAt:       sp.clock.Now(),
 		Attempt:          1,
+		Shard:            lease.Shard(),
+		FencingToken:     lease.FencingToken(),
 	}
 
 	eventData, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal timeout event: %w", err)
+		return false, fmt.Errorf("failed to marshal timeout event: %w", err)
 	}
 
-	// Add to Redis stream
-	streamArgs := &redis.XAddArgs{
-		Stream: TimeoutEventsStream,
-		Values: map[string]interface{}{
-			"conversation_id":    event.ConversationID,
-			"level":              event.Level,
-			"agent_message_time": event.AgentMessageTime.UnixMilli(),
-			"detected_at":        event.DetectedAt.UnixMilli(),
-			"attempt":            event.Attempt,
-			"event_data":         string(eventData),
-		},
+	shardKeys := lease.Keys()
+	keys := []string{shardKeys.Waiting, shardKeys.NotificationStates, EventsStream(lease.Shard()), shardKeys.WaitingSince, shardKeys.LeaderEpoch, shardKeys.Paused}
+	args := []interface{}{
+		conversationID, startTime, state.Level, level, deadline, event.FencingToken,
+		"event_id", event.EventID,
+		"conversation_id", event.ConversationID,
+		"level", event.Level,
+		"level_name", event.LevelName,
+		"action", event.Action,
+		"policy", event.Policy,
+		"agent_message_time", event.AgentMessageTime.UnixMilli(),
+		"detected_at", event.DetectedAt.UnixMilli(),
+		"attempt", event.Attempt,
+		"shard", event.Shard,
+		"fencing_token", event.FencingToken,
+		"event_data", string(eventData),
 	}
 
-	messageID, err := sp.rdb.XAdd(ctx, streamArgs).Result()
+	messageID, err := publishTimeoutEventScript.Run(ctx, sp.rdb, keys, args...).Text()
+	if err == redis.Nil {
+		return false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to add message to stream: %w", err)
+		return false, fmt.Errorf("failed to add message to stream: %w", phase1.AsFencingError(err))
 	}
 
 	sp.logger.WithFields(logrus.Fields{
 		"conversation_id": conversationID,
 		"level":           level,
+		"event_id":        event.EventID,
 		"message_id":      messageID,
 	}).Debug("Published timeout event to stream")
+	phase1.PublishEscalation(ctx, sp.rdb, sp.logger, event)
 
-	return nil
+	return true, nil
 }
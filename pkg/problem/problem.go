@@ -0,0 +1,117 @@
+// Package problem writes the HTTP API's error responses as RFC 7807 problem
+// documents, identified by machine-readable codes
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of problem documents
+const ContentType = "application/problem+json"
+
+// typePrefix makes a problem type URI of a code
+const typePrefix = "urn:timeout-tracking:problem:"
+
+// Problem codes
+const (
+	// CodeInvalidBody - the body isn't a JSON document of the expected shape
+	CodeInvalidBody = "invalid_body"
+	// CodeUnknownField - the body has a field the endpoint doesn't take
+	CodeUnknownField = "unknown_field"
+	// CodeBodyTooLarge - the body is over the endpoint's size limit
+	CodeBodyTooLarge = "body_too_large"
+	// CodeValidationFailed - fields are missing or invalid; see errors
+	CodeValidationFailed = "validation_failed"
+	// CodeInvalidParameter - a path or query parameter is invalid
+	CodeInvalidParameter = "invalid_parameter"
+	// CodeUnknownPolicy - the escalation policy named isn't defined
+	CodeUnknownPolicy = "unknown_policy"
+	// CodeInvalidLevel - the escalation level isn't above the conversation's
+	// in its policy
+	CodeInvalidLevel = "invalid_level"
+	// CodeBatchEmpty and CodeBatchTooLarge - a batch has no events, or too many
+	CodeBatchEmpty    = "batch_empty"
+	CodeBatchTooLarge = "batch_too_large"
+	// CodeConversationNotTracked - the conversation isn't waiting
+	CodeConversationNotTracked = "conversation_not_tracked"
+	// CodeConversationPaused and CodeConversationNotPaused - the hold
+	// operation doesn't apply to the conversation as it is
+	CodeConversationPaused    = "conversation_paused"
+	CodeConversationNotPaused = "conversation_not_paused"
+	// CodeConcurrentUpdate - the conversation changed while being updated;
+	// the request can be retried
+	CodeConcurrentUpdate = "concurrent_update"
+	// CodeNotFound - no such route or resource
+	CodeNotFound = "not_found"
+	// CodeMethodNotAllowed - the route doesn't take the method
+	CodeMethodNotAllowed = "method_not_allowed"
+	// CodeUnavailable - the service can't reach its storage
+	CodeUnavailable = "unavailable"
+	// CodeInternal - anything else; details are only logged
+	CodeInternal = "internal_error"
+)
+
+// Field error codes
+const (
+	FieldRequired      = "required"
+	FieldTooLong       = "too_long"
+	FieldInvalidFormat = "invalid_format"
+	FieldInFuture      = "in_future"
+	FieldTooOld        = "too_old"
+)
+
+// FieldError is what is wrong with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem document, extended with the problem's code
+// and, when fields failed validation, what is wrong with each
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// New creates the problem of a response with status
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write answers r with p
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Respond answers r with a new problem
+func Respond(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, r, New(status, code, detail))
+}
+
+// Handler answers every request with a new problem, as for routes that
+// don't exist
+func Handler(status int, code, detail string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Respond(w, r, status, code, detail)
+	})
+}
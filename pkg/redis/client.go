@@ -9,13 +9,38 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Connection modes
+const (
+	// ModeStandalone connects to the single node in URL
+	ModeStandalone = "standalone"
+	// ModeSentinel asks the sentinels in Addrs for the current master of
+	// MasterName and follows failovers
+	ModeSentinel = "sentinel"
+	// ModeCluster connects to a Redis Cluster, discovering the nodes from the
+	// seeds in Addrs
+	ModeCluster = "cluster"
+)
+
 type Client struct {
-	rdb    *redis.Client
+	rdb    redis.UniversalClient
 	logger *logrus.Logger
 }
 
 type ConnectionConfig struct {
-	URL                string
+	// Mode is one of the connection modes; empty means ModeStandalone
+	Mode string
+	// URL of the node in standalone mode
+	URL string
+	// Addrs are the sentinels in sentinel mode and the seed nodes in cluster
+	// mode
+	Addrs []string
+	// MasterName is the master the sentinels monitor
+	MasterName string
+	// Password authenticates to the Redis nodes in sentinel and cluster mode;
+	// in standalone mode it is part of URL
+	Password string
+	// SentinelPassword authenticates to the sentinels
+	SentinelPassword   string
 	MaxRetries         int
 	MinRetryBackoff    time.Duration
 	MaxRetryBackoff    time.Duration
@@ -31,27 +56,11 @@ type ConnectionConfig struct {
 }
 
 func NewClient(config ConnectionConfig, logger *logrus.Logger) (*Client, error) {
-	opt, err := redis.ParseURL(config.URL)
+	rdb, err := newUniversalClient(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		return nil, err
 	}
 
-	// Apply connection configuration
-	opt.MaxRetries = config.MaxRetries
-	opt.MinRetryBackoff = config.MinRetryBackoff
-	opt.MaxRetryBackoff = config.MaxRetryBackoff
-	opt.DialTimeout = config.DialTimeout
-	opt.ReadTimeout = config.ReadTimeout
-	opt.WriteTimeout = config.WriteTimeout
-	opt.PoolSize = config.PoolSize
-	opt.MinIdleConns = config.MinIdleConns
-	opt.MaxConnAge = config.MaxConnAge
-	opt.PoolTimeout = config.PoolTimeout
-	opt.IdleTimeout = config.IdleTimeout
-	opt.IdleCheckFrequency = config.IdleCheckFrequency
-
-	rdb := redis.NewClient(opt)
-
 	client := &Client{
 		rdb:    rdb,
 		logger: logger,
@@ -65,7 +74,7 @@ func NewClient(config ConnectionConfig, logger *logrus.Logger) (*Client, error)
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	logger.Info("Successfully connected to Redis")
+	logger.WithField("mode", config.mode()).Info("Successfully connected to Redis")
 	return client, nil
 }
 
@@ -77,10 +86,79 @@ func (c *Client) Close() error {
 	return c.rdb.Close()
 }
 
-func (c *Client) GetRedisClient() *redis.Client {
+// GetRedisClient returns the underlying client: a *redis.Client, a failover
+// client or a *redis.ClusterClient depending on the mode
+func (c *Client) GetRedisClient() redis.UniversalClient {
 	return c.rdb
 }
 
+func (config ConnectionConfig) mode() string {
+	if config.Mode == "" {
+		return ModeStandalone
+	}
+	return config.Mode
+}
+
+// newUniversalClient builds the client for the configured mode without
+// connecting
+func newUniversalClient(config ConnectionConfig) (redis.UniversalClient, error) {
+	// Used by sentinel and cluster mode
+	opts := &redis.UniversalOptions{
+		Addrs:              config.Addrs,
+		MasterName:         config.MasterName,
+		Password:           config.Password,
+		SentinelPassword:   config.SentinelPassword,
+		MaxRetries:         config.MaxRetries,
+		MinRetryBackoff:    config.MinRetryBackoff,
+		MaxRetryBackoff:    config.MaxRetryBackoff,
+		DialTimeout:        config.DialTimeout,
+		ReadTimeout:        config.ReadTimeout,
+		WriteTimeout:       config.WriteTimeout,
+		PoolSize:           config.PoolSize,
+		MinIdleConns:       config.MinIdleConns,
+		MaxConnAge:         config.MaxConnAge,
+		PoolTimeout:        config.PoolTimeout,
+		IdleTimeout:        config.IdleTimeout,
+		IdleCheckFrequency: config.IdleCheckFrequency,
+	}
+
+	switch config.mode() {
+	case ModeStandalone:
+		opt, err := redis.ParseURL(config.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+
+		// Apply connection configuration
+		opt.MaxRetries = config.MaxRetries
+		opt.MinRetryBackoff = config.MinRetryBackoff
+		opt.MaxRetryBackoff = config.MaxRetryBackoff
+		opt.DialTimeout = config.DialTimeout
+		opt.ReadTimeout = config.ReadTimeout
+		opt.WriteTimeout = config.WriteTimeout
+		opt.PoolSize = config.PoolSize
+		opt.MinIdleConns = config.MinIdleConns
+		opt.MaxConnAge = config.MaxConnAge
+		opt.PoolTimeout = config.PoolTimeout
+		opt.IdleTimeout = config.IdleTimeout
+		opt.IdleCheckFrequency = config.IdleCheckFrequency
+
+		return redis.NewClient(opt), nil
+	case ModeSentinel:
+		if config.MasterName == "" || len(config.Addrs) == 0 {
+			return nil, fmt.Errorf("sentinel mode needs a master name and sentinel addresses")
+		}
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case ModeCluster:
+		if len(config.Addrs) == 0 {
+			return nil, fmt.Errorf("cluster mode needs seed node addresses")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", config.Mode)
+	}
+}
+
 // DefaultConnectionConfig returns a production-ready Redis configuration
 func DefaultConnectionConfig() ConnectionConfig {
 	return ConnectionConfig{
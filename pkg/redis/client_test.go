@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUniversalClient_Modes(t *testing.T) {
+	config := DefaultConnectionConfig()
+	config.URL = "redis://:secret@localhost:6380/2"
+
+	rdb, err := newUniversalClient(config)
+	require.NoError(t, err)
+	defer rdb.Close()
+	client, ok := rdb.(*redis.Client)
+	require.True(t, ok, "standalone mode should build a single-node client")
+	assert.Equal(t, "localhost:6380", client.Options().Addr)
+	assert.Equal(t, "secret", client.Options().Password)
+	assert.Equal(t, 2, client.Options().DB)
+	assert.Equal(t, config.PoolSize, client.Options().PoolSize)
+
+	config.Mode = ModeSentinel
+	config.Addrs = []string{"sentinel-1:26379", "sentinel-2:26379"}
+	config.MasterName = "mymaster"
+	rdb, err = newUniversalClient(config)
+	require.NoError(t, err)
+	defer rdb.Close()
+	_, ok = rdb.(*redis.Client)
+	assert.True(t, ok, "sentinel mode should build a failover client")
+
+	config.Mode = ModeCluster
+	config.Addrs = []string{"node-1:6379", "node-2:6379"}
+	rdb, err = newUniversalClient(config)
+	require.NoError(t, err)
+	defer rdb.Close()
+	cluster, ok := rdb.(*redis.ClusterClient)
+	require.True(t, ok, "cluster mode should build a cluster client")
+	assert.Equal(t, config.Addrs, cluster.Options().Addrs)
+}
+
+func TestNewUniversalClient_InvalidConfig(t *testing.T) {
+	config := DefaultConnectionConfig()
+
+	config.Mode = ModeSentinel
+	_, err := newUniversalClient(config)
+	assert.Error(t, err, "sentinel mode without sentinels")
+
+	config.Mode = ModeCluster
+	_, err = newUniversalClient(config)
+	assert.Error(t, err, "cluster mode without seed nodes")
+
+	config.Mode = "replicated"
+	_, err = newUniversalClient(config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/problem"
+)
+
+// RequestIDHeader carries a request's ID. A caller's ID is kept, so requests
+// can be traced across services; one is generated otherwise. Either way it is
+// echoed in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the caller IDs that are kept
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a
+// request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func loggingMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := recordStatus(w)
+
+			next.ServeHTTP(recorder, r)
+
+			logger.WithFields(logrus.Fields{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"status":     recorder.status,
+				"duration":   time.Since(start),
+				"remote":     r.RemoteAddr,
+				"request_id": RequestID(r.Context()),
+			}).Debug("HTTP request processed")
+		})
+	}
+}
+
+// metricsMiddleware counts and times requests by route template rather than
+// path, so conversation IDs don't multiply the series
+func metricsMiddleware(metrics *metrics.Metrics) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := recordStatus(w)
+
+			next.ServeHTTP(recorder, r)
+
+			route := "unknown"
+			if current := mux.CurrentRoute(r); current != nil {
+				if template, err := current.GetPathTemplate(); err == nil {
+					route = template
+				}
+			}
+			metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// recoveryMiddleware answers a request whose handler panicked with a 500,
+// instead of dropping the connection
+func recoveryMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := recordStatus(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					// Deliberately aborted; net/http handles it quietly
+					panic(recovered)
+				}
+
+				logger.WithFields(logrus.Fields{
+					"method":     r.Method,
+					"path":       r.URL.Path,
+					"request_id": RequestID(r.Context()),
+					"panic":      recovered,
+					"stack":      string(debug.Stack()),
+				}).Error("HTTP handler panicked")
+
+				// Too late to change the response once it has started
+				if !recorder.written {
+					problem.Respond(recorder, r, http.StatusInternalServerError, problem.CodeInternal, "")
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// statusRecorder remembers the status of the response written through it
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+// recordStatus wraps w, or returns it when an outer middleware already did,
+// so every middleware sees the same status
+func recordStatus(w http.ResponseWriter) *statusRecorder {
+	if recorder, ok := w.(*statusRecorder); ok {
+		return recorder
+	}
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.written {
+		r.status = status
+		r.written = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.written = true
+	return r.ResponseWriter.Write(b)
+}
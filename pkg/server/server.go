@@ -8,27 +8,64 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 
+	"redis-timeout-tracking-poc/pkg/clock"
 	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
 	"redis-timeout-tracking-poc/pkg/handlers"
+	"redis-timeout-tracking-poc/pkg/metrics"
 	"redis-timeout-tracking-poc/pkg/phase1"
+	"redis-timeout-tracking-poc/pkg/problem"
 )
 
-func NewHTTPServer(config *config.Config, timeoutManager *phase1.TimeoutManager, logger *logrus.Logger, isLeaderFunc func() bool) *http.Server {
-	handler := handlers.NewHandler(timeoutManager, logger, isLeaderFunc)
+// Extension adds phase-specific endpoints to the HTTP API. They are served
+// behind the same middleware as the shared ones.
+type Extension interface {
+	RegisterRoutes(router *mux.Router)
+}
+
+// NewHTTPServer creates the HTTP API of a timeout tracking pod: the endpoints
+// both phases share, those of extensions, and the metrics endpoint, behind
+// request ID, logging, metrics and panic recovery middleware
+func NewHTTPServer(config *config.Config, timeoutManager *phase1.TimeoutManager, evaluator *escalation.Evaluator, logger *logrus.Logger, metrics *metrics.Metrics, cluster handlers.Cluster, clock clock.Clock, extensions ...Extension) *http.Server {
+	handler := handlers.NewHandler(timeoutManager, evaluator, config, logger, cluster, clock)
 
 	router := mux.NewRouter()
 
 	// API routes
+	router.HandleFunc("/conversations", handler.ListConversations).Methods("GET")
+	router.HandleFunc("/conversations/events:batch", handler.EventsBatch).Methods("POST")
+	router.HandleFunc("/conversations/{id}", handler.GetConversation).Methods("GET")
+	router.HandleFunc("/conversations/{id}/history", handler.GetHistory).Methods("GET")
 	router.HandleFunc("/conversations/{id}/agent-message", handler.AgentMessage).Methods("POST")
 	router.HandleFunc("/conversations/{id}/customer-response", handler.CustomerResponse).Methods("POST")
+	router.HandleFunc("/conversations/{id}/pause", handler.PauseConversation).Methods("POST")
+	router.HandleFunc("/conversations/{id}/resume", handler.ResumeConversation).Methods("POST")
+	router.HandleFunc("/conversations/{id}/snooze", handler.SnoozeConversation).Methods("POST")
+	router.HandleFunc("/conversations/{id}/escalate", handler.ForceEscalation).Methods("POST")
 	router.HandleFunc("/health", handler.Health).Methods("GET")
 	router.HandleFunc("/status", handler.Status).Methods("GET")
 
+	// Phase-specific routes
+	for _, extension := range extensions {
+		extension.RegisterRoutes(router)
+	}
+
 	// Metrics endpoint
 	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
-	// Add logging middleware
-	router.Use(loggingMiddleware(logger))
+	// Outermost first, so requests are logged and measured with the status
+	// recovery answers a panic with
+	middleware := []mux.MiddlewareFunc{
+		requestIDMiddleware,
+		loggingMiddleware(logger),
+		metricsMiddleware(metrics),
+		recoveryMiddleware(logger),
+	}
+	router.Use(middleware...)
+
+	// The router only applies its middleware to matched routes
+	router.NotFoundHandler = chain(problem.Handler(http.StatusNotFound, problem.CodeNotFound, "No such route"), middleware)
+	router.MethodNotAllowedHandler = chain(problem.Handler(http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, "The route doesn't take this method"), middleware)
 
 	return &http.Server{
 		Addr:         ":" + config.Port,
@@ -39,19 +76,20 @@ func NewHTTPServer(config *config.Config, timeoutManager *phase1.TimeoutManager,
 	}
 }
 
-func loggingMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-
-			next.ServeHTTP(w, r)
+// Start serves server in the background
+func Start(server *http.Server, logger *logrus.Logger) {
+	go func() {
+		logger.WithField("addr", server.Addr).Info("Starting HTTP server")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Fatal("HTTP server failed")
+		}
+	}()
+}
 
-			logger.WithFields(logrus.Fields{
-				"method":   r.Method,
-				"path":     r.URL.Path,
-				"duration": time.Since(start),
-				"remote":   r.RemoteAddr,
-			}).Debug("HTTP request processed")
-		})
+// chain wraps handler in middleware, the first outermost, as router.Use does
+func chain(handler http.Handler, middleware []mux.MiddlewareFunc) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
 	}
+	return handler
 }
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/clock"
+	"redis-timeout-tracking-poc/pkg/config"
+	"redis-timeout-tracking-poc/pkg/escalation"
+	"redis-timeout-tracking-poc/pkg/metrics"
+	"redis-timeout-tracking-poc/pkg/phase1"
+	"redis-timeout-tracking-poc/pkg/problem"
+)
+
+type testCluster struct{}
+
+func (testCluster) IsLeader() bool     { return true }
+func (testCluster) OwnedShards() []int { return []int{0} }
+
+// panicking registers a route whose handler panics
+type panicking struct{}
+
+func (panicking) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/panic/{id}", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}).Methods("GET")
+}
+
+func setupTestServer(t *testing.T, extensions ...Extension) (http.Handler, *metrics.Metrics) {
+	cfg := &config.Config{
+		TimeoutIntervalMS: 30000,
+		PodID:             "test-pod",
+		Port:              "0",
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+	metrics := metrics.NewMetricsWithRegistry(prometheus.NewRegistry())
+	clk := clock.NewManual(time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC))
+	evaluator, err := escalation.NewEvaluator(escalation.DefaultPolicySet([]int64{1, 2, 3}), cfg.TimeoutIntervalMS)
+	require.NoError(t, err)
+
+	tm := phase1.NewTimeoutManager(phase1.NewMemoryStore(cfg), cfg, logger, metrics, evaluator, clk)
+	return NewHTTPServer(cfg, tm, evaluator, logger, metrics, testCluster{}, clk, extensions...).Handler, metrics
+}
+
+func TestHTTPServer_Middleware(t *testing.T) {
+	handler, metrics := setupTestServer(t)
+
+	request := httptest.NewRequest("POST", "/conversations/conv_123/agent-message", strings.NewReader(`{"agent_id": "agent_456", "message_id": "msg_1"}`))
+	request.Header.Set(RequestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "req-1", recorder.Header().Get(RequestIDHeader))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/conversations/conv_123", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"status":"waiting"`)
+	assert.NotEmpty(t, recorder.Header().Get(RequestIDHeader), "an ID is generated when the caller has none")
+
+	// Counted by route, not by conversation
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("POST", "/conversations/{id}/agent-message", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/conversations/{id}", "200")))
+}
+
+func TestHTTPServer_UnmatchedRoutes(t *testing.T) {
+	handler, metrics := setupTestServer(t)
+
+	// Served behind the same middleware as the routes
+	request := httptest.NewRequest("GET", "/nowhere", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, "req-1", recorder.Header().Get(RequestIDHeader))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "unknown", "404")))
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/health", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+	assert.NotEmpty(t, recorder.Header().Get(RequestIDHeader))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("DELETE", "unknown", "405")))
+}
+
+func TestHTTPServer_Extensions(t *testing.T) {
+	handler, metrics := setupTestServer(t, panicking{})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/panic/1", nil))
+
+	// The extension is served behind the same middleware
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	assert.NotEmpty(t, recorder.Header().Get(RequestIDHeader))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/panic/{id}", "500")))
+
+	// The shared routes are still there
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/status", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), `"pod_id":"test-pod"`)
+}
+
+func TestHTTPServer_Problems(t *testing.T) {
+	handler, _ := setupTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+		fields []string
+	}{
+		{"missing fields", "POST", "/conversations/conv_123/agent-message", `{}`, http.StatusBadRequest, problem.CodeValidationFailed, []string{"message_id", "agent_id"}},
+		{"unknown field", "POST", "/conversations/conv_123/agent-message", `{"agent_id": "a", "message_id": "m", "agnet": "x"}`, http.StatusBadRequest, problem.CodeUnknownField, nil},
+		{"invalid body", "POST", "/conversations/conv_123/customer-response", `{"customer_id":`, http.StatusBadRequest, problem.CodeInvalidBody, nil},
+		{"body too large", "POST", "/conversations/conv_123/customer-response", `{"customer_id": "` + strings.Repeat("c", 70<<10) + `"}`, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge, nil},
+		{"invalid conversation ID", "GET", "/conversations/conv%20123", "", http.StatusBadRequest, problem.CodeInvalidParameter, []string{"id"}},
+		{"timestamp in the future", "POST", "/conversations/conv_123/customer-response", `{"customer_id": "c", "message_id": "m", "timestamp": "2026-10-16T10:00:00Z"}`, http.StatusBadRequest, problem.CodeValidationFailed, []string{"timestamp"}},
+		{"timestamp too old", "POST", "/conversations/conv_123/customer-response", `{"customer_id": "c", "message_id": "m", "timestamp": "2026-10-14T09:00:00Z"}`, http.StatusBadRequest, problem.CodeValidationFailed, []string{"timestamp"}},
+		{"not tracked", "POST", "/conversations/conv_123/pause", "", http.StatusNotFound, problem.CodeConversationNotTracked, nil},
+		{"no route", "GET", "/nowhere", "", http.StatusNotFound, problem.CodeNotFound, nil},
+		{"wrong method", "DELETE", "/status", "", http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, problem.ContentType, recorder.Header().Get("Content-Type"))
+
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &p))
+			assert.Equal(t, tt.status, p.Status)
+			assert.Equal(t, tt.code, p.Code)
+			assert.Equal(t, "urn:timeout-tracking:problem:"+tt.code, p.Type)
+
+			var fields []string
+			for _, field := range p.Errors {
+				fields = append(fields, field.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestHTTPServer_BatchRejectsInvalidEvents(t *testing.T) {
+	handler, _ := setupTestServer(t)
+
+	body := `{"events": [
+		{"type": "agent_message", "conversation_id": "conv_1", "agent_id": "agent_1", "message_id": "msg_1"},
+		{"type": "agent_message", "conversation_id": "conv 2", "agent_id": "agent_1", "message_id": "msg_2"},
+		{"type": "customer_response", "conversation_id": "conv_3", "message_id": "msg_3"}
+	]}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/conversations/events:batch", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response struct {
+		Failed  int `json:"failed"`
+		Results []struct {
+			Index  int    `json:"index"`
+			Result string `json:"result"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	// The valid event is applied despite the others
+	assert.Equal(t, 2, response.Failed)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, "applied", response.Results[0].Result)
+	assert.Equal(t, "rejected", response.Results[1].Result)
+	assert.Contains(t, response.Results[1].Error, "conversation_id")
+	assert.Equal(t, "rejected", response.Results[2].Result)
+	assert.Contains(t, response.Results[2].Error, "customer_id")
+}
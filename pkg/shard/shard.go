@@ -0,0 +1,78 @@
+// Package shard partitions waiting conversations into detection shards and
+// decides which pod owns each shard
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+)
+
+// Slots is the number of hash slots conversation IDs are hashed into, as in
+// Redis Cluster
+const Slots = 16384
+
+// Slot returns the hash slot of key: CRC16 (XMODEM) modulo Slots, the hash
+// Redis Cluster uses. Unlike Redis, hash tags in key are not special.
+func Slot(key string) int {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % Slots
+}
+
+// For returns which of count shards a conversation belongs to
+func For(conversationID string, count int) int {
+	if count <= 1 {
+		return 0
+	}
+	return Slot(conversationID) % count
+}
+
+// Key returns the name of base for a shard. The shard number is a hash tag,
+// so on a cluster all keys of a shard land in the same slot and scripts can
+// use them together.
+func Key(base string, shard int) string {
+	return fmt.Sprintf("%s:{%d}", base, shard)
+}
+
+// Owner picks the member that should own a shard by rendezvous hashing: the
+// member with the highest hash of (member, shard) wins. When a member joins
+// or leaves, only the shards it wins or held change owner. Owner returns ""
+// when there are no members.
+func Owner(shard int, members []string) string {
+	var owner string
+	var best uint64
+	for _, member := range members {
+		score := weight(member, shard)
+		if owner == "" || score > best || (score == best && member < owner) {
+			owner, best = member, score
+		}
+	}
+	return owner
+}
+
+func weight(member string, shard int) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(member))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(shard)))
+
+	// FNV alone barely mixes the short suffix; finish with splitmix64 so
+	// shards spread evenly across members
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
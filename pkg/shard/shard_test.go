@@ -0,0 +1,54 @@
+package shard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlot(t *testing.T) {
+	// Values from CLUSTER KEYSLOT
+	assert.Equal(t, 12182, Slot("foo"))
+	assert.Equal(t, 5061, Slot("bar"))
+	assert.Equal(t, 12739, Slot("123456789"))
+}
+
+func TestFor(t *testing.T) {
+	assert.Equal(t, 0, For("foo", 0))
+	assert.Equal(t, 0, For("foo", 1))
+	assert.Equal(t, 12182%7, For("foo", 7))
+}
+
+func TestKey(t *testing.T) {
+	assert.Equal(t, "waiting_conversations:{3}", Key("waiting_conversations", 3))
+}
+
+func TestOwner(t *testing.T) {
+	assert.Equal(t, "", Owner(0, nil))
+
+	members := []string{"pod-a", "pod-b", "pod-c"}
+	owners := make(map[int]string)
+	counts := make(map[string]int)
+	for s := 0; s < 64; s++ {
+		owners[s] = Owner(s, members)
+		counts[owners[s]]++
+	}
+
+	// Every member gets a share
+	for _, member := range members {
+		assert.Greater(t, counts[member], 10, "member %s", member)
+	}
+
+	// Member order doesn't matter
+	for s := 0; s < 64; s++ {
+		assert.Equal(t, owners[s], Owner(s, []string{"pod-c", "pod-a", "pod-b"}))
+	}
+
+	// When a member leaves, only its shards move
+	for s := 0; s < 64; s++ {
+		owner := Owner(s, []string{"pod-a", "pod-c"})
+		if owners[s] != "pod-b" {
+			assert.Equal(t, owners[s], owner, "shard %d", s)
+		}
+	}
+}
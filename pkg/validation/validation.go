@@ -0,0 +1,118 @@
+// Package validation checks the fields of API requests before anything is
+// stored: ID formats and lengths, required fields, and timestamps
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"redis-timeout-tracking-poc/pkg/constants"
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/problem"
+)
+
+// idPattern is what an ID may look like. IDs end up in Redis keys and
+// members, so braces, which would change a key's hash slot, whitespace and
+// other separators are refused.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._:@-]*$`)
+
+// Error lists what is wrong with each invalid field of a request
+type Error struct {
+	Fields []problem.FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator collects the errors of a request's fields. Timestamps are
+// checked against now.
+type Validator struct {
+	now    time.Time
+	fields []problem.FieldError
+}
+
+func New(now time.Time) *Validator {
+	return &Validator{now: now}
+}
+
+// Err returns an *Error listing every invalid field, or nil when all are
+// valid
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: v.fields}
+}
+
+func (v *Validator) fail(field, code, message string) {
+	v.fields = append(v.fields, problem.FieldError{Field: field, Code: code, Message: message})
+}
+
+// RequiredID checks an ID that must be present
+func (v *Validator) RequiredID(field, value string) {
+	if value == "" {
+		v.fail(field, problem.FieldRequired, "is required")
+		return
+	}
+	v.OptionalID(field, value)
+}
+
+// OptionalID checks an ID that may be empty
+func (v *Validator) OptionalID(field, value string) {
+	switch {
+	case value == "":
+	case len(value) > constants.MaxIDLength:
+		v.fail(field, problem.FieldTooLong, fmt.Sprintf("must be at most %d characters", constants.MaxIDLength))
+	case !idPattern.MatchString(value):
+		v.fail(field, problem.FieldInvalidFormat, "must start with a letter or digit and contain only letters, digits and . _ : @ -")
+	}
+}
+
+// Attribute checks an optional policy selection attribute
+func (v *Validator) Attribute(field, value string) {
+	if len(value) > constants.MaxAttributeLength {
+		v.fail(field, problem.FieldTooLong, fmt.Sprintf("must be at most %d characters", constants.MaxAttributeLength))
+	}
+}
+
+// Timestamp checks the time of an event: it may be slightly ahead of now,
+// for clock skew, but not older than conversations are kept. A zero
+// timestamp is left for the caller to default.
+func (v *Validator) Timestamp(field string, value time.Time) {
+	switch {
+	case value.IsZero():
+	case value.After(v.now.Add(constants.MaxEventClockSkew)):
+		v.fail(field, problem.FieldInFuture, fmt.Sprintf("must be at most %s in the future", constants.MaxEventClockSkew))
+	case value.Before(v.now.Add(-constants.ConversationMaxAge)):
+		v.fail(field, problem.FieldTooOld, fmt.Sprintf("must be at most %s in the past", constants.ConversationMaxAge))
+	}
+}
+
+// Event checks the fields of an agent message or customer response, the same
+// way for every API that takes them. An unknown type is left for the timeout
+// manager to reject.
+func Event(now time.Time, event models.ConversationEvent) error {
+	v := New(now)
+	v.RequiredID("conversation_id", event.ConversationID)
+	v.RequiredID("message_id", event.MessageID)
+	v.Timestamp("timestamp", event.Timestamp)
+
+	switch event.Type {
+	case models.EventAgentMessage:
+		v.RequiredID("agent_id", event.AgentID)
+		v.Attribute("policy", event.Policy)
+		v.Attribute("tenant_id", event.TenantID)
+		v.Attribute("channel", event.Channel)
+		v.Attribute("priority", event.Priority)
+	case models.EventCustomerResponse:
+		v.RequiredID("customer_id", event.CustomerID)
+	}
+	return v.Err()
+}
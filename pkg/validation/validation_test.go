@@ -0,0 +1,91 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"redis-timeout-tracking-poc/pkg/models"
+	"redis-timeout-tracking-poc/pkg/problem"
+)
+
+func TestValidator(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		check func(v *Validator)
+		code  string
+	}{
+		{"valid ID", func(v *Validator) { v.RequiredID("id", "conv_123:a.b@c-d") }, ""},
+		{"missing ID", func(v *Validator) { v.RequiredID("id", "") }, problem.FieldRequired},
+		{"missing optional ID", func(v *Validator) { v.OptionalID("id", "") }, ""},
+		{"ID too long", func(v *Validator) { v.RequiredID("id", strings.Repeat("a", 129)) }, problem.FieldTooLong},
+		{"ID with whitespace", func(v *Validator) { v.RequiredID("id", "conv 123") }, problem.FieldInvalidFormat},
+		{"ID with braces", func(v *Validator) { v.OptionalID("id", "{conv}") }, problem.FieldInvalidFormat},
+		{"ID starting with a separator", func(v *Validator) { v.RequiredID("id", "-conv") }, problem.FieldInvalidFormat},
+		{"attribute too long", func(v *Validator) { v.Attribute("channel", strings.Repeat("c", 65)) }, problem.FieldTooLong},
+		{"zero timestamp", func(v *Validator) { v.Timestamp("timestamp", time.Time{}) }, ""},
+		{"timestamp within skew", func(v *Validator) { v.Timestamp("timestamp", now.Add(4*time.Minute)) }, ""},
+		{"timestamp in the future", func(v *Validator) { v.Timestamp("timestamp", now.Add(6*time.Minute)) }, problem.FieldInFuture},
+		{"timestamp too old", func(v *Validator) { v.Timestamp("timestamp", now.Add(-25*time.Hour)) }, problem.FieldTooOld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(now)
+			tt.check(v)
+
+			err := v.Err()
+			if tt.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			fields := err.(*Error).Fields
+			require.Len(t, fields, 1)
+			assert.Equal(t, tt.code, fields[0].Code)
+		})
+	}
+}
+
+func TestValidator_CollectsEveryField(t *testing.T) {
+	v := New(time.Now())
+	v.RequiredID("conversation_id", "")
+	v.RequiredID("message_id", "msg 1")
+
+	err := v.Err()
+	require.Error(t, err)
+	assert.Len(t, err.(*Error).Fields, 2)
+	assert.Equal(t, "conversation_id: is required; message_id: must start with a letter or digit and contain only letters, digits and . _ : @ -", err.Error())
+}
+
+func TestEvent(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+	err := Event(now, models.ConversationEvent{
+		Type:           models.EventAgentMessage,
+		ConversationID: "conv_123",
+		AgentID:        "agent_456",
+		MessageID:      "msg_1",
+		Timestamp:      now,
+	})
+	assert.NoError(t, err)
+
+	// Each type's actor is required
+	err = Event(now, models.ConversationEvent{
+		Type:           models.EventCustomerResponse,
+		ConversationID: "conv 123",
+		MessageID:      "msg_1",
+		Timestamp:      now.Add(-25 * time.Hour),
+	})
+	require.Error(t, err)
+	var fields []string
+	for _, field := range err.(*Error).Fields {
+		fields = append(fields, field.Field)
+	}
+	assert.Equal(t, []string{"conversation_id", "timestamp", "customer_id"}, fields)
+}
@@ -15,6 +15,13 @@ INSTANCES=3
 BASE_PORT=8080
 PIDS=()
 
+# The instances run a single detection shard, so every key is shard 0's
+SHARD_COUNT=1
+LEADER_KEY='timeout:leader:{0}'
+WAITING_KEY='waiting_conversations:{0}'
+SINCE_KEY='waiting_since:{0}'
+STATES_KEY='notification_states:{0}'
+
 # Check for podman
 if ! command -v podman &>/dev/null; then
     echo -e "${RED}❌ 'podman' command not found.${NC}"
@@ -73,8 +80,8 @@ show_leader_status() {
     done
     
     # Redis leader info
-    redis_leader=$(podman exec redis-timeout-poc redis-cli GET timeout:leader 2>/dev/null || echo "(none)")
-    redis_ttl=$(podman exec redis-timeout-poc redis-cli TTL timeout:leader 2>/dev/null || echo "-1")
+    redis_leader=$(podman exec redis-timeout-poc redis-cli GET "$LEADER_KEY" 2>/dev/null || echo "(none)")
+    redis_ttl=$(podman exec redis-timeout-poc redis-cli TTL "$LEADER_KEY" 2>/dev/null || echo "-1")
     
     echo -e "\n${YELLOW}Redis Leader Key:${NC} $redis_leader"
     echo -e "${YELLOW}Leader TTL:${NC} $redis_ttl seconds"
@@ -112,6 +119,7 @@ for i in $(seq 1 $INSTANCES); do
         TIMEOUT_INTERVAL_SECONDS=5 \
         LEADER_ELECTION_TTL_SECONDS=10 \
         LEADER_ELECTION_INTERVAL_SECONDS=3 \
+        SHARD_COUNT=$SHARD_COUNT \
         PORT=$port \
         LOG_LEVEL=info \
         POD_ID=$pod_id \
@@ -221,7 +229,7 @@ for i in {1..12}; do
         show_leader_status
         
         # Check notification state
-        level=$(podman exec redis-timeout-poc redis-cli HGET notification_states $CONV_ID 2>/dev/null || echo "0")
+        level=$(podman exec redis-timeout-poc redis-cli HGET "$STATES_KEY" $CONV_ID 2>/dev/null || echo "0")
         echo -e "${YELLOW}    Notification level for $CONV_ID: $level${NC}"
     fi
     
@@ -256,12 +264,12 @@ done
     done
     
     echo -e "\n${YELLOW}📊 Checking waiting conversations in Redis:${NC}"
-    waiting_count=$(podman exec redis-timeout-poc redis-cli ZCARD waiting_conversations 2>/dev/null || echo "0")
+    waiting_count=$(podman exec redis-timeout-poc redis-cli ZCARD "$WAITING_KEY" 2>/dev/null || echo "0")
     echo "  Total waiting conversations: $waiting_count"
     
     if [ "$waiting_count" != "0" ]; then
         echo -e "${YELLOW}  Details:${NC}"
-        podman exec redis-timeout-poc redis-cli ZRANGE waiting_conversations 0 -1 WITHSCORES | \
+        podman exec redis-timeout-poc redis-cli ZRANGE "$SINCE_KEY" 0 -1 WITHSCORES | \
         while read -r conv_id && read -r timestamp; do
             current_time=$(date +%s)000
             wait_time=$(( (current_time - timestamp) / 1000 ))
@@ -281,7 +289,7 @@ done
     
     # Check notification states
     echo -e "\n${YELLOW}📊 Checking notification states:${NC}"
-    states=$(podman exec redis-timeout-poc redis-cli HGETALL notification_states 2>/dev/null || echo "(empty)")
+    states=$(podman exec redis-timeout-poc redis-cli HGETALL "$STATES_KEY" 2>/dev/null || echo "(empty)")
     if [ "$states" = "(empty)" ]; then
         echo "  (none yet)"
     else
@@ -316,15 +324,15 @@ done
     done
     
     echo -e "\n${YELLOW}📊 Checking conversations after customer responses:${NC}"
-    waiting_count=$(podman exec redis-timeout-poc redis-cli ZCARD waiting_conversations 2>/dev/null || echo "0")
+    waiting_count=$(podman exec redis-timeout-poc redis-cli ZCARD "$WAITING_KEY" 2>/dev/null || echo "0")
     echo "  Remaining waiting conversations: $waiting_count"
     
-    states_count=$(podman exec redis-timeout-poc redis-cli HLEN notification_states 2>/dev/null || echo "0")
+    states_count=$(podman exec redis-timeout-poc redis-cli HLEN "$STATES_KEY" 2>/dev/null || echo "0")
     echo "  Remaining notification states: $states_count"
     
     if [ "$waiting_count" != "0" ]; then
         echo -e "${YELLOW}  Remaining conversations:${NC}"
-        podman exec redis-timeout-poc redis-cli ZRANGE waiting_conversations 0 -1 WITHSCORES
+        podman exec redis-timeout-poc redis-cli ZRANGE "$WAITING_KEY" 0 -1 WITHSCORES
     fi
     
     wait_for_input
@@ -350,7 +358,7 @@ done
     echo -e "\n${YELLOW}⏰ Waiting 6 seconds for first notification...${NC}"
     sleep 6
     
-    level=$(podman exec redis-timeout-poc redis-cli HGET notification_states $restart_conv 2>/dev/null || echo "0")
+    level=$(podman exec redis-timeout-poc redis-cli HGET "$STATES_KEY" $restart_conv 2>/dev/null || echo "0")
     echo "  Notification level: $level"
     
     # Customer responds via different instance
@@ -373,11 +381,11 @@ done
     
     # Show final state
     echo -e "\n${YELLOW}📊 Final Redis state:${NC}"
-    waiting_count=$(podman exec redis-timeout-poc redis-cli ZCARD waiting_conversations 2>/dev/null || echo "0")
+    waiting_count=$(podman exec redis-timeout-poc redis-cli ZCARD "$WAITING_KEY" 2>/dev/null || echo "0")
     echo "  Waiting conversations: $waiting_count"
     
     if [ "$waiting_count" != "0" ]; then
-        podman exec redis-timeout-poc redis-cli ZRANGE waiting_conversations 0 -1 WITHSCORES | \
+        podman exec redis-timeout-poc redis-cli ZRANGE "$SINCE_KEY" 0 -1 WITHSCORES | \
         while read -r conv_id && read -r timestamp; do
             current_time=$(date +%s)000
             wait_time=$(( (current_time - timestamp) / 1000 ))
@@ -406,6 +414,6 @@ done
     echo ""
     echo -e "${BLUE}🔍 Redis monitoring commands:${NC}"
     echo "  podman exec -it redis-timeout-poc redis-cli monitor"
-    echo "  podman exec redis-timeout-poc redis-cli ZRANGE waiting_conversations 0 -1 WITHSCORES"
-    echo "  podman exec redis-timeout-poc redis-cli HGETALL notification_states"
-    echo "  podman exec redis-timeout-poc redis-cli GET timeout:leader" 
\ No newline at end of file
+    echo "  podman exec redis-timeout-poc redis-cli ZRANGE '$WAITING_KEY' 0 -1 WITHSCORES"
+    echo "  podman exec redis-timeout-poc redis-cli HGETALL '$STATES_KEY'"
+    echo "  podman exec redis-timeout-poc redis-cli GET '$LEADER_KEY'"
\ No newline at end of file